@@ -0,0 +1,162 @@
+package ai
+
+import (
+	"ai-notes/internal/model"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Anthropic Messages API 未指定 max_tokens 时的默认值 (该字段必填)
+const anthropicMaxTokens = 4096
+
+// Anthropic 原生 Messages API
+type Anthropic struct {
+	BaseURL string
+	APIKey  string
+	Model   string
+	Client  *http.Client
+}
+
+func (p *Anthropic) Name() string         { return "anthropic" }
+func (p *Anthropic) DefaultModel() string { return p.Model }
+
+func (p *Anthropic) header() http.Header {
+	h := http.Header{}
+	h.Set("x-api-key", p.APIKey)
+	h.Set("anthropic-version", "2023-06-01")
+	return h
+}
+
+type anthropicRequest struct {
+	Model       string          `json:"model"`
+	System      string          `json:"system,omitempty"`
+	Messages    []model.Message `json:"messages"`
+	MaxTokens   int             `json:"max_tokens"`
+	Temperature *float64        `json:"temperature,omitempty"`
+	Stream      bool            `json:"stream"`
+}
+
+func (p *Anthropic) ChatStream(ctx context.Context, req ChatRequest) (Stream, error) {
+	body := anthropicRequest{
+		Model:       req.Model,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		Stream:      true,
+	}
+	if body.Model == "" {
+		body.Model = p.Model
+	}
+	if body.MaxTokens == 0 {
+		body.MaxTokens = anthropicMaxTokens
+	}
+	// system 消息是单独的字段，不在 messages 中
+	var system []string
+	for _, m := range req.Messages {
+		if m.Role == "system" {
+			system = append(system, m.Content)
+			continue
+		}
+		body.Messages = append(body.Messages, m)
+	}
+	body.System = strings.Join(system, "\n\n")
+
+	resp, err := post(ctx, p.Client, p.BaseURL+"/v1/messages", p.header(), body)
+	if err != nil {
+		return nil, err
+	}
+	return &anthropicStream{bodyStream: bodyStream{body: resp.Body}, sse: newSSEReader(resp.Body)}, nil
+}
+
+type anthropicStream struct {
+	bodyStream
+	sse   *sseReader
+	usage Usage
+	done  bool // 已收到 message_stop
+}
+
+type anthropicEvent struct {
+	Message struct {
+		Usage struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	} `json:"message"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Usage struct {
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (s *anthropicStream) Recv() (Event, error) {
+	for {
+		if s.done {
+			return Event{}, io.EOF
+		}
+		name, data, err := s.sse.next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return Event{}, io.ErrUnexpectedEOF // 没有收到 message_stop
+			}
+			return Event{}, err
+		}
+		var ev anthropicEvent
+		if data != "" {
+			if err := json.Unmarshal([]byte(data), &ev); err != nil {
+				return Event{}, fmt.Errorf("解析 AI 输出失败: %w", err)
+			}
+		}
+		switch name {
+		case "message_start":
+			s.usage.InputTokens = ev.Message.Usage.InputTokens
+			s.usage.OutputTokens = ev.Message.Usage.OutputTokens
+		case "content_block_delta":
+			if ev.Delta.Type == "text_delta" && ev.Delta.Text != "" {
+				return Event{Type: EventDelta, Text: ev.Delta.Text}, nil
+			}
+		case "message_delta":
+			s.usage.OutputTokens = ev.Usage.OutputTokens
+		case "message_stop":
+			s.done = true
+			usage := s.usage
+			return Event{Type: EventUsage, Usage: &usage}, nil
+		case "error":
+			return Event{}, errors.New(ev.Error.Message)
+		}
+	}
+}
+
+// Embed Anthropic 没有 embeddings 接口
+func (p *Anthropic) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	return nil, ErrNotSupported
+}
+
+func (p *Anthropic) ListModels(ctx context.Context) ([]string, error) {
+	resp, err := get(ctx, p.Client, p.BaseURL+"/v1/models", p.header())
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := decodeJSON(resp, &out); err != nil {
+		return nil, err
+	}
+	models := make([]string, 0, len(out.Data))
+	for _, m := range out.Data {
+		models = append(models, m.ID)
+	}
+	return models, nil
+}
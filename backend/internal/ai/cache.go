@@ -0,0 +1,107 @@
+package ai
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Cache 按内容寻址的结果缓存：键为完整请求 (服务商、模型、参数、渲染后的消息) 的哈希，
+// 超过 TTL 的条目失效，总大小超过上限时淘汰最久未使用的条目。并发安全
+type Cache struct {
+	ttl      time.Duration
+	maxBytes int
+
+	mu    sync.Mutex
+	size  int
+	order *list.List // 最近使用的在前
+	items map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key     string
+	text    string
+	expires time.Time
+}
+
+// NewCache 创建缓存；maxBytes <= 0 时返回 nil，表示不缓存
+func NewCache(ttl time.Duration, maxBytes int) *Cache {
+	if maxBytes <= 0 {
+		return nil
+	}
+	return &Cache{ttl: ttl, maxBytes: maxBytes, order: list.New(), items: map[string]*list.Element{}}
+}
+
+// CacheKey 请求的缓存键；model 为空时应传入实际使用的默认模型，保证切换默认模型后不会命中旧结果
+func CacheKey(provider string, req ChatRequest) string {
+	data, _ := json.Marshal(struct {
+		Provider string
+		ChatRequest
+	}{provider, req})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Get 读取未过期的缓存
+func (c *Cache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return "", false
+	}
+	e := el.Value.(*cacheEntry)
+	if c.ttl > 0 && time.Now().After(e.expires) {
+		c.remove(el)
+		return "", false
+	}
+	c.order.MoveToFront(el)
+	return e.text, true
+}
+
+// Put 写入缓存，单条超过总大小上限时不缓存
+func (c *Cache) Put(key, text string) {
+	if len(text) > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	c.items[key] = c.order.PushFront(&cacheEntry{key: key, text: text, expires: time.Now().Add(c.ttl)})
+	c.size += len(text)
+	for c.size > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *Cache) remove(el *list.Element) {
+	e := c.order.Remove(el).(*cacheEntry)
+	delete(c.items, e.key)
+	c.size -= len(e.text)
+}
+
+// Replay 把缓存的结果作为 Stream 重放：一个 delta 事件后结束，与实时输出的事件格式相同
+func Replay(text string) Stream {
+	return &replayStream{text: text}
+}
+
+type replayStream struct {
+	text string
+	sent bool
+}
+
+func (r *replayStream) Recv() (Event, error) {
+	if r.sent || r.text == "" {
+		return Event{}, io.EOF
+	}
+	r.sent = true
+	return Event{Type: EventDelta, Text: r.text}, nil
+}
+
+func (r *replayStream) Close() error { return nil }
@@ -0,0 +1,90 @@
+package ai
+
+import (
+	"ai-notes/internal/model"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestNewCacheDisabled(t *testing.T) {
+	if c := NewCache(time.Hour, 0); c != nil {
+		t.Error("maxBytes 为 0 时应不缓存")
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	c := NewCache(time.Hour, 100)
+	c.Put("k", "v")
+	if v, ok := c.Get("k"); !ok || v != "v" {
+		t.Fatalf("Get = %q, %v", v, ok)
+	}
+	// 让条目过期
+	c.items["k"].Value.(*cacheEntry).expires = time.Now().Add(-time.Second)
+	if _, ok := c.Get("k"); ok {
+		t.Fatal("过期的条目不应命中")
+	}
+	if c.size != 0 || len(c.items) != 0 || c.order.Len() != 0 {
+		t.Errorf("过期条目未清理: size %d, items %d", c.size, len(c.items))
+	}
+
+	// TTL 为 0 时不过期
+	c = NewCache(0, 100)
+	c.Put("k", "v")
+	c.items["k"].Value.(*cacheEntry).expires = time.Now().Add(-time.Hour)
+	if _, ok := c.Get("k"); !ok {
+		t.Error("TTL 为 0 时条目不应过期")
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	c := NewCache(time.Hour, 10)
+	c.Put("a", "1234")
+	c.Put("b", "1234")
+	c.Get("a") // a 最近使用过，超限时先淘汰 b
+	c.Put("c", "1234")
+	if _, ok := c.Get("b"); ok {
+		t.Error("最久未使用的 b 应被淘汰")
+	}
+	for _, k := range []string{"a", "c"} {
+		if _, ok := c.Get(k); !ok {
+			t.Errorf("%s 不应被淘汰", k)
+		}
+	}
+	if c.size != 8 {
+		t.Errorf("size = %d，期望 8", c.size)
+	}
+
+	// 覆盖同一个键不重复计算大小
+	c.Put("a", "12")
+	if c.size != 6 {
+		t.Errorf("覆盖后 size = %d，期望 6", c.size)
+	}
+	// 单条超过上限时不缓存，也不挤掉已有条目
+	c.Put("big", "12345678901")
+	if _, ok := c.Get("big"); ok || len(c.items) != 2 {
+		t.Errorf("超大条目不应缓存，items %d", len(c.items))
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	req := ChatRequest{Model: "m", Messages: []model.Message{{Role: "user", Content: "hi"}}}
+	other := req
+	other.Messages = []model.Message{{Role: "user", Content: "hello"}}
+	if CacheKey("openai", req) != CacheKey("openai", req) {
+		t.Error("相同请求的键应相同")
+	}
+	if CacheKey("openai", req) == CacheKey("ollama", req) || CacheKey("openai", req) == CacheKey("openai", other) {
+		t.Error("服务商或消息不同时键应不同")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	s := Replay("text")
+	if ev, err := s.Recv(); err != nil || ev.Type != EventDelta || ev.Text != "text" {
+		t.Fatalf("Recv = %+v, %v", ev, err)
+	}
+	if _, err := s.Recv(); err != io.EOF {
+		t.Errorf("第二次 Recv 应返回 EOF，得到 %v", err)
+	}
+}
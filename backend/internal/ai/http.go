@@ -0,0 +1,113 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// post 发送 JSON 请求，非 200 时读取响应内容并返回 *APIError
+func post(ctx context.Context, client *http.Client, url string, header http.Header, body any) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if header != nil {
+		req.Header = header.Clone()
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return do(client, req)
+}
+
+// get 发送 GET 请求，非 200 时返回 *APIError
+func get(ctx context.Context, client *http.Client, url string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if header != nil {
+		req.Header = header.Clone()
+	}
+	return do(client, req)
+}
+
+func do(client *http.Client, req *http.Request) (*http.Response, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return nil, &APIError{Status: resp.StatusCode, Body: string(body)}
+	}
+	return resp, nil
+}
+
+// decodeJSON 读取并解析整个响应
+func decodeJSON(resp *http.Response, v any) error {
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// sseReader 逐条读取 text/event-stream，返回每条消息的 event 名和 data (多行 data 以换行拼接)
+type sseReader struct {
+	r *bufio.Reader
+}
+
+func newSSEReader(r io.Reader) *sseReader {
+	return &sseReader{r: bufio.NewReader(r)}
+}
+
+func (s *sseReader) next() (event, data string, err error) {
+	var lines []string
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil && line == "" {
+			if err == io.EOF && len(lines) > 0 {
+				return event, strings.Join(lines, "\n"), nil
+			}
+			return "", "", err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "":
+			if len(lines) > 0 || event != "" {
+				return event, strings.Join(lines, "\n"), nil
+			}
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(line[len("event:"):])
+		case strings.HasPrefix(line, "data:"):
+			lines = append(lines, strings.TrimPrefix(line[len("data:"):], " "))
+		}
+		// 注释 (":") 和其他字段忽略
+	}
+}
+
+// bodyStream Stream 的公共部分：关闭响应体，并缓存一次解析出的多个事件
+type bodyStream struct {
+	body    io.ReadCloser
+	pending []Event
+}
+
+func (b *bodyStream) Close() error {
+	return b.body.Close()
+}
+
+// pop 取出缓存的事件
+func (b *bodyStream) pop() (Event, bool) {
+	if len(b.pending) == 0 {
+		return Event{}, false
+	}
+	ev := b.pending[0]
+	b.pending = b.pending[1:]
+	return ev, true
+}
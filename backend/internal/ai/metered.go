@@ -0,0 +1,173 @@
+package ai
+
+import (
+	"ai-notes/internal/model"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Call 一次调用的来源 (动作名、客户端)，由 HTTP 层放入 context，记录用量时使用
+type Call struct {
+	Action string
+	Client string
+}
+
+type callKey struct{}
+
+// WithCall 在 ctx 中记录调用来源
+func WithCall(ctx context.Context, call Call) context.Context {
+	return context.WithValue(ctx, callKey{}, call)
+}
+
+// CallFrom 取出调用来源，没有时为空 (如后台生成向量)
+func CallFrom(ctx context.Context) Call {
+	call, _ := ctx.Value(callKey{}).(Call)
+	return call
+}
+
+// EstimateTokens 服务商没有返回用量时粗略估算 token 数：英文约 4 个字符一个 token，中日韩文字约一字一个
+func EstimateTokens(s string) int {
+	var c tokenCounter
+	c.add(s)
+	return c.tokens()
+}
+
+type tokenCounter struct {
+	ascii, other int
+}
+
+func (c *tokenCounter) add(s string) {
+	n := utf8.RuneCountInString(s)
+	ascii := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] < utf8.RuneSelf {
+			ascii++
+		}
+	}
+	c.ascii += ascii
+	c.other += n - ascii
+}
+
+func (c *tokenCounter) tokens() int {
+	return (c.ascii+3)/4 + c.other
+}
+
+// Metered 包装服务商：每次对话 / 向量调用结束后 (包括出错和被取消) 把用量记录交给 record
+func Metered(p AIProvider, record func(model.AIUsage)) AIProvider {
+	return &metered{AIProvider: p, record: record}
+}
+
+type metered struct {
+	AIProvider
+	record func(model.AIUsage)
+}
+
+// usageRecord 一条记录的公共字段
+func (m *metered) usageRecord(ctx context.Context, action, modelName string) model.AIUsage {
+	call := CallFrom(ctx)
+	if call.Action != "" {
+		action = call.Action
+	}
+	if modelName == "" {
+		modelName = m.DefaultModel()
+	}
+	return model.AIUsage{Action: action, Provider: m.Name(), Model: modelName, Client: call.Client}
+}
+
+func (m *metered) ChatStream(ctx context.Context, req ChatRequest) (Stream, error) {
+	s := &meteredStream{rec: m.usageRecord(ctx, "chat", req.Model), start: time.Now(), record: m.record}
+	for _, msg := range req.Messages {
+		s.input.add(msg.Content)
+	}
+	stream, err := m.AIProvider.ChatStream(ctx, req)
+	if err != nil {
+		// 没有产生输出，上游一般也不计费，只记录失败
+		s.finish(err)
+		return nil, err
+	}
+	s.Stream = stream
+	return s, nil
+}
+
+func (m *metered) Embed(ctx context.Context, modelName string, texts []string) ([][]float32, error) {
+	rec := m.usageRecord(ctx, "embed", modelName)
+	start := time.Now()
+	vecs, err := m.AIProvider.Embed(ctx, modelName, texts)
+	for _, t := range texts {
+		rec.InputTokens += EstimateTokens(t)
+	}
+	rec.Estimated = true
+	rec.LatencyMs = time.Since(start).Milliseconds()
+	rec.Status, rec.Error = status(err)
+	if err != nil {
+		rec.InputTokens = 0
+	}
+	m.record(rec)
+	return vecs, err
+}
+
+// meteredStream 转发事件的同时统计输出，读完、出错或提前关闭时记录一次用量
+type meteredStream struct {
+	Stream
+	rec    model.AIUsage
+	start  time.Time
+	record func(model.AIUsage)
+	input  tokenCounter
+	output tokenCounter
+	usage  *Usage
+	once   sync.Once
+}
+
+func (s *meteredStream) Recv() (Event, error) {
+	ev, err := s.Stream.Recv()
+	switch {
+	case err != nil:
+		s.finish(err)
+	case ev.Type == EventDelta:
+		s.output.add(ev.Text)
+	case ev.Type == EventUsage && ev.Usage != nil:
+		u := *ev.Usage
+		s.usage = &u
+	}
+	return ev, err
+}
+
+// Close 没有读完就关闭 (客户端断开或被取消) 时按已收到的内容记录
+func (s *meteredStream) Close() error {
+	s.finish(context.Canceled)
+	return s.Stream.Close()
+}
+
+func (s *meteredStream) finish(err error) {
+	s.once.Do(func() {
+		s.rec.LatencyMs = time.Since(s.start).Milliseconds()
+		s.rec.Status, s.rec.Error = status(err)
+		switch {
+		case s.usage != nil:
+			s.rec.InputTokens, s.rec.OutputTokens = s.usage.InputTokens, s.usage.OutputTokens
+		case s.Stream != nil:
+			s.rec.InputTokens, s.rec.OutputTokens = s.input.tokens(), s.output.tokens()
+			s.rec.Estimated = true
+		}
+		s.record(s.rec)
+	})
+}
+
+// status 记录中的状态和错误信息 (截断到字段长度以内)
+func status(err error) (string, string) {
+	switch {
+	case err == nil || errors.Is(err, io.EOF):
+		return "ok", ""
+	case errors.Is(err, context.Canceled):
+		return "cancelled", ""
+	}
+	msg := []rune(err.Error())
+	if len(msg) > 500 {
+		msg = msg[:500]
+	}
+	return "error", string(msg)
+}
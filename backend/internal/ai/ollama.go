@@ -0,0 +1,148 @@
+package ai
+
+import (
+	"ai-notes/internal/model"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Ollama 本地 Ollama 服务的原生接口 (/api/chat 返回逐行 JSON，而不是 SSE)
+type Ollama struct {
+	BaseURL string
+	Model   string
+	Client  *http.Client
+}
+
+func (p *Ollama) Name() string         { return "ollama" }
+func (p *Ollama) DefaultModel() string { return p.Model }
+
+type ollamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []model.Message `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Options  map[string]any  `json:"options,omitempty"`
+}
+
+type ollamaChunk struct {
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	Done            bool   `json:"done"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+	Error           string `json:"error"`
+}
+
+func (p *Ollama) ChatStream(ctx context.Context, req ChatRequest) (Stream, error) {
+	body := ollamaChatRequest{Model: req.Model, Messages: req.Messages, Stream: true}
+	if body.Model == "" {
+		body.Model = p.Model
+	}
+	opts := map[string]any{}
+	if req.Temperature != nil {
+		opts["temperature"] = *req.Temperature
+	}
+	if req.MaxTokens > 0 {
+		opts["num_predict"] = req.MaxTokens
+	}
+	if len(opts) > 0 {
+		body.Options = opts
+	}
+
+	resp, err := post(ctx, p.Client, p.BaseURL+"/api/chat", nil, body)
+	if err != nil {
+		return nil, err
+	}
+	return &ollamaStream{bodyStream: bodyStream{body: resp.Body}, r: bufio.NewReader(resp.Body)}, nil
+}
+
+type ollamaStream struct {
+	bodyStream
+	r    *bufio.Reader
+	done bool
+}
+
+func (s *ollamaStream) Recv() (Event, error) {
+	for {
+		if ev, ok := s.pop(); ok {
+			return ev, nil
+		}
+		if s.done {
+			return Event{}, io.EOF
+		}
+		line, err := s.r.ReadString('\n')
+		if err != nil && strings.TrimSpace(line) == "" {
+			if errors.Is(err, io.EOF) {
+				return Event{}, io.ErrUnexpectedEOF // 没有收到 done
+			}
+			return Event{}, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		var chunk ollamaChunk
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return Event{}, fmt.Errorf("解析 AI 输出失败: %w", err)
+		}
+		if chunk.Error != "" {
+			return Event{}, errors.New(chunk.Error)
+		}
+		if chunk.Message.Content != "" {
+			s.pending = append(s.pending, Event{Type: EventDelta, Text: chunk.Message.Content})
+		}
+		if chunk.Done {
+			s.done = true
+			s.pending = append(s.pending, Event{Type: EventUsage, Usage: &Usage{
+				InputTokens:  chunk.PromptEvalCount,
+				OutputTokens: chunk.EvalCount,
+			}})
+		}
+	}
+}
+
+func (p *Ollama) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	resp, err := post(ctx, p.Client, p.BaseURL+"/api/embed", nil, map[string]any{
+		"model": model,
+		"input": texts,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := decodeJSON(resp, &out); err != nil {
+		return nil, fmt.Errorf("解析 embeddings 响应失败: %w", err)
+	}
+	if len(out.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("embeddings 响应返回 %d 个向量，期望 %d 个", len(out.Embeddings), len(texts))
+	}
+	return out.Embeddings, checkVectors(out.Embeddings)
+}
+
+func (p *Ollama) ListModels(ctx context.Context) ([]string, error) {
+	resp, err := get(ctx, p.Client, p.BaseURL+"/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := decodeJSON(resp, &out); err != nil {
+		return nil, err
+	}
+	models := make([]string, 0, len(out.Models))
+	for _, m := range out.Models {
+		models = append(models, m.Name)
+	}
+	return models, nil
+}
@@ -0,0 +1,180 @@
+package ai
+
+import (
+	"ai-notes/internal/model"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// OpenAI OpenAI 兼容接口 (OpenAI、DeepSeek、各类代理，以及 Ollama 的 /v1 兼容层)
+type OpenAI struct {
+	BaseURL string
+	APIKey  string
+	Model   string
+	Client  *http.Client
+}
+
+func (p *OpenAI) Name() string         { return "openai" }
+func (p *OpenAI) DefaultModel() string { return p.Model }
+
+func (p *OpenAI) header() http.Header {
+	h := http.Header{}
+	if p.APIKey != "" {
+		h.Set("Authorization", "Bearer "+p.APIKey)
+	}
+	return h
+}
+
+type openAIChatRequest struct {
+	Model         string               `json:"model"`
+	Messages      []model.Message      `json:"messages"`
+	Stream        bool                 `json:"stream"`
+	Temperature   *float64             `json:"temperature,omitempty"`
+	MaxTokens     int                  `json:"max_tokens,omitempty"`
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *OpenAI) ChatStream(ctx context.Context, req ChatRequest) (Stream, error) {
+	body := openAIChatRequest{
+		Model:       req.Model,
+		Messages:    req.Messages,
+		Stream:      true,
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+		// 要求在流的最后返回 token 用量
+		StreamOptions: &openAIStreamOptions{IncludeUsage: true},
+	}
+	if body.Model == "" {
+		body.Model = p.Model
+	}
+
+	resp, err := post(ctx, p.Client, p.BaseURL+"/chat/completions", p.header(), body)
+	if err != nil {
+		return nil, err
+	}
+	return &openAIStream{bodyStream: bodyStream{body: resp.Body}, sse: newSSEReader(resp.Body)}, nil
+}
+
+type openAIStream struct {
+	bodyStream
+	sse *sseReader
+}
+
+func (s *openAIStream) Recv() (Event, error) {
+	for {
+		if ev, ok := s.pop(); ok {
+			return ev, nil
+		}
+		_, data, err := s.sse.next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return Event{}, io.EOF
+			}
+			return Event{}, err
+		}
+		if data == "[DONE]" {
+			return Event{}, io.EOF
+		}
+		if data == "" {
+			continue
+		}
+		var chunk openAIChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return Event{}, fmt.Errorf("解析 AI 输出失败: %w", err)
+		}
+		if chunk.Error != nil {
+			return Event{}, errors.New(chunk.Error.Message)
+		}
+		for _, c := range chunk.Choices {
+			if c.Delta.Content != "" {
+				s.pending = append(s.pending, Event{Type: EventDelta, Text: c.Delta.Content})
+			}
+		}
+		if chunk.Usage != nil {
+			s.pending = append(s.pending, Event{Type: EventUsage, Usage: &Usage{
+				InputTokens:  chunk.Usage.PromptTokens,
+				OutputTokens: chunk.Usage.CompletionTokens,
+			}})
+		}
+	}
+}
+
+func (p *OpenAI) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	resp, err := post(ctx, p.Client, p.BaseURL+"/embeddings", p.header(), map[string]any{
+		"model": model,
+		"input": texts,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := decodeJSON(resp, &out); err != nil {
+		return nil, fmt.Errorf("解析 embeddings 响应失败: %w", err)
+	}
+	vecs := make([][]float32, len(texts))
+	for _, d := range out.Data {
+		if d.Index < 0 || d.Index >= len(vecs) {
+			return nil, fmt.Errorf("embeddings 响应的 index %d 越界", d.Index)
+		}
+		vecs[d.Index] = d.Embedding
+	}
+	return vecs, checkVectors(vecs)
+}
+
+func (p *OpenAI) ListModels(ctx context.Context) ([]string, error) {
+	resp, err := get(ctx, p.Client, p.BaseURL+"/models", p.header())
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := decodeJSON(resp, &out); err != nil {
+		return nil, err
+	}
+	models := make([]string, 0, len(out.Data))
+	for _, m := range out.Data {
+		models = append(models, m.ID)
+	}
+	return models, nil
+}
+
+// checkVectors 每条输入都要有向量
+func checkVectors(vecs [][]float32) error {
+	for i, v := range vecs {
+		if len(v) == 0 {
+			return fmt.Errorf("embeddings 响应缺少第 %d 条输入的向量", i)
+		}
+	}
+	return nil
+}
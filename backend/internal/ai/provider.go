@@ -0,0 +1,164 @@
+// Package ai 大模型服务的统一接口：流式对话、向量、模型列表
+// 不同厂商的协议 (OpenAI 兼容接口、Anthropic Messages API、Ollama) 由各自的适配器转换为统一的事件流
+package ai
+
+import (
+	"ai-notes/internal/model"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrNotSupported 当前服务商不支持该能力 (如 Anthropic 没有 embeddings 接口)
+var ErrNotSupported = errors.New("当前 AI 服务商不支持该功能")
+
+// AIProvider 大模型服务商
+type AIProvider interface {
+	// Name 服务商名称：openai / anthropic / ollama
+	Name() string
+	// DefaultModel 请求未指定模型时使用的对话模型
+	DefaultModel() string
+	// ChatStream 发起流式对话；上游返回非 200 时返回 *APIError，此时还没有任何输出
+	ChatStream(ctx context.Context, req ChatRequest) (Stream, error)
+	// Embed 为一批文本生成向量，返回的向量与输入一一对应
+	Embed(ctx context.Context, model string, texts []string) ([][]float32, error)
+	// ListModels 服务商提供的模型
+	ListModels(ctx context.Context) ([]string, error)
+}
+
+// ChatRequest 对话请求，与具体服务商无关
+type ChatRequest struct {
+	Model       string // 为空时使用 DefaultModel
+	Messages    []model.Message
+	Temperature *float64
+	MaxTokens   int // 0 表示由服务商决定 (Anthropic 必填，适配器会填默认值)
+}
+
+// EventType 统一事件流中的事件类型
+type EventType string
+
+const (
+	EventDelta EventType = "delta" // 一段新生成的文本
+	EventUsage EventType = "usage" // token 用量
+	EventDone  EventType = "done"  // 正常结束
+	EventError EventType = "error" // 出错中断
+)
+
+// Usage token 用量
+type Usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// Event 统一事件，前端收到的 SSE 每条 data 都是一个 Event 的 JSON
+type Event struct {
+	Type  EventType `json:"type"`
+	Text  string    `json:"text,omitempty"`
+	Usage *Usage    `json:"usage,omitempty"`
+	Error string    `json:"error,omitempty"`
+}
+
+// Stream 流式对话的输出，Recv 依次返回 delta / usage 事件，正常结束时返回 io.EOF
+type Stream interface {
+	Recv() (Event, error)
+	Close() error
+}
+
+// APIError 上游返回的错误状态码和响应内容
+type APIError struct {
+	Status int
+	Body   string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("AI Error (%d): %s", e.Status, e.Body)
+}
+
+// Config 服务商配置
+type Config struct {
+	Provider string // openai (默认，含 DeepSeek 等兼容接口) / anthropic / ollama
+	BaseURL  string // 为空时使用各服务商的默认地址
+	APIKey   string
+	Model    string // 默认对话模型，为空时使用各服务商的默认模型
+	// ConnectTimeout 建立连接 (含 TLS 握手) 的超时，IdleTimeout 等待上游数据的超时 (流式输出中两段数据之间也算)；
+	// 为 0 时使用 DefaultConnectTimeout / DefaultIdleTimeout
+	ConnectTimeout time.Duration
+	IdleTimeout    time.Duration
+}
+
+// New 按配置创建服务商适配器
+func New(cfg Config) (AIProvider, error) {
+	client := newClient(cfg.ConnectTimeout, cfg.IdleTimeout)
+	pick := func(v, fallback string) string {
+		if v == "" {
+			return fallback
+		}
+		return strings.TrimRight(v, "/")
+	}
+	switch cfg.Provider {
+	case "", "openai":
+		return &OpenAI{
+			BaseURL: pick(cfg.BaseURL, "https://api.deepseek.com"),
+			APIKey:  cfg.APIKey,
+			Model:   pick(cfg.Model, "deepseek-chat"),
+			Client:  client,
+		}, nil
+	case "anthropic":
+		return &Anthropic{
+			BaseURL: pick(cfg.BaseURL, "https://api.anthropic.com"),
+			APIKey:  cfg.APIKey,
+			Model:   pick(cfg.Model, "claude-3-5-haiku-latest"),
+			Client:  client,
+		}, nil
+	case "ollama":
+		return &Ollama{
+			BaseURL: pick(cfg.BaseURL, "http://localhost:11434"),
+			Model:   pick(cfg.Model, "llama3.1"),
+			Client:  client,
+		}, nil
+	default:
+		return nil, fmt.Errorf("不支持的 AI 服务商 AI_PROVIDER=%s", cfg.Provider)
+	}
+}
+
+// Complete 非流式调用：读完整个输出，返回全文和 token 用量
+func Complete(ctx context.Context, p AIProvider, req ChatRequest) (string, Usage, error) {
+	var usage Usage
+	stream, err := p.ChatStream(ctx, req)
+	if err != nil {
+		return "", usage, err
+	}
+	defer stream.Close()
+
+	var out strings.Builder
+	for {
+		ev, err := stream.Recv()
+		if err == io.EOF {
+			return out.String(), usage, nil
+		}
+		if err != nil {
+			return out.String(), usage, err
+		}
+		switch ev.Type {
+		case EventDelta:
+			out.WriteString(ev.Text)
+		case EventUsage:
+			usage = *ev.Usage
+		}
+	}
+}
+
+// Embedder 用指定的向量模型调用服务商的 Embed，满足 semantic.Embedder
+type Embedder struct {
+	Provider AIProvider
+	Name     string
+}
+
+func (e Embedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return e.Provider.Embed(ctx, e.Name, texts)
+}
+
+func (e Embedder) Model() string { return e.Name }
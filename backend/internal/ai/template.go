@@ -0,0 +1,26 @@
+package ai
+
+import (
+	"ai-notes/internal/model"
+	"regexp"
+)
+
+var templateVar = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// Render 把模板中的 {{name}} 替换为 vars 中的值；没有提供的变量替换为空字符串
+// 只做一次替换，变量值里的 {{...}} 不会被再次展开
+func Render(tmpl string, vars map[string]string) string {
+	return templateVar.ReplaceAllStringFunc(tmpl, func(m string) string {
+		return vars[templateVar.FindStringSubmatch(m)[1]]
+	})
+}
+
+// PromptRequest 用模板和变量生成对话请求：有系统提示时作为第一条消息，模型 / 温度取模板中的设置
+func PromptRequest(p *model.Prompt, vars map[string]string) ChatRequest {
+	var messages []model.Message
+	if p.System != "" {
+		messages = append(messages, model.Message{Role: "system", Content: Render(p.System, vars)})
+	}
+	messages = append(messages, model.Message{Role: "user", Content: Render(p.Template, vars)})
+	return ChatRequest{Model: p.Model, Temperature: p.Temperature, Messages: messages}
+}
@@ -0,0 +1,89 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// 默认超时：本地模型 (Ollama) 首次加载可能需要几十秒才开始输出
+const (
+	DefaultConnectTimeout = 10 * time.Second
+	DefaultIdleTimeout    = 120 * time.Second
+)
+
+// ErrIdleTimeout 上游超过 IdleTimeout 没有返回数据
+var ErrIdleTimeout = errors.New("AI 服务响应超时")
+
+// newClient 带连接超时和空闲超时的 http.Client
+// 流式输出可能持续数分钟，不能用 http.Client.Timeout 限制整个请求，改为限制两次收到数据之间的间隔
+func newClient(connect, idle time.Duration) *http.Client {
+	if connect <= 0 {
+		connect = DefaultConnectTimeout
+	}
+	if idle <= 0 {
+		idle = DefaultIdleTimeout
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: connect, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = connect
+	return &http.Client{Transport: &idleTransport{base: transport, idle: idle}}
+}
+
+// idleTransport 等待响应头或读取响应体时超过 idle 没有数据就取消请求
+type idleTransport struct {
+	base http.RoundTripper
+	idle time.Duration
+}
+
+func (t *idleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	b := &idleBody{cancel: cancel}
+	b.timer = time.AfterFunc(t.idle, func() {
+		b.expired.Store(true)
+		cancel()
+	})
+
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		b.timer.Stop()
+		cancel()
+		if b.expired.Load() {
+			return nil, ErrIdleTimeout
+		}
+		return nil, err
+	}
+	b.body, b.idle = resp.Body, t.idle
+	resp.Body = b
+	return resp, nil
+}
+
+// idleBody 每次读到数据时重置计时器
+type idleBody struct {
+	body    io.ReadCloser
+	idle    time.Duration
+	timer   *time.Timer
+	cancel  context.CancelFunc
+	expired atomic.Bool
+}
+
+func (b *idleBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if n > 0 {
+		b.timer.Reset(b.idle)
+	}
+	if err != nil && err != io.EOF && b.expired.Load() {
+		err = ErrIdleTimeout
+	}
+	return n, err
+}
+
+func (b *idleBody) Close() error {
+	b.timer.Stop()
+	b.cancel()
+	return b.body.Close()
+}
@@ -0,0 +1,102 @@
+package dao
+
+import (
+	"ai-notes/internal/model"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrSessionNotFound 对话不存在
+var ErrSessionNotFound = errors.New("对话不存在")
+
+// ChatStore 持久化针对笔记的 AI 对话 (数据库后端支持)
+type ChatStore interface {
+	GetChatSession(id uint) (*model.ChatSession, error)
+	// ListChatSessions 笔记的所有对话，noteID 为 0 时为全部；最近对话过的在前
+	ListChatSessions(noteID uint) ([]model.ChatSession, error)
+	// ListChatMessages 对话的所有消息，按先后排序
+	ListChatMessages(sessionID uint) ([]model.ChatMessage, error)
+	// AppendChatMessages 追加消息，并更新对话的消息数和时间；
+	// session.ID 为 0 时在同一事务中先创建对话，并回填 ID
+	AppendChatMessages(session *model.ChatSession, msgs []model.ChatMessage) error
+	// DeleteChatSession 删除对话及其消息
+	DeleteChatSession(id uint) error
+}
+
+// 编译期检查：NoteDAO 支持对话
+var _ ChatStore = (*NoteDAO)(nil)
+
+// GetChatSession 按 ID 读取对话
+func (s *NoteDAO) GetChatSession(id uint) (*model.ChatSession, error) {
+	var session model.ChatSession
+	if err := s.DB.First(&session, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ListChatSessions 对话列表
+func (s *NoteDAO) ListChatSessions(noteID uint) ([]model.ChatSession, error) {
+	query := s.DB.Order("updated_at desc, id desc")
+	if noteID != 0 {
+		query = query.Where("note_id = ?", noteID)
+	}
+	var list []model.ChatSession
+	if err := query.Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// ListChatMessages 对话的消息
+func (s *NoteDAO) ListChatMessages(sessionID uint) ([]model.ChatMessage, error) {
+	var list []model.ChatMessage
+	if err := s.DB.Where("session_id = ?", sessionID).Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// AppendChatMessages 追加消息；新对话与第一轮消息一起创建，模型调用失败时不会留下空对话
+func (s *NoteDAO) AppendChatMessages(session *model.ChatSession, msgs []model.ChatMessage) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if session.ID == 0 {
+			if err := tx.Create(session).Error; err != nil {
+				return err
+			}
+		}
+		for i := range msgs {
+			msgs[i].SessionID = session.ID
+		}
+		if err := tx.Create(&msgs).Error; err != nil {
+			return err
+		}
+		// Update 会自动刷新 updated_at
+		res := tx.Model(&model.ChatSession{ID: session.ID}).Update("messages", gorm.Expr("messages + ?", len(msgs)))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrSessionNotFound // 对话期间被删除
+		}
+		return nil
+	})
+}
+
+// DeleteChatSession 删除对话
+func (s *NoteDAO) DeleteChatSession(id uint) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Delete(&model.ChatSession{}, id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrSessionNotFound
+		}
+		return tx.Where("session_id = ?", id).Delete(&model.ChatMessage{}).Error
+	})
+}
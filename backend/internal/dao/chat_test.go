@@ -0,0 +1,74 @@
+package dao_test
+
+import (
+	"ai-notes/internal/dao"
+	"ai-notes/internal/dao/daotest"
+	"ai-notes/internal/model"
+	"testing"
+)
+
+// chatCounts 对话数和消息数
+func chatCounts(t *testing.T, s *dao.NoteDAO) (sessions, messages int64) {
+	t.Helper()
+	if err := s.DB.Model(&model.ChatSession{}).Count(&sessions).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DB.Model(&model.ChatMessage{}).Count(&messages).Error; err != nil {
+		t.Fatal(err)
+	}
+	return sessions, messages
+}
+
+func turn(q, a string) []model.ChatMessage {
+	return []model.ChatMessage{{Role: "user", Content: q}, {Role: "assistant", Content: a}}
+}
+
+func TestAppendChatMessagesCreatesSession(t *testing.T) {
+	s := daotest.New(t)
+	session := &model.ChatSession{NoteID: 1, Title: "q1"}
+	if err := s.AppendChatMessages(session, turn("q1", "a1")); err != nil {
+		t.Fatal(err)
+	}
+	if session.ID == 0 {
+		t.Fatal("新对话应回填 ID")
+	}
+	if err := s.AppendChatMessages(session, turn("q2", "a2")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetChatSession(session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Messages != 4 {
+		t.Errorf("消息数 %d，期望 4", got.Messages)
+	}
+	if sessions, messages := chatCounts(t, s); sessions != 1 || messages != 4 {
+		t.Errorf("%d 个对话、%d 条消息，期望 1 个对话、4 条消息", sessions, messages)
+	}
+}
+
+func TestPurgeTrashDeletesChats(t *testing.T) {
+	s := daotest.New(t)
+	for _, title := range []string{"a", "b"} {
+		if err := s.SaveNote(title, "", title); err != nil {
+			t.Fatal(err)
+		}
+		note, err := s.FindNote(title, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AppendChatMessages(&model.ChatSession{NoteID: note.ID}, turn("q", "a")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.DeleteNote("a", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.PurgeTrash(nil); err != nil {
+		t.Fatal(err)
+	}
+	// 只剩未删除的笔记 b 的对话
+	if sessions, messages := chatCounts(t, s); sessions != 1 || messages != 2 {
+		t.Errorf("清理后剩 %d 个对话、%d 条消息，期望 1 个对话、2 条消息", sessions, messages)
+	}
+}
@@ -0,0 +1,19 @@
+// Package daotest 测试用的存储后端
+package daotest
+
+import (
+	"ai-notes/internal/dao"
+	"testing"
+)
+
+// New 内存 SQLite 上的 NoteDAO：每次调用都是独立的空数据库，测试结束时关闭
+func New(t testing.TB) *dao.NoteDAO {
+	t.Helper()
+	s := dao.NewSQLiteNoteDAO(":memory:")
+	t.Cleanup(func() {
+		if db, err := s.DB.DB(); err == nil {
+			db.Close()
+		}
+	})
+	return s
+}
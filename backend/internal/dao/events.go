@@ -0,0 +1,63 @@
+package dao
+
+import (
+	"sync"
+	"time"
+)
+
+// NoteEvent 笔记变更事件
+// 本进程内的写入和 (文件系统后端) 外部编辑器的修改都会产生；移动 / 重命名拆成旧位置 remove + 新位置 write
+type NoteEvent struct {
+	Title     string
+	Folder    string // 完整路径
+	Op        string // "write" / "remove" / "remove_folder" (整个文件夹子树被外部删除或移走，Title 为空)
+	ID        uint   // 不支持 ID 的后端为 0
+	UpdatedAt time.Time
+}
+
+// Observable 支持订阅笔记变更的存储后端 (例如用来维护搜索索引)
+type Observable interface {
+	Subscribe(fn func(NoteEvent))
+}
+
+// 编译期检查：两个后端都支持订阅
+var (
+	_ Observable = (*NoteDAO)(nil)
+	_ Observable = (*FileNoteStore)(nil)
+)
+
+// notifier 保存订阅者，嵌入到各个存储后端中
+// 回调在写入完成 (事务提交、锁释放) 之后同步调用，耗时的处理应由订阅方自行异步执行
+type notifier struct {
+	subMu sync.RWMutex
+	subs  []func(NoteEvent)
+}
+
+// Subscribe 注册变更回调
+func (n *notifier) Subscribe(fn func(NoteEvent)) {
+	n.subMu.Lock()
+	defer n.subMu.Unlock()
+	n.subs = append(n.subs, fn)
+}
+
+func (n *notifier) notify(events ...NoteEvent) {
+	n.subMu.RLock()
+	subs := append([]func(NoteEvent){}, n.subs...)
+	n.subMu.RUnlock()
+	for _, ev := range events {
+		if ev.UpdatedAt.IsZero() {
+			ev.UpdatedAt = time.Now()
+		}
+		for _, fn := range subs {
+			fn(ev)
+		}
+	}
+}
+
+// moveEvents 笔记从旧位置移动到新位置
+func moveEvents(id uint, oldTitle, oldFolder, newTitle, newFolder string) []NoteEvent {
+	return []NoteEvent{
+		{Op: "remove", ID: id, Title: oldTitle, Folder: oldFolder},
+		{Op: "write", ID: id, Title: newTitle, Folder: newFolder},
+	}
+}
@@ -0,0 +1,11 @@
+package dao
+
+import "ai-notes/internal/model"
+
+// 供 dao_test 包的测试使用 (dao_test 才能引用 daotest，避免循环导入)
+
+var RewriteTag = rewriteTag
+
+func (s *NoteDAO) FindNote(title, folderName string) (*model.Note, error) {
+	return s.findNote(title, folderName)
+}
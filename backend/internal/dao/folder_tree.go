@@ -0,0 +1,73 @@
+package dao
+
+import (
+	"ai-notes/internal/model"
+	"sort"
+	"strings"
+)
+
+// 文件夹支持嵌套，v1 接口中的 folder 参数是以 '/' 分隔的完整路径，例如 "Work/ProjectA/Meetings"
+
+// splitFolderPath 把路径拆成各级名称，忽略首尾和重复的 '/'
+func splitFolderPath(path string) []string {
+	var segs []string
+	for _, seg := range strings.Split(path, "/") {
+		if seg != "" {
+			segs = append(segs, seg)
+		}
+	}
+	return segs
+}
+
+// cleanFolderPath 规范化路径："/Work//A/" -> "Work/A"
+func cleanFolderPath(path string) string {
+	return strings.Join(splitFolderPath(path), "/")
+}
+
+// parentFolderPath 上级文件夹路径与最后一级名称
+func parentFolderPath(path string) (parent, name string) {
+	segs := splitFolderPath(path)
+	if len(segs) == 0 {
+		return "", ""
+	}
+	return strings.Join(segs[:len(segs)-1], "/"), segs[len(segs)-1]
+}
+
+// isSubFolder 判断 path 是否等于 ancestor 或位于其下
+func isSubFolder(path, ancestor string) bool {
+	path, ancestor = cleanFolderPath(path), cleanFolderPath(ancestor)
+	return path == ancestor || strings.HasPrefix(path, ancestor+"/")
+}
+
+// buildFolderTree 由完整路径列表构建文件夹树，ids 可为 nil (文件系统后端没有 ID)
+// 同级按名称排序；只出现在子路径里的中间层级也会补出节点
+func buildFolderTree(paths []string, ids map[string]uint) []*model.FolderNode {
+	root := &model.FolderNode{Children: []*model.FolderNode{}}
+	nodes := map[string]*model.FolderNode{"": root}
+
+	var ensure func(path string) *model.FolderNode
+	ensure = func(path string) *model.FolderNode {
+		if n, ok := nodes[path]; ok {
+			return n
+		}
+		parent, name := parentFolderPath(path)
+		n := &model.FolderNode{ID: ids[path], Name: name, Path: path, Children: []*model.FolderNode{}}
+		p := ensure(parent)
+		p.Children = append(p.Children, n)
+		nodes[path] = n
+		return n
+	}
+	for _, p := range paths {
+		ensure(cleanFolderPath(p))
+	}
+
+	var sortTree func(ns []*model.FolderNode)
+	sortTree = func(ns []*model.FolderNode) {
+		sort.Slice(ns, func(i, j int) bool { return ns[i].Name < ns[j].Name })
+		for _, n := range ns {
+			sortTree(n.Children)
+		}
+	}
+	sortTree(root.Children)
+	return root.Children
+}
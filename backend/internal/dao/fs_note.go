@@ -0,0 +1,665 @@
+package dao
+
+import (
+	"ai-notes/internal/model"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// 笔记文件扩展名
+const noteExt = ".md"
+
+// FileNoteStore 以 Markdown 文件保存笔记：文件夹 = 目录，标题 = 文件名
+// 笔记可以直接用其他编辑器修改，也可以用 git 做版本管理
+type FileNoteStore struct {
+	Root string
+
+	mu      sync.RWMutex // 保护本进程内的读写顺序，外部修改靠 watcher 感知
+	watcher *fsnotify.Watcher
+
+	dirMu sync.Mutex
+	dirs  map[string]struct{} // 正在监听的目录，用来区分被删除的是目录还是其他文件
+
+	notifier // 本进程写入和外部修改都会通知订阅者
+}
+
+// 编译期检查：FileNoteStore 必须实现 NoteStore
+var _ NoteStore = (*FileNoteStore)(nil)
+
+// NewFileNoteStore 初始化文件系统存储，并启动目录监听
+func NewFileNoteStore(root string) *FileNoteStore {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		log.Fatal("创建笔记目录失败:", err)
+	}
+	s := &FileNoteStore{Root: root}
+	if err := s.startWatcher(); err != nil {
+		// 监听失败不影响读写，只是感知不到外部修改
+		log.Println("启动文件监听失败:", err)
+	}
+	return s
+}
+
+// Close 停止文件监听
+func (s *FileNoteStore) Close() error {
+	if s.watcher == nil {
+		return nil
+	}
+	return s.watcher.Close()
+}
+
+// ==============================
+// 路径与文件名转义
+// ==============================
+
+// escapeName 把标题 / 文件夹名转成安全的文件名
+// 使用 %XX 转义 '/'、'\\'、Windows 保留字符、控制字符和 '%' 本身；
+// 开头的 '.' (隐藏文件 / ".." ) 和结尾的 ' '、'.' (Windows 会吞掉) 也会被转义
+func escapeName(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		escape := false
+		switch {
+		case c < 0x20 || c == 0x7f:
+			escape = true
+		case strings.IndexByte(`/\:*?"<>|%`, c) >= 0:
+			escape = true
+		case c == '.' && i == 0:
+			escape = true
+		case (c == '.' || c == ' ') && i == len(name)-1:
+			escape = true
+		}
+		if escape {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	out := b.String()
+
+	// Windows 保留设备名 (CON、NUL、COM1 ...) 不能作为文件名，转义首字符
+	base := strings.ToUpper(out)
+	if i := strings.IndexByte(base, '.'); i >= 0 {
+		base = base[:i]
+	}
+	if isReservedName(base) {
+		out = fmt.Sprintf("%%%02X", out[0]) + out[1:]
+	}
+	return out
+}
+
+func isReservedName(name string) bool {
+	switch name {
+	case "CON", "PRN", "AUX", "NUL":
+		return true
+	}
+	if len(name) == 4 && (strings.HasPrefix(name, "COM") || strings.HasPrefix(name, "LPT")) {
+		return name[3] >= '1' && name[3] <= '9'
+	}
+	return false
+}
+
+// unescapeName escapeName 的逆操作；不是合法转义序列的 '%' 原样保留 (兼容手工创建的文件)
+func unescapeName(name string) string {
+	if !strings.Contains(name, "%") {
+		return name
+	}
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '%' && i+2 < len(name) && isHex(name[i+1]) && isHex(name[i+2]) {
+			b.WriteByte(unhex(name[i+1])<<4 | unhex(name[i+2]))
+			i += 2
+			continue
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+// folderPath 文件夹对应的目录，空字符串表示根目录
+// 嵌套文件夹 "Work/ProjectA" 对应子目录 Work/ProjectA，每一级分别转义
+func (s *FileNoteStore) folderPath(folderName string) string {
+	parts := []string{s.Root}
+	for _, seg := range splitFolderPath(folderName) {
+		parts = append(parts, escapeName(seg))
+	}
+	return filepath.Join(parts...)
+}
+
+// folderOfDir folderPath 的逆操作：目录 -> 文件夹完整路径，根目录返回空字符串
+func (s *FileNoteStore) folderOfDir(dir string) string {
+	rel, err := filepath.Rel(s.Root, dir)
+	if err != nil || rel == "." {
+		return ""
+	}
+	var segs []string
+	for _, seg := range strings.Split(filepath.ToSlash(rel), "/") {
+		segs = append(segs, unescapeName(seg))
+	}
+	return strings.Join(segs, "/")
+}
+
+// notePath 笔记对应的文件路径
+func (s *FileNoteStore) notePath(title, folderName string) string {
+	return filepath.Join(s.folderPath(folderName), escapeName(title)+noteExt)
+}
+
+// isTempFile 原子写入时产生的临时文件，列表和监听都要忽略
+func isTempFile(name string) bool {
+	return strings.HasPrefix(name, ".tmp-")
+}
+
+// writeFileAtomic 先写同目录临时文件再 rename，避免写到一半被读取或崩溃后留下半截文件
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // rename 成功后这里是 no-op
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, path)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// ==============================
+// 笔记
+// ==============================
+
+// SaveNote 新建或覆盖笔记文件
+func (s *FileNoteStore) SaveNote(title, folderName, content string) error {
+	if title == "" {
+		return fmt.Errorf("标题不能为空")
+	}
+	var events []NoteEvent
+	defer func() { s.notify(events...) }() // 在释放锁之后通知
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.folderPath(folderName), 0o755); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.notePath(title, folderName), []byte(content)); err != nil {
+		return err
+	}
+	events = append(events, NoteEvent{Op: "write", Title: title, Folder: cleanFolderPath(folderName)})
+	return nil
+}
+
+// GetNote 读取笔记内容
+func (s *FileNoteStore) GetNote(title, folderName string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if folderName != "" && !exists(s.folderPath(folderName)) {
+		return "", fmt.Errorf("找不到文件夹: %s", folderName)
+	}
+	data, err := os.ReadFile(s.notePath(title, folderName))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// ListNotes 根目录和所有子目录下的 .md 文件，按修改时间倒序
+func (s *FileNoteStore) ListNotes() ([]model.NoteSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type entry struct {
+		summary model.NoteSummary
+		modTime time.Time
+	}
+	var entries []entry
+
+	collect := func(dir, folder string) error {
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			name := f.Name()
+			if f.IsDir() || isTempFile(name) || !strings.HasSuffix(name, noteExt) {
+				continue
+			}
+			info, err := f.Info()
+			if err != nil {
+				continue // 文件在遍历过程中被删除
+			}
+			entries = append(entries, entry{
+				summary: model.NoteSummary{
+					Title:     unescapeName(strings.TrimSuffix(name, noteExt)),
+					Folder:    folder,
+					UpdatedAt: info.ModTime(),
+					Size:      int(info.Size()),
+				},
+				modTime: info.ModTime(),
+			})
+		}
+		return nil
+	}
+
+	if err := collect(s.Root, ""); err != nil {
+		return nil, err
+	}
+	dirs, err := s.listFolderDirs()
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		if err := collect(dir, s.folderOfDir(dir)); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].modTime.After(entries[j].modTime)
+	})
+	var summaries []model.NoteSummary
+	for _, e := range entries {
+		summaries = append(summaries, e.summary)
+	}
+	return summaries, nil
+}
+
+// DeleteNote 删除笔记文件
+func (s *FileNoteStore) DeleteNote(title, folderName string) error {
+	var events []NoteEvent
+	defer func() { s.notify(events...) }()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := os.Remove(s.notePath(title, folderName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	events = append(events, NoteEvent{Op: "remove", Title: title, Folder: cleanFolderPath(folderName)})
+	return nil
+}
+
+// UpdateNoteMeta 移动或重命名笔记 (即移动文件)
+func (s *FileNoteStore) UpdateNoteMeta(oldTitle, oldFolder, newTitle, newFolder string) error {
+	if oldTitle == newTitle && oldFolder == newFolder {
+		return nil
+	}
+	var events []NoteEvent
+	defer func() { s.notify(events...) }()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	newPath := s.notePath(newTitle, newFolder)
+	if exists(newPath) {
+		return ErrTitleTaken
+	}
+	oldPath := s.notePath(oldTitle, oldFolder)
+	if !exists(oldPath) {
+		return fmt.Errorf("笔记不存在")
+	}
+	if err := os.MkdirAll(s.folderPath(newFolder), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return err
+	}
+	events = moveEvents(0, oldTitle, cleanFolderPath(oldFolder), newTitle, cleanFolderPath(newFolder))
+	return nil
+}
+
+// ==============================
+// 文件夹
+// ==============================
+
+// listFolderDirs 递归列出根目录下的所有子目录 (绝对路径，跳过隐藏目录如 .git)
+func (s *FileNoteStore) listFolderDirs() ([]string, error) {
+	var dirs []string
+	err := filepath.WalkDir(s.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == s.Root {
+				return err
+			}
+			return nil // 遍历过程中被删除的目录
+		}
+		if !d.IsDir() || path == s.Root {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		dirs = append(dirs, path)
+		return nil
+	})
+	return dirs, err
+}
+
+// RenameFolder 修改文件夹路径 (即移动目录)，可以同时改名和移动到其他上级下
+func (s *FileNoteStore) RenameFolder(oldPath, newPath string) error {
+	oldPath, newPath = cleanFolderPath(oldPath), cleanFolderPath(newPath)
+	if oldPath == "" || newPath == "" {
+		return fmt.Errorf("文件夹名称不能为空")
+	}
+	if oldPath == newPath {
+		return nil
+	}
+	if isSubFolder(newPath, oldPath) {
+		return ErrFolderCycle
+	}
+	var events []NoteEvent
+	defer func() { s.notify(events...) }()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	events, err = s.renameFolder(oldPath, newPath)
+	return err
+}
+
+// MoveFolder 把目录移动到 newParent 下，保持名称不变
+func (s *FileNoteStore) MoveFolder(path, newParent string) error {
+	path, newParent = cleanFolderPath(path), cleanFolderPath(newParent)
+	if path == "" {
+		return fmt.Errorf("不能移动根目录")
+	}
+	if newParent != "" && isSubFolder(newParent, path) {
+		return ErrFolderCycle
+	}
+	var events []NoteEvent
+	defer func() { s.notify(events...) }()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !exists(s.folderPath(newParent)) {
+		return fmt.Errorf("找不到文件夹 '%s'", newParent)
+	}
+	_, name := parentFolderPath(path)
+	newPath := name
+	if newParent != "" {
+		newPath = newParent + "/" + name
+	}
+	if newPath == path {
+		return nil
+	}
+	var err error
+	events, err = s.renameFolder(path, newPath)
+	return err
+}
+
+// renameFolder 调用方需持有写锁，返回子树中每篇笔记的移动事件
+func (s *FileNoteStore) renameFolder(oldPath, newPath string) ([]NoteEvent, error) {
+	newDir := s.folderPath(newPath)
+	if exists(newDir) {
+		return nil, fmt.Errorf("文件夹 '%s' 已存在", newPath)
+	}
+	oldDir := s.folderPath(oldPath)
+	if !exists(oldDir) {
+		return nil, fmt.Errorf("找不到文件夹 '%s'", oldPath)
+	}
+	// 目标上级不存在时创建
+	if err := os.MkdirAll(filepath.Dir(newDir), 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(oldDir, newDir); err != nil {
+		return nil, err
+	}
+
+	var events []NoteEvent
+	for _, n := range s.notesUnder(newDir) {
+		oldFolder := oldPath + strings.TrimPrefix(n.Folder, newPath)
+		events = append(events, moveEvents(0, n.Title, oldFolder, n.Title, n.Folder)...)
+	}
+	return events, nil
+}
+
+// notesUnder 目录 (含子目录) 下的所有笔记，只填 Title / Folder
+func (s *FileNoteStore) notesUnder(dir string) []NoteEvent {
+	var notes []NoteEvent
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(name, ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isTempFile(name) || !strings.HasSuffix(name, noteExt) {
+			return nil
+		}
+		notes = append(notes, NoteEvent{
+			Title:  unescapeName(strings.TrimSuffix(name, noteExt)),
+			Folder: s.folderOfDir(filepath.Dir(path)),
+		})
+		return nil
+	})
+	return notes
+}
+
+// CreateFolder 创建空目录 (缺失的上级一并创建)
+func (s *FileNoteStore) CreateFolder(path string) error {
+	if cleanFolderPath(path) == "" {
+		return fmt.Errorf("文件夹名称不能为空")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return os.MkdirAll(s.folderPath(path), 0o755)
+}
+
+// ListFolders 获取所有文件夹的完整路径，按路径排序
+func (s *FileNoteStore) ListFolders() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	dirs, err := s.listFolderDirs()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, d := range dirs {
+		names = append(names, s.folderOfDir(d))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ListFolderTree 获取文件夹树 (文件系统后端没有 ID)
+func (s *FileNoteStore) ListFolderTree() ([]*model.FolderNode, error) {
+	names, err := s.ListFolders()
+	if err != nil {
+		return nil, err
+	}
+	return buildFolderTree(names, nil), nil
+}
+
+// DeleteFolder 删除目录、子目录及其下所有笔记
+func (s *FileNoteStore) DeleteFolder(path string) error {
+	if cleanFolderPath(path) == "" {
+		return fmt.Errorf("不能删除根目录")
+	}
+	var events []NoteEvent
+	defer func() { s.notify(events...) }()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir := s.folderPath(path)
+	if !exists(dir) {
+		return fmt.Errorf("文件夹不存在")
+	}
+	removed := s.notesUnder(dir)
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	for _, n := range removed {
+		n.Op = "remove"
+		events = append(events, n)
+	}
+	return nil
+}
+
+// ==============================
+// 文件监听 (感知外部编辑器 / git 的修改)
+// ==============================
+
+func (s *FileNoteStore) startWatcher() error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := w.Add(s.Root); err != nil {
+		w.Close()
+		return err
+	}
+	s.watcher = w
+	s.dirs = map[string]struct{}{}
+	// fsnotify 不支持递归监听，每个子目录单独添加
+	dirs, _ := s.listFolderDirs()
+	for _, d := range dirs {
+		s.watchDir(d)
+	}
+	go s.watchLoop()
+	return nil
+}
+
+// watchDir 监听一个子目录并登记
+func (s *FileNoteStore) watchDir(path string) {
+	if err := s.watcher.Add(path); err != nil {
+		log.Printf("监听目录 '%s' 失败: %v", path, err)
+		return
+	}
+	s.dirMu.Lock()
+	s.dirs[path] = struct{}{}
+	s.dirMu.Unlock()
+}
+
+// unwatchDir 目录被删除或移走时注销它及其下级目录，返回它是否是正在监听的目录
+func (s *FileNoteStore) unwatchDir(path string) bool {
+	s.dirMu.Lock()
+	defer s.dirMu.Unlock()
+	if _, ok := s.dirs[path]; !ok {
+		return false
+	}
+	prefix := path + string(filepath.Separator)
+	for d := range s.dirs {
+		if d == path || strings.HasPrefix(d, prefix) {
+			delete(s.dirs, d)
+		}
+	}
+	return true
+}
+
+func (s *FileNoteStore) watchLoop() {
+	for {
+		select {
+		case ev, ok := <-s.watcher.Events:
+			if !ok {
+				return
+			}
+			s.handleFSEvent(ev)
+		case err, ok := <-s.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Println("文件监听出错:", err)
+		}
+	}
+}
+
+func (s *FileNoteStore) handleFSEvent(ev fsnotify.Event) {
+	name := filepath.Base(ev.Name)
+	if isTempFile(name) || strings.HasPrefix(name, ".") {
+		return
+	}
+
+	// 新建 (或移入) 的子目录也需要监听，连同其中已有的下级目录
+	if ev.Has(fsnotify.Create) {
+		if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+			filepath.WalkDir(ev.Name, func(path string, d fs.DirEntry, err error) error {
+				if err != nil || !d.IsDir() {
+					return nil
+				}
+				if path != ev.Name && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				s.watchDir(path)
+				return nil
+			})
+			// 从别处移入的目录里已经有笔记
+			events := s.notesUnder(ev.Name)
+			for i := range events {
+				events[i].Op = "write"
+			}
+			s.notify(events...)
+			return
+		}
+	}
+
+	if !strings.HasSuffix(name, noteExt) {
+		// 目录被删除或移走时已无法列出其中的笔记，按文件夹通知；
+		// 附件、编辑器的临时文件等其他文件忽略 (不能只看有没有扩展名，同名文件会误删文件夹的索引)
+		if (ev.Has(fsnotify.Remove) || ev.Has(fsnotify.Rename)) && s.unwatchDir(ev.Name) {
+			s.notify(NoteEvent{Op: "remove_folder", Folder: s.folderOfDir(ev.Name)})
+		}
+		return
+	}
+	fe := NoteEvent{
+		Title:  unescapeName(strings.TrimSuffix(name, noteExt)),
+		Folder: s.folderOfDir(filepath.Dir(ev.Name)),
+		Op:     "write",
+	}
+	switch {
+	case ev.Has(fsnotify.Remove), ev.Has(fsnotify.Rename):
+		fe.Op = "remove"
+	case ev.Has(fsnotify.Create), ev.Has(fsnotify.Write):
+	default:
+		return // chmod 等事件忽略
+	}
+
+	s.notify(fe)
+}
@@ -0,0 +1,47 @@
+package dao
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestHandleFSEventRemove(t *testing.T) {
+	root := t.TempDir()
+	work := filepath.Join(root, "Work")
+	s := &FileNoteStore{Root: root, dirs: map[string]struct{}{
+		work:                         {},
+		filepath.Join(work, "Sub"):   {},
+		filepath.Join(root, "Other"): {},
+	}}
+	var got []NoteEvent
+	s.Subscribe(func(ev NoteEvent) { got = append(got, ev) })
+
+	tests := []struct {
+		name string
+		path string
+		want []NoteEvent
+	}{
+		{"笔记", filepath.Join(work, "a.md"), []NoteEvent{{Op: "remove", Title: "a", Folder: "Work"}}},
+		{"与文件夹同名的普通文件", filepath.Join(root, "Other", "Work"), nil},
+		{"附件", filepath.Join(work, "image.png"), nil},
+		{"临时文件", filepath.Join(work, "a.md~"), nil},
+		{"监听中的目录", work, []NoteEvent{{Op: "remove_folder", Folder: "Work"}}},
+		{"已注销的下级目录", filepath.Join(work, "Sub"), nil},
+	}
+	for _, tt := range tests {
+		got = nil
+		s.handleFSEvent(fsnotify.Event{Name: tt.path, Op: fsnotify.Remove})
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: 得到 %d 个事件 %+v，期望 %d 个", tt.name, len(got), got, len(tt.want))
+			continue
+		}
+		for i, ev := range got {
+			w := tt.want[i]
+			if ev.Op != w.Op || ev.Title != w.Title || ev.Folder != w.Folder {
+				t.Errorf("%s: 事件 %+v，期望 %+v", tt.name, ev, w)
+			}
+		}
+	}
+}
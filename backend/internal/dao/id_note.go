@@ -0,0 +1,211 @@
+package dao
+
+import (
+	"ai-notes/internal/model"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// IDStore 支持按 ID 寻址笔记 / 文件夹的存储后端 (v2 接口)
+type IDStore interface {
+	GetNoteByID(id uint) (*model.NoteDetail, error)
+	// UpdateNoteByID 按 patch 修改笔记的标题 / 文件夹 / 内容，expected 不为 nil 时做版本检查
+	UpdateNoteByID(id uint, patch model.NotePatch, expected *uint) (*model.NoteDetail, error)
+	// DeleteNoteByID 移入回收站
+	DeleteNoteByID(id uint) error
+	// ListNotesInFolder 文件夹下的笔记，folderID 为 0 表示根目录
+	ListNotesInFolder(folderID uint) ([]model.NoteSummary, error)
+}
+
+// 编译期检查：NoteDAO 支持 ID 寻址
+var _ IDStore = (*NoteDAO)(nil)
+
+// detail 生成单篇笔记详情，paths 为 folderPaths 的结果
+func detail(n *model.Note, paths map[uint]string) *model.NoteDetail {
+	return &model.NoteDetail{
+		NoteSummary: summarize(n, paths),
+		Content:     n.Content,
+		Version:     n.Version,
+	}
+}
+
+// GetNoteByID 按 ID 读取笔记
+func (s *NoteDAO) GetNoteByID(id uint) (*model.NoteDetail, error) {
+	var note model.Note
+	if err := s.DB.First(&note, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	paths, err := s.folderPaths(s.DB)
+	if err != nil {
+		return nil, err
+	}
+	return detail(&note, paths), nil
+}
+
+// UpdateNoteByID 按 ID 修改笔记
+func (s *NoteDAO) UpdateNoteByID(id uint, patch model.NotePatch, expected *uint) (*model.NoteDetail, error) {
+	if patch.Title != nil && *patch.Title == "" {
+		return nil, fmt.Errorf("标题不能为空")
+	}
+
+	var note model.Note
+	var old model.Note // 修改前的标题 / 文件夹，用于变更通知
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&note, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
+		if expected != nil && note.Version != *expected {
+			current := note
+			return &VersionConflictError{Current: &current}
+		}
+		old = note
+
+		updates := map[string]interface{}{}
+		title, folderID := note.Title, note.FolderID
+		if patch.Title != nil {
+			title = *patch.Title
+		}
+		if patch.FolderID.Set {
+			folderID = patch.FolderID.Value
+			if folderID != nil {
+				var count int64
+				if err := tx.Model(&model.Folder{}).Where("id = ?", *folderID).Count(&count).Error; err != nil {
+					return err
+				}
+				if count == 0 {
+					return ErrNotFound
+				}
+			}
+		}
+		if title != note.Title || !sameFolder(folderID, note.FolderID) {
+			taken, err := titleTaken(tx, title, folderID)
+			if err != nil {
+				return err
+			}
+			if taken {
+				return ErrTitleTaken
+			}
+			updates["title"] = title
+			updates["folder_id"] = folderID
+		}
+		contentChanged := patch.Content != nil && *patch.Content != note.Content
+		if contentChanged {
+			updates["content"] = *patch.Content
+			updates["version"] = gorm.Expr("version + 1")
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		coalesce := true
+		if contentChanged {
+			based, err := s.baseRevision(tx, &note)
+			if err != nil {
+				return err
+			}
+			coalesce = !based
+		}
+
+		res := tx.Model(&note).Where("version = ?", note.Version).Updates(updates)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			var current model.Note
+			if err := tx.First(&current, note.ID).Error; err != nil {
+				return err
+			}
+			return &VersionConflictError{Current: &current}
+		}
+		note.Title, note.FolderID = title, folderID
+		if contentChanged {
+			note.Content = *patch.Content
+			note.Version++
+			if err := s.syncTags(tx, &note); err != nil {
+				return err
+			}
+			return s.recordRevision(tx, &note, coalesce)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	d, err := s.GetNoteByID(note.ID)
+	if err != nil {
+		return nil, err
+	}
+	if d.Version != old.Version || d.Title != old.Title || !sameFolder(d.FolderID, old.FolderID) {
+		oldFolder, err := s.folderPathOf(s.DB, old.FolderID)
+		if err != nil {
+			return nil, err
+		}
+		s.notify(moveEvents(d.ID, old.Title, oldFolder, d.Title, d.Folder)...)
+	}
+	return d, nil
+}
+
+func sameFolder(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// DeleteNoteByID 按 ID 把笔记移入回收站
+func (s *NoteDAO) DeleteNoteByID(id uint) error {
+	var note model.Note
+	if err := s.DB.First(&note, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+	folderName, err := s.folderPathOf(s.DB, note.FolderID)
+	if err != nil {
+		return err
+	}
+	if _, err = trashNotes(s.DB.Model(&model.Note{}).Where("id = ?", id), folderName); err != nil {
+		return err
+	}
+	s.notify(NoteEvent{Op: "remove", ID: id, Title: note.Title, Folder: folderName})
+	return nil
+}
+
+// ListNotesInFolder 文件夹下的笔记，最近修改的在前
+func (s *NoteDAO) ListNotesInFolder(folderID uint) ([]model.NoteSummary, error) {
+	query := s.DB.Order("updated_at desc")
+	if folderID == 0 {
+		query = query.Where("folder_id IS NULL")
+	} else {
+		var count int64
+		if err := s.DB.Model(&model.Folder{}).Where("id = ?", folderID).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, ErrNotFound
+		}
+		query = query.Where("folder_id = ?", folderID)
+	}
+
+	var notes []model.Note
+	if err := query.Find(&notes).Error; err != nil {
+		return nil, err
+	}
+	paths, err := s.folderPaths(s.DB)
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]model.NoteSummary, 0, len(notes))
+	for i := range notes {
+		summaries = append(summaries, summarize(&notes[i], paths))
+	}
+	return summaries, nil
+}
@@ -0,0 +1,212 @@
+package dao
+
+import (
+	"ai-notes/internal/model"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrJobNotFound 任务不存在
+var ErrJobNotFound = errors.New("任务不存在")
+
+// 任务 / 条目状态
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobDone      = "done"
+	JobFailed    = "failed" // 仅用于条目
+	JobCancelled = "cancelled"
+)
+
+// JobStore 持久化后台 AI 任务 (数据库后端支持)
+// 条目的状态保存在数据库中，进程重启后未完成的条目会重新排队
+type JobStore interface {
+	// CreateJob 创建任务及其条目，job.Total 按条目数设置
+	CreateJob(job *model.AIJob, items []model.AIJobItem) error
+	GetJob(id uint) (*model.AIJob, error)
+	// ListJobs 最近的 limit 个任务，新的在前
+	ListJobs(limit int) ([]model.AIJob, error)
+	ListJobItems(jobID uint) ([]model.AIJobItem, error)
+	// ClaimJobItem 取出最早的待处理条目并标记为 running，没有时返回 nil
+	ClaimJobItem() (*model.AIJobItem, error)
+	// ReleaseJobItem 把条目放回队列 (如今日预算用完，稍后再试)
+	ReleaseJobItem(id uint) error
+	// FinishJobItem 保存条目的结果 (状态、次数、错误)，更新任务计数，条目全部处理完时结束任务；返回更新后的任务
+	FinishJobItem(item *model.AIJobItem) (*model.AIJob, error)
+	// CancelJob 取消任务：还没开始的条目直接标记为取消，进行中的条目由调用方中断
+	CancelJob(id uint) (*model.AIJob, error)
+	// RequeueJobItems 把上次退出时正在处理的条目放回队列，启动时调用
+	RequeueJobItems() (int64, error)
+}
+
+// 编译期检查：NoteDAO 支持后台任务
+var _ JobStore = (*NoteDAO)(nil)
+
+// CreateJob 创建任务及其条目
+func (s *NoteDAO) CreateJob(job *model.AIJob, items []model.AIJobItem) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		job.Status = JobPending
+		job.Total = len(items)
+		if err := tx.Create(job).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		for i := range items {
+			items[i].JobID = job.ID
+			items[i].Status = JobPending
+		}
+		return tx.CreateInBatches(items, 200).Error
+	})
+}
+
+// GetJob 按 ID 读取任务
+func (s *NoteDAO) GetJob(id uint) (*model.AIJob, error) {
+	var job model.AIJob
+	if err := s.DB.First(&job, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrJobNotFound
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListJobs 最近的任务
+func (s *NoteDAO) ListJobs(limit int) ([]model.AIJob, error) {
+	var list []model.AIJob
+	if err := s.DB.Order("id desc").Limit(limit).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// ListJobItems 任务的所有条目
+func (s *NoteDAO) ListJobItems(jobID uint) ([]model.AIJobItem, error) {
+	var list []model.AIJobItem
+	if err := s.DB.Where("job_id = ?", jobID).Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// ClaimJobItem 取出一个待处理条目
+func (s *NoteDAO) ClaimJobItem() (*model.AIJobItem, error) {
+	var claimed *model.AIJobItem
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		active := tx.Model(&model.AIJob{}).Select("id").Where("status IN ?", []string{JobPending, JobRunning})
+		var item model.AIJobItem
+		res := tx.Where("status = ? AND job_id IN (?)", JobPending, active).Order("id").Limit(1).Find(&item)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		// 带上状态条件，防止同一条目被重复领取
+		res = tx.Model(&item).Where("status = ?", JobPending).Update("status", JobRunning)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		if err := tx.Model(&model.AIJob{}).Where("id = ? AND status = ?", item.JobID, JobPending).
+			Update("status", JobRunning).Error; err != nil {
+			return err
+		}
+		item.Status = JobRunning
+		claimed = &item
+		return nil
+	})
+	return claimed, err
+}
+
+// ReleaseJobItem 把条目放回队列
+func (s *NoteDAO) ReleaseJobItem(id uint) error {
+	return s.DB.Model(&model.AIJobItem{}).Where("id = ? AND status = ?", id, JobRunning).
+		Update("status", JobPending).Error
+}
+
+// FinishJobItem 保存条目结果并更新任务
+func (s *NoteDAO) FinishJobItem(item *model.AIJobItem) (*model.AIJob, error) {
+	var job model.AIJob
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(item).Where("status = ?", JobRunning).Updates(map[string]interface{}{
+			"status":   item.Status,
+			"attempts": item.Attempts,
+			"changed":  item.Changed,
+			"error":    item.Error,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected > 0 {
+			counter := map[string]string{JobDone: "succeeded", JobFailed: "failed", JobCancelled: "cancelled"}[item.Status]
+			if counter != "" {
+				if err := tx.Model(&model.AIJob{}).Where("id = ?", item.JobID).
+					Update(counter, gorm.Expr(counter+" + 1")).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := tx.First(&job, item.JobID).Error; err != nil {
+			return err
+		}
+		if job.Status != JobPending && job.Status != JobRunning {
+			return nil
+		}
+		var remaining int64
+		if err := tx.Model(&model.AIJobItem{}).Where("job_id = ? AND status IN ?", job.ID, []string{JobPending, JobRunning}).
+			Count(&remaining).Error; err != nil {
+			return err
+		}
+		if remaining > 0 {
+			return nil
+		}
+		now := time.Now()
+		job.Status, job.FinishedAt = JobDone, &now
+		return tx.Model(&job).Updates(map[string]interface{}{"status": job.Status, "finished_at": now}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// CancelJob 取消任务
+func (s *NoteDAO) CancelJob(id uint) (*model.AIJob, error) {
+	var job model.AIJob
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&job, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrJobNotFound
+			}
+			return err
+		}
+		if job.Status != JobPending && job.Status != JobRunning {
+			return nil // 已经结束，取消没有效果
+		}
+		res := tx.Model(&model.AIJobItem{}).Where("job_id = ? AND status = ?", id, JobPending).
+			Update("status", JobCancelled)
+		if res.Error != nil {
+			return res.Error
+		}
+		now := time.Now()
+		job.Status, job.FinishedAt = JobCancelled, &now
+		job.Cancelled += int(res.RowsAffected)
+		return tx.Model(&job).Updates(map[string]interface{}{
+			"status":      job.Status,
+			"finished_at": now,
+			"cancelled":   job.Cancelled,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// RequeueJobItems 重新排队上次中断的条目
+func (s *NoteDAO) RequeueJobItems() (int64, error) {
+	res := s.DB.Model(&model.AIJobItem{}).Where("status = ?", JobRunning).Update("status", JobPending)
+	return res.RowsAffected, res.Error
+}
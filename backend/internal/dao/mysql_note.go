@@ -4,13 +4,18 @@ import (
 	"ai-notes/internal/model" // 请确认你的 go.mod 包名
 	"fmt"
 	"log"
+	"sort"
+	"strings"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
 
 type NoteDAO struct {
-	DB *gorm.DB
+	DB        *gorm.DB
+	Revisions RevisionPolicy // 历史版本策略
+
+	notifier // 笔记变更通知 (搜索索引等)
 }
 
 // 初始化 MySQL 连接
@@ -18,34 +23,80 @@ func NewNoteDAO(user, password, host, port, dbName string) *NoteDAO {
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
 		user, password, host, port, dbName)
 
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	return openNoteDAO(mysql.Open(dsn))
+}
+
+// openNoteDAO 打开 GORM 连接并自动迁移表结构，各个 SQL 后端共用
+func openNoteDAO(dialector gorm.Dialector) *NoteDAO {
+	db, err := gorm.Open(dialector, &gorm.Config{})
 	if err != nil {
 		log.Fatal("连接数据库失败:", err)
 	}
 
+	// 旧版本的模板表没有 cacheable 列，迁移后要为内置模板补上默认值
+	addCacheable := db.Migrator().HasTable(&model.Prompt{}) && !db.Migrator().HasColumn(&model.Prompt{}, "Cacheable")
+
 	// 自动迁移模式：自动创建表结构
 	// 先迁移 Folder，再 Note
-	err = db.AutoMigrate(&model.Folder{}, &model.Note{})
+	err = db.AutoMigrate(&model.Folder{}, &model.Note{}, &model.NoteRevision{}, &model.Tag{}, &model.Prompt{}, &model.AIUsage{}, &model.AIJob{}, &model.AIJobItem{}, &model.ChatSession{}, &model.ChatMessage{})
 	if err != nil {
 		log.Fatal("数据库迁移失败:", err)
 	}
 
-	s := &NoteDAO{DB: db}
+	s := &NoteDAO{DB: db, Revisions: DefaultRevisionPolicy}
+	s.migrateFolderTree()
 	s.MigrateLegacyFolders() // 尝试迁移旧数据
+	s.backfillTags()
+	s.seedPrompts()
+	if addCacheable {
+		s.backfillPromptCacheable()
+	}
 	return s
 }
 
+// migrateFolderTree 从平铺文件夹升级到嵌套文件夹
+// 旧表上 name 是全局唯一索引，会阻止不同父文件夹下出现同名子文件夹，需要删掉；
+// 旧文件夹 parent_id 为 NULL，自然成为顶级文件夹。名称里的 '/' 现在是路径分隔符，替换为全角 '／'
+func (s *NoteDAO) migrateFolderTree() {
+	m := s.DB.Migrator()
+	if m.HasIndex(&model.Folder{}, "idx_folders_name") {
+		if err := m.DropIndex(&model.Folder{}, "idx_folders_name"); err != nil {
+			log.Fatal("删除旧的文件夹名唯一索引失败:", err)
+		}
+	}
+	if err := s.DB.Model(&model.Folder{}).Where("name LIKE ?", "%/%").
+		Update("name", gorm.Expr("REPLACE(name, '/', '／')")).Error; err != nil {
+		log.Println("迁移文件夹名中的 '/' 失败:", err)
+	}
+}
+
+// ensureRootTitleIndex 为根目录笔记补一个部分唯一索引
+// idx_title_folder_id 中 NULL 互不相等，根目录 (folder_id IS NULL) 下的同名笔记
+// 不会被唯一索引拦住。SQLite / Postgres 支持部分索引，MySQL 不支持，只能依赖应用层检查
+// 回收站里的笔记 folder_id 同样为 NULL，所以索引只覆盖未删除的笔记
+func (s *NoteDAO) ensureRootTitleIndex() {
+	// 旧版本的索引没有排除回收站，先删掉再重建
+	if err := s.DB.Exec("DROP INDEX IF EXISTS idx_title_root").Error; err != nil {
+		log.Fatal("删除旧的根目录唯一索引失败:", err)
+	}
+	if err := s.DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_title_root_live ON notes (title) WHERE folder_id IS NULL AND deleted_at IS NULL").Error; err != nil {
+		log.Fatal("创建根目录唯一索引失败:", err)
+	}
+	// 同理，顶级文件夹 (parent_id IS NULL) 的名称唯一
+	if err := s.DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_folder_name_root ON folders (name) WHERE parent_id IS NULL").Error; err != nil {
+		log.Fatal("创建顶级文件夹唯一索引失败:", err)
+	}
+}
+
 // MigrateLegacyFolders 将旧的 folder 字符串字段迁移到 Folders 表
 func (s *NoteDAO) MigrateLegacyFolders() {
-	// 检查 notes 表是否有 folder 列 (raw SQL)
+	// 检查 notes 表是否有 folder 列
 	// 如果 Note struct 已经去掉了 Folder 字段，GORM 可能看不到它，但数据库里还在
-	// 我们用 raw sql 检查并迁移
-	
+	// 列检查交给 GORM Migrator (各方言各自实现：MySQL/Postgres 查 information_schema，SQLite 查 PRAGMA)，
+	// 数据迁移本身用通用 SQL
+
 	// 1. 检查是否存在 'folder' 列
-	var count int64
-	s.DB.Raw("SELECT count(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'notes' AND COLUMN_NAME = 'folder'").Scan(&count)
-	
-	if count > 0 {
+	if s.DB.Migrator().HasColumn("notes", "folder") {
 		log.Println("检测到旧版 'folder' 字段，开始迁移数据...")
 		
 		// 2. 获取所有非空的旧 folder 字符串
@@ -57,9 +108,9 @@ func (s *NoteDAO) MigrateLegacyFolders() {
 		
 		for _, r := range results {
 			folderName := r.Folder
-			// 3. 确保 Folder 存在
-			var folder model.Folder
-			if err := s.DB.FirstOrCreate(&folder, model.Folder{Name: folderName}).Error; err != nil {
+			// 3. 确保 Folder 存在 (旧数据是平铺的，统一作为顶级文件夹)
+			folder, err := s.ensureFolder(strings.ReplaceAll(folderName, "/", "／"))
+			if err != nil {
 				log.Printf("迁移创建文件夹 '%s' 失败: %v", folderName, err)
 				continue
 			}
@@ -76,21 +127,91 @@ func (s *NoteDAO) MigrateLegacyFolders() {
 	}
 }
 
-// ensureFolder 根据名称获取或创建 Folder
-func (s *NoteDAO) ensureFolder(name string) (*model.Folder, error) {
-	if name == "" {
-		return nil, nil // 根目录
+// resolveFolder 按完整路径逐级查找文件夹，create 为 true 时创建缺失的层级
+// 路径为空 (根目录) 时返回 nil；不创建且找不到时返回 ErrNotFound
+func (s *NoteDAO) resolveFolder(db *gorm.DB, path string, create bool) (*model.Folder, error) {
+	var parent *model.Folder
+	for _, name := range splitFolderPath(path) {
+		var folder model.Folder
+		q := db.Where("name = ?", name)
+		if parent == nil {
+			q = q.Where("parent_id IS NULL")
+		} else {
+			q = q.Where("parent_id = ?", parent.ID)
+		}
+		if err := q.Limit(1).Find(&folder).Error; err != nil {
+			return nil, err
+		}
+		if folder.ID == 0 {
+			if !create {
+				return nil, ErrNotFound
+			}
+			// 注意：不能用 FirstOrCreate(model.Folder{Name, ParentID})，ParentID 为 nil 时不会出现在查询条件里
+			folder = model.Folder{Name: name}
+			if parent != nil {
+				folder.ParentID = &parent.ID
+			}
+			if err := db.Create(&folder).Error; err != nil {
+				return nil, err
+			}
+		}
+		parent = &folder
 	}
-	var folder model.Folder
-	// 使用 FirstOrCreate 保证存在
-	// 注意：必须把 Name 放在 struct 里传进去，否则 simple Where string 不会被用于创建字段
-	if err := s.DB.FirstOrCreate(&folder, model.Folder{Name: name}).Error; err != nil {
+	return parent, nil
+}
+
+// ensureFolder 根据路径获取或创建 Folder (缺失的上级一并创建)
+func (s *NoteDAO) ensureFolder(path string) (*model.Folder, error) {
+	return s.resolveFolder(s.DB, path, true)
+}
+
+// folderPaths 所有文件夹 ID -> 完整路径
+func (s *NoteDAO) folderPaths(db *gorm.DB) (map[uint]string, error) {
+	var folders []model.Folder
+	if err := db.Select("id", "name", "parent_id").Find(&folders).Error; err != nil {
 		return nil, err
 	}
-	return &folder, nil
+	byID := make(map[uint]model.Folder, len(folders))
+	for _, f := range folders {
+		byID[f.ID] = f
+	}
+
+	paths := make(map[uint]string, len(folders))
+	var pathOf func(id uint, depth int) string
+	pathOf = func(id uint, depth int) string {
+		if p, ok := paths[id]; ok {
+			return p
+		}
+		f := byID[id]
+		p := f.Name
+		// depth 防止脏数据中的环导致死循环
+		if f.ParentID != nil && depth < len(byID) {
+			if _, ok := byID[*f.ParentID]; ok {
+				p = pathOf(*f.ParentID, depth+1) + "/" + f.Name
+			}
+		}
+		paths[id] = p
+		return p
+	}
+	for id := range byID {
+		pathOf(id, 0)
+	}
+	return paths, nil
 }
 
-// getFolderID 获取 FolderID (如果 folderName 为空则返回 nil)
+// folderPathOf 单个文件夹的完整路径，id 为 nil 表示根目录
+func (s *NoteDAO) folderPathOf(db *gorm.DB, id *uint) (string, error) {
+	if id == nil {
+		return "", nil
+	}
+	paths, err := s.folderPaths(db)
+	if err != nil {
+		return "", err
+	}
+	return paths[*id], nil
+}
+
+// getFolderID 获取 FolderID，不存在时创建 (如果 folderName 为空则返回 nil)
 func (s *NoteDAO) getFolderID(folderName string) (*uint, error) {
 	if folderName == "" {
 		return nil, nil
@@ -104,59 +225,117 @@ func (s *NoteDAO) getFolderID(folderName string) (*uint, error) {
 
 // SaveNote
 func (s *NoteDAO) SaveNote(title, folderName, content string) error {
+	_, err := s.saveNote(title, folderName, content, nil, true)
+	return err
+}
+
+// saveNote 新建或更新笔记，expected 不为 nil 时只有当前版本号等于 *expected 才会写入
+// (0 表示期望笔记尚不存在)，否则返回 *VersionConflictError
+// coalesce 见 recordRevision
+func (s *NoteDAO) saveNote(title, folderName, content string, expected *uint, coalesce bool) (*model.Note, error) {
 	folderID, err := s.getFolderID(folderName)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	
-	// 在数据库中查找笔记：需通过 Title + FolderID 唯一确定
 	var note model.Note
-	// 构建查询条件
-	query := s.DB.Where("title = ?", title)
-	if folderID == nil {
-		query = query.Where("folder_id IS NULL")
-	} else {
-		query = query.Where("folder_id = ?", *folderID)
-	}
-	
-	result := query.First(&note)
-
-	if result.Error == nil {
-		// 存在 -> 更新
-		note.Content = content
-		return s.DB.Save(&note).Error
-	} else {
-		// 不存在 -> 创建
-		newNote := model.Note{
-			Title:    title,
-			FolderID: folderID,
-			Content:  content,
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		// 在数据库中查找笔记：需通过 Title + FolderID 唯一确定
+		// 构建查询条件
+		query := tx.Where("title = ?", title)
+		if folderID == nil {
+			query = query.Where("folder_id IS NULL")
+		} else {
+			query = query.Where("folder_id = ?", *folderID)
 		}
-		// 如果 folderID == 0，GORM 会存为 0。如果我们想要 NULL，可以是指针或者 Accept 0 as "root"
-		// 这里我们假设 0 就是 root。Types里 FolderID 是 uint，默认 0。
-		// 如果我们希望 FolderID 对应 NULL，需要 FolderID *uint
-		// 简单起见：FolderID = 0 表示根目录 (因为 GORM default convention start ID at 1)
 		
-		return s.DB.Create(&newNote).Error
+		result := query.First(&note)
+
+		if result.Error == nil {
+			// 存在 -> 更新
+			if expected != nil && note.Version != *expected {
+				current := note
+				return &VersionConflictError{Current: &current}
+			}
+			based, err := s.baseRevision(tx, &note)
+			if err != nil {
+				return err
+			}
+			if based {
+				coalesce = false
+			}
+			// 带上版本号条件，防止读取之后被其他请求抢先写入
+			res := tx.Model(&note).Where("version = ?", note.Version).Updates(map[string]interface{}{
+				"content": content,
+				"version": gorm.Expr("version + 1"),
+			})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				var current model.Note
+				if err := tx.First(&current, note.ID).Error; err != nil {
+					return err
+				}
+				return &VersionConflictError{Current: &current}
+			}
+			note.Content = content
+			note.Version++
+		} else {
+			if expected != nil && *expected != 0 {
+				// 客户端基于的笔记已被删除或移走
+				return &VersionConflictError{}
+			}
+			// 不存在 -> 创建 (FolderID 为 nil 表示根目录，存为 NULL)
+			note = model.Note{
+				Title:    title,
+				FolderID: folderID,
+				Content:  content,
+				Version:  1,
+			}
+			if err := tx.Create(&note).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := s.syncTags(tx, &note); err != nil {
+			return err
+		}
+		// 记录历史版本
+		return s.recordRevision(tx, &note, coalesce)
+	})
+	if err != nil {
+		return nil, err
 	}
+	s.notify(NoteEvent{Op: "write", ID: note.ID, Title: title, Folder: cleanFolderPath(folderName), UpdatedAt: note.UpdatedAt})
+	return &note, nil
 }
 
-// GetNote
-func (s *NoteDAO) GetNote(title, folderName string) (string, error) {
+// findNote 按标题 + 文件夹名查找笔记 (不会创建文件夹)
+func (s *NoteDAO) findNote(title, folderName string) (*model.Note, error) {
 	var note model.Note
 	query := s.DB.Where("title = ?", title)
 	if folderName == "" {
 		query = query.Where("folder_id IS NULL")
 	} else {
 		// 先找文件夹ID
-		var f model.Folder
-		if err := s.DB.Where("name = ?", folderName).First(&f).Error; err != nil {
-			return "", fmt.Errorf("找不到文件夹: %s", folderName)
+		f, err := s.resolveFolder(s.DB, folderName, false)
+		if err != nil {
+			return nil, fmt.Errorf("找不到文件夹: %s", folderName)
 		}
 		query = query.Where("folder_id = ?", f.ID)
 	}
 	
 	if err := query.First(&note).Error; err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+// GetNote
+func (s *NoteDAO) GetNote(title, folderName string) (string, error) {
+	note, err := s.findNote(title, folderName)
+	if err != nil {
 		return "", err
 	}
 	return note.Content, nil
@@ -165,23 +344,28 @@ func (s *NoteDAO) GetNote(title, folderName string) (string, error) {
 // ListNotes Return summaries with Folder Names
 func (s *NoteDAO) ListNotes() ([]model.NoteSummary, error) {
 	var notes []model.Note
-	// Preload Folder 关联
-	if err := s.DB.Preload("Folder").Order("updated_at desc").Find(&notes).Error; err != nil {
+	if err := s.DB.Order("updated_at desc").Find(&notes).Error; err != nil {
 		return nil, err
 	}
+	// 文件夹名换成完整路径，并附上标签
+	return s.summarizeAll(notes)
+}
 
-	var summaries []model.NoteSummary
-	for _, n := range notes {
-		fName := ""
-		if n.Folder != nil {
-			fName = n.Folder.Name
-		}
-		summaries = append(summaries, model.NoteSummary{
-			Title:  n.Title,
-			Folder: fName,
-		})
+// summarize 生成列表项，paths 为 folderPaths 的结果
+func summarize(n *model.Note, paths map[uint]string) model.NoteSummary {
+	fName := ""
+	if n.FolderID != nil {
+		fName = paths[*n.FolderID]
+	}
+	return model.NoteSummary{
+		Title:     n.Title,
+		Folder:    fName,
+		ID:        n.ID,
+		FolderID:  n.FolderID,
+		CreatedAt: n.CreatedAt,
+		UpdatedAt: n.UpdatedAt,
+		Size:      len(n.Content),
 	}
-	return summaries, nil
 }
 
 func (s *NoteDAO) DeleteNote(title, folderName string) error {
@@ -189,14 +373,23 @@ func (s *NoteDAO) DeleteNote(title, folderName string) error {
 	if folderName == "" {
 		query = query.Where("folder_id IS NULL")
 	} else {
-		var f model.Folder
-		if err := s.DB.Where("name = ?", folderName).First(&f).Error; err != nil {
-			return nil
+		f, err := s.resolveFolder(s.DB, folderName, false)
+		if err != nil {
+			return err
 		}
 		query = query.Where("folder_id = ?", f.ID)
 	}
 	
-	return query.Unscoped().Delete(&model.Note{}).Error
+	// 移入回收站 (软删除)，历史版本保留，可从回收站恢复
+	n, err := trashNotes(query.Model(&model.Note{}), folderName)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	s.notify(NoteEvent{Op: "remove", Title: title, Folder: cleanFolderPath(folderName)})
+	return nil
 }
 
 // UpdateNoteMeta (Move or Rename Note)
@@ -218,7 +411,7 @@ func (s *NoteDAO) UpdateNoteMeta(oldTitle, oldFolder, newTitle, newFolder string
 		}
 		q.Count(&count)
 		if count > 0 {
-			return fmt.Errorf("目标位置已存在同名笔记")
+			return ErrTitleTaken
 		}
 	} else {
 		return nil
@@ -239,79 +432,253 @@ func (s *NoteDAO) UpdateNoteMeta(oldTitle, oldFolder, newTitle, newFolder string
 	// 4. Update
 	note.Title = newTitle
 	note.FolderID = newFID
-	return s.DB.Save(&note).Error
+	if err := s.DB.Save(&note).Error; err != nil {
+		return err
+	}
+	s.notify(moveEvents(note.ID, oldTitle, cleanFolderPath(oldFolder), newTitle, cleanFolderPath(newFolder))...)
+	return nil
 }
 
-// RenameFolder 修改文件夹名称
-func (s *NoteDAO) RenameFolder(oldName, newName string) error {
-	if oldName == "" || newName == "" {
+// RenameFolder 修改文件夹路径：可以只改名 ("Work/A" -> "Work/B")，
+// 也可以同时移动 ("Work/A" -> "Archive/A2")，子文件夹和笔记随之移动
+func (s *NoteDAO) RenameFolder(oldPath, newPath string) error {
+	oldPath, newPath = cleanFolderPath(oldPath), cleanFolderPath(newPath)
+	if oldPath == "" || newPath == "" {
 		return fmt.Errorf("文件夹名称不能为空")
 	}
-	
-	// 1. 检查新名称是否已被占用
-	var exists int64
-	s.DB.Model(&model.Folder{}).Where("name = ?", newName).Count(&exists)
-	if exists > 0 {
-		// 如果目标文件夹已存在，这实际上是 "Merge" 操作吗？
-		// 用户通常期望 Rename 是单纯改名。如果名字冲突，报错比较安全。
-		return fmt.Errorf("文件夹 '%s' 已存在", newName)
+	if oldPath == newPath {
+		return nil
 	}
-	
-	// 2. 查找并更新
-	result := s.DB.Model(&model.Folder{}).Where("name = ?", oldName).Update("name", newName)
-	if result.Error != nil {
-		return result.Error
+	if isSubFolder(newPath, oldPath) {
+		return ErrFolderCycle
+	}
+
+	folder, err := s.resolveFolder(s.DB, oldPath, false)
+	if err != nil {
+		return fmt.Errorf("找不到文件夹 '%s'", oldPath)
+	}
+	parentPath, newName := parentFolderPath(newPath)
+	// 目标上级不存在时创建
+	parent, err := s.ensureFolder(parentPath)
+	if err != nil {
+		return err
+	}
+	return s.moveFolder(folder, parent, newName)
+}
+
+// MoveFolder 把文件夹连同子树移动到 newParent 下，保持名称不变
+func (s *NoteDAO) MoveFolder(path, newParent string) error {
+	path, newParent = cleanFolderPath(path), cleanFolderPath(newParent)
+	if path == "" {
+		return fmt.Errorf("不能移动根目录")
+	}
+	if newParent != "" && isSubFolder(newParent, path) {
+		return ErrFolderCycle
+	}
+
+	folder, err := s.resolveFolder(s.DB, path, false)
+	if err != nil {
+		return fmt.Errorf("找不到文件夹 '%s'", path)
+	}
+	parent, err := s.resolveFolder(s.DB, newParent, false)
+	if err != nil {
+		return fmt.Errorf("找不到文件夹 '%s'", newParent)
+	}
+	return s.moveFolder(folder, parent, folder.Name)
+}
+
+// moveFolder 把 folder 改名为 name 并挂到 parent 下 (parent 为 nil 表示顶级)
+func (s *NoteDAO) moveFolder(folder, parent *model.Folder, name string) error {
+	var parentID *uint
+	if parent != nil {
+		parentID = &parent.ID
+	}
+	// 记下子树中笔记的旧路径，移动后发送通知
+	before, err := s.subtreeNotes(folder.ID)
+	if err != nil {
+		return err
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		// 沿父链向上检查，防止移动到自己的子孙下形成环
+		for p := parent; p != nil; {
+			if p.ID == folder.ID {
+				return ErrFolderCycle
+			}
+			if p.ParentID == nil {
+				break
+			}
+			var up model.Folder
+			if err := tx.First(&up, *p.ParentID).Error; err != nil {
+				return err
+			}
+			p = &up
+		}
+
+		// 同一父文件夹下名称唯一
+		var exists int64
+		q := tx.Model(&model.Folder{}).Where("name = ? AND id <> ?", name, folder.ID)
+		if parentID == nil {
+			q = q.Where("parent_id IS NULL")
+		} else {
+			q = q.Where("parent_id = ?", *parentID)
+		}
+		if err := q.Count(&exists).Error; err != nil {
+			return err
+		}
+		if exists > 0 {
+			// 如果目标文件夹已存在，这实际上是 "Merge" 操作吗？
+			// 用户通常期望 Rename 是单纯改名。如果名字冲突，报错比较安全。
+			return fmt.Errorf("文件夹 '%s' 已存在", name)
+		}
+
+		return tx.Model(folder).Updates(map[string]interface{}{
+			"name":      name,
+			"parent_id": parentID,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	paths, err := s.folderPaths(s.DB)
+	if err != nil {
+		return err
 	}
-	if result.RowsAffected == 0 {
-		return fmt.Errorf("找不到文件夹 '%s'", oldName)
+	var events []NoteEvent
+	for _, n := range before {
+		events = append(events, moveEvents(n.ID, n.Title, n.Folder, n.Title, paths[n.folderID])...)
 	}
+	s.notify(events...)
 	return nil
 }
 
-// CreateFolder 创建空文件夹
-func (s *NoteDAO) CreateFolder(name string) error {
-	if name == "" {
+// subtreeNote 文件夹子树中的一篇笔记
+type subtreeNote struct {
+	NoteEvent
+	folderID uint
+}
+
+// subtreeNotes 文件夹及其所有子文件夹中的笔记 (Folder 为当前完整路径)
+func (s *NoteDAO) subtreeNotes(folderID uint) ([]subtreeNote, error) {
+	paths, err := s.folderPaths(s.DB)
+	if err != nil {
+		return nil, err
+	}
+	root := paths[folderID]
+	var ids []uint
+	for id, p := range paths {
+		if isSubFolder(p, root) {
+			ids = append(ids, id)
+		}
+	}
+	var notes []model.Note
+	if err := s.DB.Select("id", "title", "folder_id").Where("folder_id IN ?", ids).Find(&notes).Error; err != nil {
+		return nil, err
+	}
+	out := make([]subtreeNote, 0, len(notes))
+	for _, n := range notes {
+		out = append(out, subtreeNote{
+			NoteEvent: NoteEvent{ID: n.ID, Title: n.Title, Folder: paths[*n.FolderID]},
+			folderID:  *n.FolderID,
+		})
+	}
+	return out, nil
+}
+
+// CreateFolder 创建空文件夹 (缺失的上级一并创建)
+func (s *NoteDAO) CreateFolder(path string) error {
+	if cleanFolderPath(path) == "" {
 		return fmt.Errorf("文件夹名称不能为空")
 	}
-	_, err := s.ensureFolder(name)
+	_, err := s.ensureFolder(path)
 	return err
 }
 
-// ListFolders 获取所有文件夹名称列表
+// ListFolders 获取所有文件夹的完整路径，按路径排序
 func (s *NoteDAO) ListFolders() ([]string, error) {
-	var folders []model.Folder
-	if err := s.DB.Select("name").Find(&folders).Error; err != nil {
+	paths, err := s.folderPaths(s.DB)
+	if err != nil {
 		return nil, err
 	}
 	
 	var names []string
-	for _, f := range folders {
-		names = append(names, f.Name)
+	for _, p := range paths {
+		names = append(names, p)
 	}
+	sort.Strings(names)
 	return names, nil
 }
 
-// DeleteFolder 删除文件夹及其下所有笔记
-func (s *NoteDAO) DeleteFolder(name string) error {
-	if name == "" {
+// ListFolderTree 获取文件夹树
+func (s *NoteDAO) ListFolderTree() ([]*model.FolderNode, error) {
+	paths, err := s.folderPaths(s.DB)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]uint, len(paths))
+	list := make([]string, 0, len(paths))
+	for id, p := range paths {
+		ids[p] = id
+		list = append(list, p)
+	}
+	return buildFolderTree(list, ids), nil
+}
+
+// DeleteFolder 递归删除文件夹、所有子文件夹及其下所有笔记
+func (s *NoteDAO) DeleteFolder(path string) error {
+	if cleanFolderPath(path) == "" {
 		return fmt.Errorf("不能删除根目录")
 	}
 
-	var folder model.Folder
-	if err := s.DB.Where("name = ?", name).First(&folder).Error; err != nil {
+	folder, err := s.resolveFolder(s.DB, path, false)
+	if err != nil {
 		return fmt.Errorf("文件夹不存在")
 	}
+	paths, err := s.folderPaths(s.DB)
+	if err != nil {
+		return err
+	}
 
-	// 开启事务
-	return s.DB.Transaction(func(tx *gorm.DB) error {
-		// 1. 删除文件夹下的所有笔记 (物理删除)
-		if err := tx.Unscoped().Where("folder_id = ?", folder.ID).Delete(&model.Note{}).Error; err != nil {
-			return err
+	// 收集子树，按深度从深到浅排序，先删子文件夹再删父文件夹 (外键约束)
+	root := paths[folder.ID]
+	var subtree []uint
+	for id, p := range paths {
+		if isSubFolder(p, root) {
+			subtree = append(subtree, id)
 		}
-		// 2. 删除文件夹本身
-		if err := tx.Delete(&folder).Error; err != nil {
-			return err
+	}
+	sort.Slice(subtree, func(i, j int) bool {
+		return strings.Count(paths[subtree[i]], "/") > strings.Count(paths[subtree[j]], "/")
+	})
+	removed, err := s.subtreeNotes(folder.ID)
+	if err != nil {
+		return err
+	}
+
+	// 开启事务
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		for _, id := range subtree {
+			// 1. 文件夹下的所有笔记移入回收站，恢复时会按记录的路径重建文件夹
+			if _, err := trashNotes(tx.Model(&model.Note{}).Where("folder_id = ?", id), paths[id]); err != nil {
+				return err
+			}
+			// 2. 删除文件夹本身
+			if err := tx.Delete(&model.Folder{}, id).Error; err != nil {
+				return err
+			}
 		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	events := make([]NoteEvent, 0, len(removed))
+	for _, n := range removed {
+		n.Op = "remove"
+		events = append(events, n.NoteEvent)
+	}
+	s.notify(events...)
+	return nil
 }
@@ -0,0 +1,20 @@
+package dao
+
+import (
+	"fmt"
+
+	"gorm.io/driver/postgres"
+)
+
+// NewPostgresNoteDAO 初始化 PostgreSQL 连接
+func NewPostgresNoteDAO(user, password, host, port, dbName, sslMode string) *NoteDAO {
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
+		host, user, password, dbName, port, sslMode)
+
+	s := openNoteDAO(postgres.Open(dsn))
+
+	// Postgres 唯一索引里 NULL 互不冲突 (15 之前没有 NULLS NOT DISTINCT)，
+	// 根目录笔记的 (title, folder_id) 唯一性靠部分索引保证
+	s.ensureRootTitleIndex()
+	return s
+}
@@ -0,0 +1,188 @@
+package dao
+
+import (
+	"ai-notes/internal/model"
+	"errors"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrPromptExists 同名模板已存在
+	ErrPromptExists = errors.New("同名模板已存在")
+	// ErrPromptBuiltin 内置模板不能删除
+	ErrPromptBuiltin = errors.New("内置模板不能删除，可以修改其内容")
+)
+
+// PromptStore 提示词模板库 (数据库后端支持)
+// 不支持的后端只能使用 DefaultPrompts 中的内置模板
+type PromptStore interface {
+	ListPrompts() ([]model.Prompt, error)
+	GetPrompt(name string) (*model.Prompt, error)
+	CreatePrompt(p *model.Prompt) error
+	// UpdatePrompt 修改模板内容，名称和 Builtin 不变
+	UpdatePrompt(name string, p *model.Prompt) error
+	DeletePrompt(name string) error
+}
+
+// 编译期检查：NoteDAO 支持模板库
+var _ PromptStore = (*NoteDAO)(nil)
+
+// DefaultPrompts 内置模板，数据库中缺少时在启动时写入
+func DefaultPrompts() []model.Prompt {
+	return []model.Prompt{
+		{
+			Name:        "polish",
+			Description: "润色",
+			Template:    "请直接润色以下内容，不要废话，保持 Markdown 格式：\n\n{{content}}",
+			Builtin:     true,
+		},
+		{
+			Name:        "format",
+			Description: "Markdown 格式化",
+			Template:    "请将以下内容进行 Markdown 格式化（修正层级、列表、代码块等），直接返回格式化后的结果，不要有任何开场白或解释：\n\n{{content}}",
+			Cacheable:   true,
+			Builtin:     true,
+		},
+		{
+			Name:        "summarize",
+			Description: "总结笔记 (内容过长时分段总结)",
+			Template:    "请用{{language}}总结以下笔记内容，提炼关键要点，使用 Markdown 列表，不要有任何开场白：\n\n{{content}}",
+			Builtin:     true,
+		},
+		{
+			Name:        "summarize_merge",
+			Description: "合并分段总结",
+			Template:    "以下是同一批笔记分段总结的结果，请用{{language}}把它们合并为一份完整、不重复的总结，使用 Markdown，不要有任何开场白：\n\n{{content}}",
+			Builtin:     true,
+		},
+		{
+			Name:        "title",
+			Description: "拟定标题 (返回 JSON)",
+			Template:    "请为以下笔记拟定 3 个简洁的标题，每个不超过 20 个字，使用{{language}}。只返回 JSON，格式为 {\"titles\": [\"标题1\", \"标题2\", \"标题3\"]}，不要有其他内容：\n\n{{content}}",
+			Builtin:     true,
+		},
+		{
+			Name:        "translate",
+			Description: "翻译 (保留 Markdown 结构)",
+			System:      "你是专业的翻译，只输出译文。",
+			Template:    "请把以下 Markdown 内容从{{source}}翻译为{{language}}。保持 Markdown 结构不变：标题和列表的标记、表格的 | 与分隔行、链接和图片的 URL、行内代码、HTML 标签都原样保留，只翻译其中的文字。直接返回译文，不要有任何开场白或解释，也不要用代码块包裹：\n\n{{content}}",
+			Builtin:     true,
+		},
+		{
+			Name:        "tags",
+			Description: "推荐标签 (返回 JSON)",
+			Template:    "请为以下笔记推荐 3 到 6 个标签。标签用小写，可以用 / 表示层级 (如 work/project)，不要包含空格和 # 号；已有的标签优先复用：{{tags}}。只返回 JSON，格式为 {\"tags\": [\"标签1\", \"标签2\"]}，不要有其他内容：\n\n{{content}}",
+			Builtin:     true,
+		},
+	}
+}
+
+// DefaultPrompt 按名称查找内置模板
+func DefaultPrompt(name string) (*model.Prompt, error) {
+	for _, p := range DefaultPrompts() {
+		if p.Name == name {
+			return &p, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// FindPrompt 按动作名查找模板；不支持模板库的后端只查内置模板
+func FindPrompt(s NoteStore, name string) (*model.Prompt, error) {
+	if ps, ok := s.(PromptStore); ok {
+		return ps.GetPrompt(name)
+	}
+	return DefaultPrompt(name)
+}
+
+// seedPrompts 写入缺少的内置模板；已存在的 (可能被用户修改过) 保持不变
+func (s *NoteDAO) seedPrompts() {
+	for _, p := range DefaultPrompts() {
+		var count int64
+		if err := s.DB.Model(&model.Prompt{}).Where("name = ?", p.Name).Count(&count).Error; err != nil {
+			log.Println("检查内置模板失败:", err)
+			return
+		}
+		if count > 0 {
+			continue
+		}
+		if err := s.DB.Create(&p).Error; err != nil {
+			log.Printf("写入内置模板 %s 失败: %v", p.Name, err)
+		}
+	}
+}
+
+// backfillPromptCacheable 模板表刚加上 cacheable 列时，按 DefaultPrompts 为已有的内置模板设置默认值
+func (s *NoteDAO) backfillPromptCacheable() {
+	for _, p := range DefaultPrompts() {
+		if !p.Cacheable {
+			continue
+		}
+		if err := s.DB.Model(&model.Prompt{}).Where("name = ? AND builtin = ?", p.Name, true).Update("cacheable", true).Error; err != nil {
+			log.Printf("更新内置模板 %s 失败: %v", p.Name, err)
+		}
+	}
+}
+
+// ListPrompts 所有模板，按名称排序
+func (s *NoteDAO) ListPrompts() ([]model.Prompt, error) {
+	var prompts []model.Prompt
+	if err := s.DB.Order("name").Find(&prompts).Error; err != nil {
+		return nil, err
+	}
+	return prompts, nil
+}
+
+// GetPrompt 按名称读取模板
+func (s *NoteDAO) GetPrompt(name string) (*model.Prompt, error) {
+	var p model.Prompt
+	if err := s.DB.Where("name = ?", name).First(&p).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+// CreatePrompt 新建模板
+func (s *NoteDAO) CreatePrompt(p *model.Prompt) error {
+	if _, err := s.GetPrompt(p.Name); err == nil {
+		return ErrPromptExists
+	} else if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	p.ID, p.Builtin = 0, false
+	return s.DB.Create(p).Error
+}
+
+// UpdatePrompt 修改模板内容
+func (s *NoteDAO) UpdatePrompt(name string, p *model.Prompt) error {
+	old, err := s.GetPrompt(name)
+	if err != nil {
+		return err
+	}
+	// Select 让空字符串 / nil 也能写入 (例如清空 system 或恢复默认温度)
+	return s.DB.Model(old).Select("Description", "System", "Template", "Model", "Temperature", "Cacheable").Updates(&model.Prompt{
+		Description: p.Description,
+		System:      p.System,
+		Template:    p.Template,
+		Model:       p.Model,
+		Temperature: p.Temperature,
+		Cacheable:   p.Cacheable,
+	}).Error
+}
+
+// DeletePrompt 删除模板，内置模板不能删除
+func (s *NoteDAO) DeletePrompt(name string) error {
+	p, err := s.GetPrompt(name)
+	if err != nil {
+		return err
+	}
+	if p.Builtin {
+		return ErrPromptBuiltin
+	}
+	return s.DB.Delete(p).Error
+}
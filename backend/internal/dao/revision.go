@@ -0,0 +1,255 @@
+package dao
+
+import (
+	"ai-notes/internal/diff"
+	"ai-notes/internal/model"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RevisionPolicy 历史版本的合并与保留策略
+type RevisionPolicy struct {
+	// CoalesceWindow 距上一个版本创建不到这么久的小改动直接覆盖上一个版本 (前端自动保存很频繁)
+	CoalesceWindow time.Duration
+	// KeepLast 至少保留最近 N 个版本，<= 0 表示不清理
+	KeepLast int
+	// KeepDays 最近 N 天内每天额外保留当天最后一个版本
+	KeepDays int
+}
+
+// DefaultRevisionPolicy 默认策略：5 分钟内合并，保留最近 50 个 + 30 天每日快照
+var DefaultRevisionPolicy = RevisionPolicy{
+	CoalesceWindow: 5 * time.Minute,
+	KeepLast:       50,
+	KeepDays:       30,
+}
+
+// ErrRevisionNotFound 历史版本不存在
+var ErrRevisionNotFound = errors.New("版本不存在")
+
+// RevisionStore 支持历史版本的存储后端
+type RevisionStore interface {
+	SetRevisionPolicy(p RevisionPolicy)
+	ListRevisions(title, folderName string) ([]model.RevisionSummary, error)
+	GetRevision(id uint) (*model.NoteRevision, error)
+	// PreviousRevision 同一笔记中早于 id 的最近一个版本，没有时返回 nil
+	PreviousRevision(id uint) (*model.NoteRevision, error)
+	// RestoreRevision 用历史版本覆盖当前内容 (会产生一个新版本)，返回恢复后的笔记
+	RestoreRevision(id uint) (*model.Note, error)
+	// FindRevisionByHash 按内容哈希 (见 ContentHash) 查找笔记的历史版本，找不到时返回 nil
+	FindRevisionByHash(title, folderName, hash string) (*model.NoteRevision, error)
+	// SaveNoteRevision 同 SaveNoteIfVersion，但总是记录一个独立的版本，不与上一个版本合并 (如应用 AI 修改，需要能单独撤销)
+	SaveNoteRevision(title, folderName, content string, expected uint) (*model.Note, error)
+}
+
+// 编译期检查：NoteDAO 支持历史版本
+var _ RevisionStore = (*NoteDAO)(nil)
+
+// ContentHash 笔记内容的哈希 (sha256 十六进制)，客户端可以用它指代编辑时基于的内容
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// SetRevisionPolicy 设置版本合并与保留策略
+func (s *NoteDAO) SetRevisionPolicy(p RevisionPolicy) {
+	s.Revisions = p
+}
+
+// isMinorEdit 改动行数不超过 3 行或不超过 20% 视为小改动
+// AI 润色、全选删除这类大改动总是单独成为一个版本，不会覆盖掉改动前的内容
+func isMinorEdit(oldContent, newContent string) bool {
+	edits := diff.Strings(oldContent, newContent)
+	changed := diff.ChangedLines(edits)
+	return changed <= 3 || changed*5 <= len(edits)
+}
+
+// SaveNoteRevision 带版本检查的保存，并记录一个独立的历史版本
+func (s *NoteDAO) SaveNoteRevision(title, folderName, content string, expected uint) (*model.Note, error) {
+	return s.saveNote(title, folderName, content, &expected, false)
+}
+
+// baseRevision 在修改笔记内容之前调用：笔记还没有任何历史版本 (如启用历史版本之前创建的笔记) 时，
+// 先把修改前的内容记为一个版本，否则第一次保存后原文就找不回来了。
+// 返回是否新建了版本，新建时本次修改不能合并到这个版本中
+func (s *NoteDAO) baseRevision(tx *gorm.DB, note *model.Note) (bool, error) {
+	var count int64
+	if err := tx.Model(&model.NoteRevision{}).Where("note_id = ?", note.ID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+	rev := model.NoteRevision{NoteID: note.ID, Content: note.Content, CreatedAt: note.UpdatedAt}
+	return true, tx.Create(&rev).Error
+}
+
+// recordRevision 在 tx 中为笔记当前内容记录一个版本
+// coalesce 为 true 时允许合并到上一个版本 (自动保存)，恢复等显式操作传 false
+func (s *NoteDAO) recordRevision(tx *gorm.DB, note *model.Note, coalesce bool) error {
+	var last model.NoteRevision
+	if err := tx.Where("note_id = ?", note.ID).Order("id desc").Limit(1).Find(&last).Error; err != nil {
+		return err
+	}
+	if last.ID != 0 {
+		if last.Content == note.Content {
+			return nil
+		}
+		if coalesce && time.Since(last.CreatedAt) < s.Revisions.CoalesceWindow && isMinorEdit(last.Content, note.Content) {
+			last.Content = note.Content
+			return tx.Save(&last).Error
+		}
+	}
+
+	rev := model.NoteRevision{NoteID: note.ID, Content: note.Content}
+	if err := tx.Create(&rev).Error; err != nil {
+		return err
+	}
+	return s.pruneRevisions(tx, note.ID)
+}
+
+// pruneRevisions 按保留策略清理旧版本
+// 一个版本只要满足任一条件就保留：最近 KeepLast 个之一 / KeepDays 天内当天的最后一个
+func (s *NoteDAO) pruneRevisions(tx *gorm.DB, noteID uint) error {
+	p := s.Revisions
+	if p.KeepLast <= 0 {
+		return nil
+	}
+
+	var revs []model.NoteRevision
+	if err := tx.Select("id", "created_at").Where("note_id = ?", noteID).Order("id desc").Find(&revs).Error; err != nil {
+		return err
+	}
+	if len(revs) <= p.KeepLast {
+		return nil
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -p.KeepDays)
+	seenDays := make(map[string]bool)
+	var drop []uint
+	for i, r := range revs {
+		day := r.CreatedAt.In(time.Local).Format("2006-01-02")
+		newestOfDay := !seenDays[day]
+		seenDays[day] = true
+
+		if i < p.KeepLast {
+			continue
+		}
+		if newestOfDay && p.KeepDays > 0 && r.CreatedAt.After(cutoff) {
+			continue
+		}
+		drop = append(drop, r.ID)
+	}
+	if len(drop) == 0 {
+		return nil
+	}
+	return tx.Delete(&model.NoteRevision{}, drop).Error
+}
+
+// ListRevisions 笔记的历史版本，新的在前
+func (s *NoteDAO) ListRevisions(title, folderName string) ([]model.RevisionSummary, error) {
+	note, err := s.findNote(title, folderName)
+	if err != nil {
+		return nil, err
+	}
+
+	var revs []model.NoteRevision
+	if err := s.DB.Where("note_id = ?", note.ID).Order("id desc").Find(&revs).Error; err != nil {
+		return nil, err
+	}
+	summaries := make([]model.RevisionSummary, 0, len(revs))
+	for _, r := range revs {
+		summaries = append(summaries, model.RevisionSummary{
+			ID:        r.ID,
+			NoteID:    r.NoteID,
+			CreatedAt: r.CreatedAt,
+			UpdatedAt: r.UpdatedAt,
+			Size:      len(r.Content),
+		})
+	}
+	return summaries, nil
+}
+
+// GetRevision 获取单个历史版本 (含内容)
+func (s *NoteDAO) GetRevision(id uint) (*model.NoteRevision, error) {
+	var rev model.NoteRevision
+	if err := s.DB.First(&rev, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRevisionNotFound
+		}
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// PreviousRevision 同一笔记中早于 id 的最近一个版本
+func (s *NoteDAO) PreviousRevision(id uint) (*model.NoteRevision, error) {
+	rev, err := s.GetRevision(id)
+	if err != nil {
+		return nil, err
+	}
+	var prev model.NoteRevision
+	if err := s.DB.Where("note_id = ? AND id < ?", rev.NoteID, rev.ID).Order("id desc").Limit(1).Find(&prev).Error; err != nil {
+		return nil, err
+	}
+	if prev.ID == 0 {
+		return nil, nil
+	}
+	return &prev, nil
+}
+
+// RestoreRevision 用历史版本覆盖笔记当前内容
+func (s *NoteDAO) RestoreRevision(id uint) (*model.Note, error) {
+	rev, err := s.GetRevision(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var note model.Note
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&note, rev.NoteID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound // 笔记已被彻底删除
+			}
+			return err
+		}
+		note.Content = rev.Content
+		note.Version++
+		if err := tx.Save(&note).Error; err != nil {
+			return err
+		}
+		if err := s.syncTags(tx, &note); err != nil {
+			return err
+		}
+		return s.recordRevision(tx, &note, false)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if folder, err := s.folderPathOf(s.DB, note.FolderID); err == nil {
+		s.notify(NoteEvent{Op: "write", ID: note.ID, Title: note.Title, Folder: folder, UpdatedAt: note.UpdatedAt})
+	}
+	return &note, nil
+}
+
+// FindRevisionByHash 按内容哈希查找笔记的历史版本 (从新到旧)
+func (s *NoteDAO) FindRevisionByHash(title, folderName, hash string) (*model.NoteRevision, error) {
+	note, err := s.findNote(title, folderName)
+	if err != nil {
+		return nil, err
+	}
+	var revs []model.NoteRevision
+	if err := s.DB.Where("note_id = ?", note.ID).Order("id desc").Find(&revs).Error; err != nil {
+		return nil, err
+	}
+	for i := range revs {
+		if ContentHash(revs[i].Content) == hash {
+			return &revs[i], nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,144 @@
+package dao_test
+
+import (
+	"ai-notes/internal/dao"
+	"ai-notes/internal/dao/daotest"
+	"ai-notes/internal/model"
+	"errors"
+	"testing"
+)
+
+// revisionContents 笔记所有历史版本的内容，从旧到新
+func revisionContents(t *testing.T, s *dao.NoteDAO, title, folder string) []string {
+	t.Helper()
+	note, err := s.FindNote(title, folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var revs []model.NoteRevision
+	if err := s.DB.Where("note_id = ?", note.ID).Order("id").Find(&revs).Error; err != nil {
+		t.Fatal(err)
+	}
+	contents := make([]string, len(revs))
+	for i, r := range revs {
+		contents[i] = r.Content
+	}
+	return contents
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSaveNoteVersionConflict(t *testing.T) {
+	s := daotest.New(t)
+
+	note, err := s.SaveNoteIfVersion("a", "Work", "v1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if note.Version != 1 {
+		t.Fatalf("新笔记版本号 %d，期望 1", note.Version)
+	}
+
+	tests := []struct {
+		name     string
+		expected uint
+		conflict bool
+	}{
+		{"期望不存在但已存在", 0, true},
+		{"旧版本", 5, true},
+		{"当前版本", 1, false},
+		{"同一版本再次保存", 1, true},
+	}
+	for i, tt := range tests {
+		_, err := s.SaveNoteIfVersion("a", "Work", "v"+string(rune('2'+i)), tt.expected)
+		var conflict *dao.VersionConflictError
+		if got := errors.As(err, &conflict); got != tt.conflict {
+			t.Errorf("%s: 冲突 = %v (%v)，期望 %v", tt.name, got, err, tt.conflict)
+		}
+		if tt.conflict && conflict != nil && (conflict.Current == nil || conflict.Current.Version == tt.expected) {
+			t.Errorf("%s: 冲突中应带上当前版本，得到 %+v", tt.name, conflict.Current)
+		}
+	}
+
+	// 期望的笔记已不存在
+	_, err = s.SaveNoteIfVersion("missing", "Work", "x", 3)
+	var conflict *dao.VersionConflictError
+	if !errors.As(err, &conflict) || conflict.Current != nil {
+		t.Errorf("笔记不存在时应返回不带 Current 的冲突，得到 %v", err)
+	}
+}
+
+func TestRecordRevision(t *testing.T) {
+	s := daotest.New(t)
+	long := "line1\nline2\nline3\nline4\nline5\nline6\nline7\nline8\nline9\nline10\n"
+
+	if err := s.SaveNote("a", "", long); err != nil {
+		t.Fatal(err)
+	}
+	// 窗口内的小改动合并到上一个版本
+	if err := s.SaveNote("a", "", long+"line11\n"); err != nil {
+		t.Fatal(err)
+	}
+	if got := revisionContents(t, s, "a", ""); !equalStrings(got, []string{long + "line11\n"}) {
+		t.Fatalf("小改动应合并，得到 %q", got)
+	}
+	// 内容没变不产生版本
+	if err := s.SaveNote("a", "", long+"line11\n"); err != nil {
+		t.Fatal(err)
+	}
+	// SaveNoteRevision 总是单独记录
+	note, _ := s.FindNote("a", "")
+	if _, err := s.SaveNoteRevision("a", "", long+"line12\n", note.Version); err != nil {
+		t.Fatal(err)
+	}
+	if got := revisionContents(t, s, "a", ""); !equalStrings(got, []string{long + "line11\n", long + "line12\n"}) {
+		t.Fatalf("独立版本，得到 %q", got)
+	}
+}
+
+func TestBaseRevisionForLegacyNote(t *testing.T) {
+	s := daotest.New(t)
+	// 启用历史版本之前创建的笔记：只有笔记行，没有任何版本
+	legacy := model.Note{Title: "old", Content: "original text", Version: 1}
+	if err := s.DB.Create(&legacy).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SaveNote("old", "", "original text!"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"original text", "original text!"}
+	if got := revisionContents(t, s, "old", ""); !equalStrings(got, want) {
+		t.Fatalf("第一次保存应保留原文，版本 %q，期望 %q", got, want)
+	}
+
+	// 按 ID 修改同样保留原文
+	legacy2 := model.Note{Title: "old2", Content: "first", Version: 1}
+	if err := s.DB.Create(&legacy2).Error; err != nil {
+		t.Fatal(err)
+	}
+	content := "second"
+	if _, err := s.UpdateNoteByID(legacy2.ID, model.NotePatch{Content: &content}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := revisionContents(t, s, "old2", ""); !equalStrings(got, []string{"first", "second"}) {
+		t.Fatalf("按 ID 修改，版本 %q", got)
+	}
+}
+
+func TestRestoreRevisionNotFound(t *testing.T) {
+	s := daotest.New(t)
+	if _, err := s.RestoreRevision(42); !errors.Is(err, dao.ErrRevisionNotFound) {
+		t.Fatalf("得到 %v，期望 dao.ErrRevisionNotFound", err)
+	}
+}
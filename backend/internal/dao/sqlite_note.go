@@ -0,0 +1,30 @@
+package dao
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/glebarez/sqlite" // 纯 Go 实现，无需 cgo
+)
+
+// NewSQLiteNoteDAO 初始化嵌入式 SQLite 存储，适合单用户 / 单二进制部署
+func NewSQLiteNoteDAO(path string) *NoteDAO {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			log.Fatal("创建数据目录失败:", err)
+		}
+	}
+
+	// WAL + busy_timeout 减少 "database is locked"；外键约束默认关闭，需要手动打开
+	dsn := path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)"
+	s := openNoteDAO(sqlite.Open(dsn))
+
+	// SQLite 同一时间只允许一个写者，单连接避免写锁竞争
+	if sqlDB, err := s.DB.DB(); err == nil {
+		sqlDB.SetMaxOpenConns(1)
+	}
+
+	s.ensureRootTitleIndex()
+	return s
+}
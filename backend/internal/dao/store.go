@@ -0,0 +1,43 @@
+package dao
+
+import (
+	"ai-notes/internal/model"
+	"errors"
+)
+
+var (
+	// ErrNotFound 笔记或文件夹不存在
+	ErrNotFound = errors.New("笔记或文件夹不存在")
+	// ErrTitleTaken 目标文件夹下已有同名笔记
+	ErrTitleTaken = errors.New("目标位置已存在同名笔记")
+	// ErrFolderCycle 把文件夹移动到它自己或它的子文件夹下
+	ErrFolderCycle = errors.New("不能把文件夹移动到它自己或它的子文件夹下")
+)
+
+// NoteStore 笔记存储接口
+// handler / router 只依赖这个接口，具体后端 (MySQL 等) 由 main.go 根据配置选择
+type NoteStore interface {
+	// 笔记
+	SaveNote(title, folderName, content string) error
+	GetNote(title, folderName string) (string, error)
+	ListNotes() ([]model.NoteSummary, error)
+	DeleteNote(title, folderName string) error
+	UpdateNoteMeta(oldTitle, oldFolder, newTitle, newFolder string) error
+
+	// 文件夹 (参数均为以 '/' 分隔的完整路径)
+	// RenameFolder 把整个子树改到 newPath 下 (可同时改名和移动)
+	RenameFolder(oldPath, newPath string) error
+	// MoveFolder 把文件夹连同子树移动到 newParent 下，newParent 为空表示顶级
+	MoveFolder(path, newParent string) error
+	// CreateFolder 创建文件夹，缺失的上级文件夹一并创建
+	CreateFolder(path string) error
+	// ListFolders 所有文件夹的完整路径
+	ListFolders() ([]string, error)
+	// ListFolderTree 文件夹树
+	ListFolderTree() ([]*model.FolderNode, error)
+	// DeleteFolder 递归删除文件夹、子文件夹及其中的笔记
+	DeleteFolder(path string) error
+}
+
+// 编译期检查：NoteDAO 必须实现 NoteStore
+var _ NoteStore = (*NoteDAO)(nil)
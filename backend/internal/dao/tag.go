@@ -0,0 +1,503 @@
+package dao
+
+import (
+	"ai-notes/internal/model"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// TagStore 支持标签的存储后端
+// 标签不单独编辑，而是保存笔记时从内容中提取：正文中的 #hashtag 以及 YAML frontmatter 的 tags:
+// 标签可以嵌套 ("work/projectA")，按 "work" 过滤 / 重命名时包含其下级标签
+type TagStore interface {
+	// ListTags 所有标签及使用次数，使用最多的在前
+	ListTags() ([]model.TagCount, error)
+	// RenameTag 重命名标签并改写所有相关笔记的内容，newName 已存在时即为合并；返回被改写的笔记数
+	RenameTag(oldName, newName string) (int64, error)
+	// ListNotesByTag 带有该标签 (或其下级标签) 的笔记
+	ListNotesByTag(tag string) ([]model.NoteSummary, error)
+}
+
+// 编译期检查：NoteDAO 支持标签
+var _ TagStore = (*NoteDAO)(nil)
+
+// ==============================
+// 标签提取 / 改写
+// ==============================
+
+// tagSpan 内容中一个标签文本的位置，content[start:end] 不含 '#' 和引号
+type tagSpan struct {
+	start, end int
+}
+
+func isTagRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '/'
+}
+
+// normalizeTag 统一标签格式：去掉 '#'、首尾的 '/'，转小写
+func normalizeTag(tag string) string {
+	tag = strings.TrimSpace(tag)
+	tag = strings.TrimLeft(tag, "#")
+	tag = strings.Trim(tag, "/")
+	return strings.ToLower(tag)
+}
+
+// validTag 标签只能由字母、数字、'_'、'-'、'/' 组成，且不能是纯数字 (#123 通常是编号而不是标签)
+func validTag(tag string) bool {
+	if tag == "" || len(tag) > 100 {
+		return false
+	}
+	hasNonDigit := false
+	for _, r := range tag {
+		if !isTagRune(r) {
+			return false
+		}
+		if !unicode.IsDigit(r) {
+			hasNonDigit = true
+		}
+	}
+	return hasNonDigit
+}
+
+// CleanTag 规范化外部给出的标签名 (如 AI 推荐的标签)，不合法时返回 false
+func CleanTag(tag string) (string, bool) {
+	tag = normalizeTag(tag)
+	return tag, validTag(tag)
+}
+
+// tagMatches tag 等于 name 或是 name 的下级标签
+func tagMatches(tag, name string) bool {
+	return tag == name || strings.HasPrefix(tag, name+"/")
+}
+
+// splitFrontmatter 返回 frontmatter 结束位置 (不含 frontmatter 时为 0)
+// frontmatter 必须从第一行 "---" 开始，到 "---" 或 "..." 行结束
+func splitFrontmatter(content string) int {
+	if !strings.HasPrefix(content, "---\n") && !strings.HasPrefix(content, "---\r\n") {
+		return 0
+	}
+	pos := strings.IndexByte(content, '\n') + 1
+	for pos < len(content) {
+		end := strings.IndexByte(content[pos:], '\n')
+		line := content[pos:]
+		next := len(content)
+		if end >= 0 {
+			line = content[pos : pos+end]
+			next = pos + end + 1
+		}
+		if l := strings.TrimRight(line, " \r"); l == "---" || l == "..." {
+			return next
+		}
+		pos = next
+	}
+	return 0 // 没有结束标记，不当作 frontmatter
+}
+
+// frontmatterTagSpans 解析 frontmatter 中的 tags: 字段，支持三种写法：
+//
+//	tags: [a, b]      tags: a, b      tags:
+//	                                    - a
+//	                                    - b
+func frontmatterTagSpans(content string, end int) []tagSpan {
+	var spans []tagSpan
+	// item 去掉空白、引号和 '#' 后记录位置
+	item := func(start, stop int) {
+		for start < stop && strings.ContainsRune(" \t\r\"'#", rune(content[start])) {
+			start++
+		}
+		for stop > start && strings.ContainsRune(" \t\r\"'", rune(content[stop-1])) {
+			stop--
+		}
+		if start < stop {
+			spans = append(spans, tagSpan{start, stop})
+		}
+	}
+	// list 按分隔符切分 content[start:stop]
+	list := func(start, stop int, seps string) {
+		from := start
+		for i := start; i <= stop; i++ {
+			if i == stop || strings.IndexByte(seps, content[i]) >= 0 {
+				item(from, i)
+				from = i + 1
+			}
+		}
+	}
+
+	inTags := false
+	for pos := strings.IndexByte(content, '\n') + 1; pos < end; {
+		lineEnd := strings.IndexByte(content[pos:end], '\n')
+		next := end
+		if lineEnd < 0 {
+			lineEnd = end
+		} else {
+			lineEnd += pos
+			next = lineEnd + 1
+		}
+		line := content[pos:lineEnd]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case inTags && strings.HasPrefix(trimmed, "- "):
+			start := pos + strings.Index(line, "- ") + 2
+			item(start, lineEnd)
+		case inTags && trimmed == "":
+		case strings.HasPrefix(strings.ToLower(line), "tags:"):
+			inTags = false
+			start, stop := pos+len("tags:"), lineEnd
+			value := strings.TrimSpace(content[start:stop])
+			switch {
+			case value == "":
+				inTags = true // 值在后续的列表项中
+			case strings.HasPrefix(value, "["):
+				open := strings.IndexByte(content[start:stop], '[') + start
+				close := strings.LastIndexByte(content[start:stop], ']')
+				if close < 0 {
+					close = stop
+				} else {
+					close += start
+				}
+				list(open+1, close, ",")
+			default:
+				list(start, stop, ", ")
+			}
+		default:
+			inTags = false
+		}
+		pos = next
+	}
+	return spans
+}
+
+// hashtagSpans 正文中的 #hashtag，跳过代码块和行内代码
+// '#' 前必须是行首或空白 / 括号，"# 标题" (后面跟空格) 不算标签
+func hashtagSpans(content string, from int) []tagSpan {
+	var spans []tagSpan
+	inFence := false
+	fence := ""
+	for pos := from; pos < len(content); {
+		lineEnd := strings.IndexByte(content[pos:], '\n')
+		next := len(content)
+		if lineEnd < 0 {
+			lineEnd = len(content)
+		} else {
+			lineEnd += pos
+			next = lineEnd + 1
+		}
+		line := content[pos:lineEnd]
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			if !inFence {
+				inFence, fence = true, trimmed[:3]
+			} else if strings.HasPrefix(trimmed, fence) {
+				inFence = false
+			}
+			pos = next
+			continue
+		}
+		if inFence {
+			pos = next
+			continue
+		}
+
+		inCode := false
+		prev := ' '
+		for i := 0; i < len(line); {
+			r, size := utf8.DecodeRuneInString(line[i:])
+			switch {
+			case r == '`':
+				inCode = !inCode
+			case r == '#' && !inCode && (unicode.IsSpace(prev) || strings.ContainsRune("([{", prev)):
+				j := i + 1
+				for j < len(line) {
+					r2, size2 := utf8.DecodeRuneInString(line[j:])
+					if !isTagRune(r2) {
+						break
+					}
+					j += size2
+				}
+				// 结尾的 '/'、'-' 一般是标点而不是标签的一部分
+				for j > i+1 && (line[j-1] == '/' || line[j-1] == '-') {
+					j--
+				}
+				if validTag(line[i+1 : j]) {
+					spans = append(spans, tagSpan{pos + i + 1, pos + j})
+				}
+				if j > i+1 {
+					prev = 'x'
+					i = j
+					continue
+				}
+			}
+			prev = r
+			i += size
+		}
+		pos = next
+	}
+	return spans
+}
+
+// allTagSpans frontmatter 和正文中的所有标签位置，按出现顺序
+func allTagSpans(content string) []tagSpan {
+	end := splitFrontmatter(content)
+	spans := frontmatterTagSpans(content, end)
+	return append(spans, hashtagSpans(content, end)...)
+}
+
+// ExtractTags 提取内容中的标签 (已规范化、去重，按出现顺序)
+func ExtractTags(content string) []string {
+	var tags []string
+	seen := map[string]bool{}
+	for _, sp := range allTagSpans(content) {
+		tag := normalizeTag(content[sp.start:sp.end])
+		if !validTag(tag) || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// rewriteTag 把内容中的 oldName 标签 (及其下级标签) 改为 newName，其余内容保持不变
+func rewriteTag(content, oldName, newName string) string {
+	spans := allTagSpans(content)
+	var b strings.Builder
+	last := 0
+	for _, sp := range spans {
+		raw := content[sp.start:sp.end]
+		tag := normalizeTag(raw)
+		if !tagMatches(tag, oldName) {
+			continue
+		}
+		// 下级部分保留原来的大小写
+		suffix := tag[len(oldName):]
+		if len(raw) == len(tag) {
+			suffix = raw[len(oldName):]
+		}
+		b.WriteString(content[last:sp.start])
+		b.WriteString(newName + suffix)
+		last = sp.end
+	}
+	if last == 0 {
+		return content
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
+
+// ==============================
+// 数据库
+// ==============================
+
+// syncTags 按笔记当前内容重建它的标签关联，需在保存笔记的事务中调用
+func (s *NoteDAO) syncTags(tx *gorm.DB, note *model.Note) error {
+	if err := tx.Exec("DELETE FROM note_tags WHERE note_id = ?", note.ID).Error; err != nil {
+		return err
+	}
+	for _, name := range ExtractTags(note.Content) {
+		var tag model.Tag
+		if err := tx.Where("name = ?", name).FirstOrCreate(&tag, model.Tag{Name: name}).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("INSERT INTO note_tags (note_id, tag_id) VALUES (?, ?)", note.ID, tag.ID).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// noteTagNames 笔记 ID -> 标签名 (按名称排序)
+func (s *NoteDAO) noteTagNames(db *gorm.DB) (map[uint][]string, error) {
+	var rows []struct {
+		NoteID uint
+		Name   string
+	}
+	err := db.Table("note_tags").
+		Select("note_tags.note_id, tags.name").
+		Joins("JOIN tags ON tags.id = note_tags.tag_id").
+		Order("tags.name").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[uint][]string)
+	for _, r := range rows {
+		tags[r.NoteID] = append(tags[r.NoteID], r.Name)
+	}
+	return tags, nil
+}
+
+// backfillTags 标签表为空时 (刚升级到支持标签的版本) 为已有笔记提取一次标签
+func (s *NoteDAO) backfillTags() {
+	var count int64
+	if err := s.DB.Model(&model.Tag{}).Count(&count).Error; err != nil || count > 0 {
+		return
+	}
+	var notes []model.Note
+	err := s.DB.Unscoped().FindInBatches(&notes, 200, func(tx *gorm.DB, batch int) error {
+		for i := range notes {
+			if len(ExtractTags(notes[i].Content)) == 0 {
+				continue
+			}
+			if err := s.syncTags(s.DB, &notes[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		log.Println("为已有笔记提取标签失败:", err)
+	}
+}
+
+// ListTags 所有标签及使用次数 (不含回收站中的笔记)
+func (s *NoteDAO) ListTags() ([]model.TagCount, error) {
+	tags := []model.TagCount{}
+	err := s.DB.Table("tags").
+		Select("tags.name AS name, COUNT(*) AS count").
+		Joins("JOIN note_tags ON note_tags.tag_id = tags.id").
+		Joins("JOIN notes ON notes.id = note_tags.note_id AND notes.deleted_at IS NULL").
+		Group("tags.name").
+		Order("count desc, name").
+		Scan(&tags).Error
+	return tags, err
+}
+
+// matchingTagIDs 名称为 name 或其下级标签的 ID
+func matchingTagIDs(db *gorm.DB, name string) ([]uint, error) {
+	var ids []uint
+	err := db.Model(&model.Tag{}).Where("name = ? OR name LIKE ? ESCAPE '!'", name, escapeLike(name)+"/%").Pluck("id", &ids).Error
+	return ids, err
+}
+
+// escapeLike 转义 LIKE 模式中的通配符 (标签名里允许 '_')
+// 各数据库对反斜杠的处理不同，统一用 '!' 作转义字符
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+// ListNotesByTag 带有该标签 (或其下级标签) 的笔记，最近修改的在前
+func (s *NoteDAO) ListNotesByTag(tag string) ([]model.NoteSummary, error) {
+	summaries := []model.NoteSummary{}
+	tag = normalizeTag(tag)
+	ids, err := matchingTagIDs(s.DB, tag)
+	if err != nil || len(ids) == 0 {
+		return summaries, err
+	}
+
+	var notes []model.Note
+	err = s.DB.Where("id IN (?)", s.DB.Table("note_tags").Select("note_id").Where("tag_id IN ?", ids)).
+		Order("updated_at desc").Find(&notes).Error
+	if err != nil {
+		return nil, err
+	}
+	return s.summarizeAll(notes)
+}
+
+// summarizeAll 批量生成列表项 (带完整文件夹路径和标签)
+func (s *NoteDAO) summarizeAll(notes []model.Note) ([]model.NoteSummary, error) {
+	paths, err := s.folderPaths(s.DB)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := s.noteTagNames(s.DB)
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]model.NoteSummary, 0, len(notes))
+	for i := range notes {
+		sum := summarize(&notes[i], paths)
+		sum.Tags = tags[notes[i].ID]
+		summaries = append(summaries, sum)
+	}
+	return summaries, nil
+}
+
+// RenameTag 重命名 / 合并标签：改写所有相关笔记 (包括回收站中的) 的内容，每篇笔记记录一个历史版本
+func (s *NoteDAO) RenameTag(oldName, newName string) (int64, error) {
+	oldName, newName = normalizeTag(oldName), normalizeTag(newName)
+	if !validTag(newName) {
+		return 0, fmt.Errorf("非法的标签名: %s", newName)
+	}
+	if oldName == newName {
+		return 0, nil
+	}
+	if tagMatches(newName, oldName) {
+		return 0, fmt.Errorf("不能把标签重命名为它自己的下级标签")
+	}
+
+	var changed int64
+	var live []model.Note // 内容被改写的非回收站笔记，提交后通知
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		ids, err := matchingTagIDs(tx, oldName)
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return ErrNotFound
+		}
+
+		var notes []model.Note
+		err = tx.Unscoped().
+			Where("id IN (?)", tx.Table("note_tags").Select("note_id").Where("tag_id IN ?", ids)).
+			Find(&notes).Error
+		if err != nil {
+			return err
+		}
+
+		for i := range notes {
+			note := &notes[i]
+			content := rewriteTag(note.Content, oldName, newName)
+			if content == note.Content {
+				continue
+			}
+			if _, err := s.baseRevision(tx, note); err != nil {
+				return err
+			}
+			err := tx.Unscoped().Model(note).Updates(map[string]interface{}{
+				"content": content,
+				"version": gorm.Expr("version + 1"),
+			}).Error
+			if err != nil {
+				return err
+			}
+			note.Content = content
+			note.Version++
+			if err := s.syncTags(tx, note); err != nil {
+				return err
+			}
+			if err := s.recordRevision(tx, note, false); err != nil {
+				return err
+			}
+			if !note.DeletedAt.Valid {
+				live = append(live, *note)
+			}
+			changed++
+		}
+
+		// 清理不再被任何笔记使用的标签
+		return tx.Where("id NOT IN (?)", tx.Table("note_tags").Select("tag_id")).Delete(&model.Tag{}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if paths, err := s.folderPaths(s.DB); err == nil {
+		events := make([]NoteEvent, 0, len(live))
+		for _, n := range live {
+			folder := ""
+			if n.FolderID != nil {
+				folder = paths[*n.FolderID]
+			}
+			events = append(events, NoteEvent{Op: "write", ID: n.ID, Title: n.Title, Folder: folder})
+		}
+		s.notify(events...)
+	}
+	return changed, nil
+}
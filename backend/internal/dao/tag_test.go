@@ -0,0 +1,108 @@
+package dao_test
+
+import (
+	"ai-notes/internal/dao"
+	"ai-notes/internal/dao/daotest"
+	"fmt"
+	"testing"
+)
+
+func TestExtractTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"正文", "学习 #Go 和 #rust", "[go rust]"},
+		{"去重", "#go #Go #go", "[go]"},
+		{"嵌套标签", "#work/projectA/", "[work/projecta]"},
+		{"标题不是标签", "# 标题\n## 二级", "[]"},
+		{"纯数字是编号", "见 #123", "[]"},
+		{"必须以空白或括号开头", "a#b (#c) url#frag", "[c]"},
+		{"代码块和行内代码", "```\n#code\n```\n`#inline` #real", "[real]"},
+		{"行内列表", "---\ntags: [a, \"b\"]\n---\n#c", "[a b c]"},
+		{"逗号分隔", "---\ntitle: x\ntags: a, #b\n---\n", "[a b]"},
+		{"YAML 列表", "---\ntags:\n  - a\n  - 'b/c'\nother: d\n  - e\n---\n", "[a b/c]"},
+		{"未闭合的 frontmatter", "---\ntags: [a]\n#b", "[b]"},
+		{"中文标签", "#读书笔记 #读书笔记/2024", "[读书笔记 读书笔记/2024]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(dao.ExtractTags(tt.content)); got != tt.want {
+			t.Errorf("%s: 得到 %s，期望 %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRewriteTag(t *testing.T) {
+	tests := []struct {
+		content, old, new, want string
+	}{
+		{"#work #Work/ProjA #workshop", "work", "job", "#job #job/ProjA #workshop"},
+		{"---\ntags: [work, other]\n---\n#work", "work", "job", "---\ntags: [job, other]\n---\n#job"},
+		{"`#work` #other", "work", "job", "`#work` #other"},
+	}
+	for _, tt := range tests {
+		if got := dao.RewriteTag(tt.content, tt.old, tt.new); got != tt.want {
+			t.Errorf("dao.RewriteTag(%q, %s, %s) = %q，期望 %q", tt.content, tt.old, tt.new, got, tt.want)
+		}
+	}
+}
+
+func TestRenameTag(t *testing.T) {
+	s := daotest.New(t)
+	for _, n := range []struct{ title, content string }{
+		{"a", "#work 和 #work/proj"},
+		{"b", "#work/proj #home"},
+		{"c", "#home"},
+	} {
+		if err := s.SaveNote(n.title, "", n.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tagList := func() string {
+		tags, err := s.ListTags()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fmt.Sprint(tags)
+	}
+	if got := tagList(); got != "[{home 2} {work/proj 2} {work 1}]" {
+		t.Fatalf("标签 %s", got)
+	}
+
+	for _, bad := range [][2]string{{"work", "work/sub"}, {"work", "a b"}} {
+		if _, err := s.RenameTag(bad[0], bad[1]); err == nil {
+			t.Errorf("RenameTag(%q, %q) 应报错", bad[0], bad[1])
+		}
+	}
+	if _, err := s.RenameTag("missing", "x"); err != dao.ErrNotFound {
+		t.Errorf("不存在的标签应返回 dao.ErrNotFound，得到 %v", err)
+	}
+
+	// 重命名包括下级标签
+	n, err := s.RenameTag("Work", "job")
+	if err != nil || n != 2 {
+		t.Fatalf("RenameTag = %d, %v，期望改写 2 篇", n, err)
+	}
+	if content, _ := s.GetNote("a", ""); content != "#job 和 #job/proj" {
+		t.Errorf("笔记内容 %q", content)
+	}
+	if got := tagList(); got != "[{home 2} {job/proj 2} {job 1}]" {
+		t.Errorf("重命名后标签 %s", got)
+	}
+	if got := revisionContents(t, s, "b", ""); !equalStrings(got, []string{"#work/proj #home", "#job/proj #home"}) {
+		t.Errorf("改写应记录历史版本，得到 %q", got)
+	}
+
+	// 合并到已有标签，旧标签被清理
+	if n, err := s.RenameTag("job/proj", "home"); err != nil || n != 2 {
+		t.Fatalf("合并 = %d, %v", n, err)
+	}
+	if got := tagList(); got != "[{home 3} {job 1}]" {
+		t.Errorf("合并后标签 %s", got)
+	}
+	notes, err := s.ListNotesByTag("home")
+	if err != nil || len(notes) != 3 {
+		t.Errorf("ListNotesByTag = %d 篇, %v", len(notes), err)
+	}
+}
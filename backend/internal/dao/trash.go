@@ -0,0 +1,189 @@
+package dao
+
+import (
+	"ai-notes/internal/model"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TrashStore 支持回收站的存储后端
+type TrashStore interface {
+	ListTrash() ([]model.TrashItem, error)
+	// RestoreTrash 恢复笔记 (原文件夹已删除时重建)，同名冲突时自动改名，返回恢复后的位置
+	RestoreTrash(id uint) (*model.TrashItem, error)
+	// PurgeTrash 彻底删除回收站中的笔记，ids 为空表示清空回收站
+	PurgeTrash(ids []uint) (int64, error)
+	// PurgeTrashBefore 彻底删除在 t 之前移入回收站的笔记
+	PurgeTrashBefore(t time.Time) (int64, error)
+}
+
+// 编译期检查：NoteDAO 支持回收站
+var _ TrashStore = (*NoteDAO)(nil)
+
+// trashNotes 把 q 命中的笔记移入回收站
+// folder_id 置空：回收站里的笔记不再占用原文件夹下的 (title, folder_id) 唯一索引，
+// 原文件夹也可以被直接删除；原文件夹名记在 trash_folder 中用于恢复
+// 返回移入回收站的笔记数
+func trashNotes(q *gorm.DB, folderName string) (int64, error) {
+	result := q.Updates(map[string]interface{}{
+		"folder_id":    nil,
+		"trash_folder": folderName,
+		"deleted_at":   time.Now(),
+	})
+	return result.RowsAffected, result.Error
+}
+
+// ListTrash 回收站列表，最近删除的在前
+func (s *NoteDAO) ListTrash() ([]model.TrashItem, error) {
+	var notes []model.Note
+	if err := s.DB.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at desc").Find(&notes).Error; err != nil {
+		return nil, err
+	}
+	items := make([]model.TrashItem, 0, len(notes))
+	for _, n := range notes {
+		items = append(items, model.TrashItem{
+			ID:        n.ID,
+			Title:     n.Title,
+			Folder:    n.TrashFolder,
+			DeletedAt: n.DeletedAt.Time,
+			Size:      len(n.Content),
+		})
+	}
+	return items, nil
+}
+
+// titleTaken 目标文件夹下是否已有同名笔记 (不含回收站)
+func titleTaken(tx *gorm.DB, title string, folderID *uint) (bool, error) {
+	var count int64
+	q := tx.Model(&model.Note{}).Where("title = ?", title)
+	if folderID == nil {
+		q = q.Where("folder_id IS NULL")
+	} else {
+		q = q.Where("folder_id = ?", *folderID)
+	}
+	if err := q.Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// RestoreTrash 从回收站恢复笔记，回收站中没有该笔记时返回 ErrNotFound
+func (s *NoteDAO) RestoreTrash(id uint) (*model.TrashItem, error) {
+	var note model.Note
+	if err := s.DB.Unscoped().Where("deleted_at IS NOT NULL").First(&note, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	// 原文件夹已被删除时重新创建
+	folderName := note.TrashFolder
+	folderID, err := s.getFolderID(folderName)
+	if err != nil {
+		return nil, err
+	}
+
+	title := note.Title
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		// 原位置已有同名笔记时改名为 "标题 (恢复)"、"标题 (恢复 2)" ...
+		for i := 1; ; i++ {
+			taken, err := titleTaken(tx, title, folderID)
+			if err != nil {
+				return err
+			}
+			if !taken {
+				break
+			}
+			if i == 1 {
+				title = fmt.Sprintf("%s (恢复)", note.Title)
+			} else {
+				title = fmt.Sprintf("%s (恢复 %d)", note.Title, i)
+			}
+		}
+
+		return tx.Unscoped().Model(&note).Updates(map[string]interface{}{
+			"deleted_at":   nil,
+			"folder_id":    folderID,
+			"title":        title,
+			"trash_folder": "",
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.notify(NoteEvent{Op: "write", ID: note.ID, Title: title, Folder: cleanFolderPath(folderName)})
+	return &model.TrashItem{ID: note.ID, Title: title, Folder: folderName, Size: len(note.Content)}, nil
+}
+
+// PurgeTrash 彻底删除回收站中的笔记及其历史版本、标签关联和 AI 对话
+func (s *NoteDAO) PurgeTrash(ids []uint) (int64, error) {
+	return s.purgeTrash(func(q *gorm.DB) *gorm.DB {
+		if len(ids) > 0 {
+			q = q.Where("id IN ?", ids)
+		}
+		return q
+	})
+}
+
+// PurgeTrashBefore 彻底删除 t 之前移入回收站的笔记
+func (s *NoteDAO) PurgeTrashBefore(t time.Time) (int64, error) {
+	return s.purgeTrash(func(q *gorm.DB) *gorm.DB {
+		return q.Where("deleted_at < ?", t)
+	})
+}
+
+func (s *NoteDAO) purgeTrash(scope func(*gorm.DB) *gorm.DB) (int64, error) {
+	var purged int64
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var ids []uint
+		q := tx.Unscoped().Model(&model.Note{}).Where("deleted_at IS NOT NULL")
+		if err := scope(q).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := tx.Where("note_id IN ?", ids).Delete(&model.NoteRevision{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM note_tags WHERE note_id IN ?", ids).Error; err != nil {
+			return err
+		}
+		sessions := tx.Model(&model.ChatSession{}).Select("id").Where("note_id IN ?", ids)
+		if err := tx.Where("session_id IN (?)", sessions).Delete(&model.ChatMessage{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("note_id IN ?", ids).Delete(&model.ChatSession{}).Error; err != nil {
+			return err
+		}
+		result := tx.Unscoped().Delete(&model.Note{}, ids)
+		purged = result.RowsAffected
+		return result.Error
+	})
+	return purged, err
+}
+
+// StartTrashPurger 后台定期清理回收站中超过 maxAge 的笔记，maxAge <= 0 时不启动
+func StartTrashPurger(ts TrashStore, maxAge time.Duration) {
+	if maxAge <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			n, err := ts.PurgeTrashBefore(time.Now().Add(-maxAge))
+			if err != nil {
+				log.Println("清理回收站失败:", err)
+			} else if n > 0 {
+				log.Printf("回收站自动清理了 %d 篇笔记", n)
+			}
+			<-ticker.C
+		}
+	}()
+}
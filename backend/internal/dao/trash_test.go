@@ -0,0 +1,45 @@
+package dao_test
+
+import (
+	"ai-notes/internal/dao"
+	"ai-notes/internal/dao/daotest"
+	"errors"
+	"testing"
+)
+
+func TestRestoreTrash(t *testing.T) {
+	s := daotest.New(t)
+	if err := s.SaveNote("a", "", "a"); err != nil {
+		t.Fatal(err)
+	}
+	note, err := s.FindNote("a", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteNote("a", ""); err != nil {
+		t.Fatal(err)
+	}
+	// 原位置已有同名笔记时改名
+	if err := s.SaveNote("a", "", "new"); err != nil {
+		t.Fatal(err)
+	}
+	item, err := s.RestoreTrash(note.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Title != "a (恢复)" {
+		t.Errorf("恢复后的标题 %q，期望 %q", item.Title, "a (恢复)")
+	}
+
+	// 已恢复的笔记不在回收站中
+	if _, err := s.RestoreTrash(note.ID); !errors.Is(err, dao.ErrNotFound) {
+		t.Errorf("回收站中没有的笔记应返回 dao.ErrNotFound，得到 %v", err)
+	}
+
+	// 其他数据库错误原样返回
+	db, _ := s.DB.DB()
+	db.Close()
+	if _, err := s.RestoreTrash(note.ID); err == nil || errors.Is(err, dao.ErrNotFound) {
+		t.Errorf("数据库出错时不应返回 dao.ErrNotFound，得到 %v", err)
+	}
+}
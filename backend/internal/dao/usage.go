@@ -0,0 +1,56 @@
+package dao
+
+import (
+	"ai-notes/internal/model"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// UsageStore 持久化 AI 用量记录 (数据库后端支持)
+// 不支持的后端只在内存中统计当天用量，用于预算控制，重启后清零
+type UsageStore interface {
+	RecordUsage(u *model.AIUsage) error
+	// SumUsage 在数据库中按时间段和动作汇总用量：bounds 为升序的各时间段起点，
+	// 第 i 段为 [bounds[i], bounds[i+1])，最后一段不设上限；早于 bounds[0] 的记录不计入
+	SumUsage(bounds []time.Time) ([]model.UsageTotal, error)
+}
+
+// 编译期检查：NoteDAO 支持用量记录
+var _ UsageStore = (*NoteDAO)(nil)
+
+// RecordUsage 写入一条用量记录
+func (s *NoteDAO) RecordUsage(u *model.AIUsage) error {
+	return s.DB.Create(u).Error
+}
+
+// SumUsage 按时间段和动作汇总用量
+// 时间段的边界由调用方按本地时区算好，以参数传入 CASE 表达式分组，不依赖各数据库的日期函数和时区设置
+func (s *NoteDAO) SumUsage(bounds []time.Time) ([]model.UsageTotal, error) {
+	totals := []model.UsageTotal{}
+	if len(bounds) == 0 {
+		return totals, nil
+	}
+
+	bucket := "0"
+	args := make([]interface{}, 0, len(bounds)-1)
+	if len(bounds) > 1 {
+		var b strings.Builder
+		b.WriteString("CASE")
+		for i, t := range bounds[1:] {
+			fmt.Fprintf(&b, " WHEN created_at < ? THEN %d", i)
+			args = append(args, t)
+		}
+		fmt.Fprintf(&b, " ELSE %d END", len(bounds)-1)
+		bucket = b.String()
+	}
+	err := s.DB.Model(&model.AIUsage{}).
+		Select(bucket+` AS bucket, action, COUNT(*) AS requests,
+			SUM(CASE WHEN status = 'error' THEN 1 ELSE 0 END) AS errors,
+			SUM(input_tokens) AS input_tokens, SUM(output_tokens) AS output_tokens`, args...).
+		Where("created_at >= ?", bounds[0]).
+		Group("1, 2"). // 按位置引用，避免在 GROUP BY 中重复带参数的表达式
+		Order("1, 2").
+		Scan(&totals).Error
+	return totals, err
+}
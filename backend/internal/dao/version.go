@@ -0,0 +1,41 @@
+package dao
+
+import (
+	"ai-notes/internal/model"
+	"fmt"
+)
+
+// VersionedStore 支持乐观并发控制的存储后端
+type VersionedStore interface {
+	// GetNoteVersioned 读取笔记及其版本号
+	GetNoteVersioned(title, folderName string) (*model.Note, error)
+	// SaveNoteIfVersion 仅当笔记当前版本号等于 expected 时保存 (0 表示期望笔记不存在)，
+	// 版本不匹配时返回 *VersionConflictError
+	SaveNoteIfVersion(title, folderName, content string, expected uint) (*model.Note, error)
+}
+
+// 编译期检查：NoteDAO 支持版本号
+var _ VersionedStore = (*NoteDAO)(nil)
+
+// VersionConflictError 保存时版本号不匹配
+type VersionConflictError struct {
+	// Current 服务器上的当前笔记；笔记已被删除或移走时为 nil
+	Current *model.Note
+}
+
+func (e *VersionConflictError) Error() string {
+	if e.Current == nil {
+		return "笔记已被删除或移动"
+	}
+	return fmt.Sprintf("笔记已被其他客户端修改 (当前版本 %d)", e.Current.Version)
+}
+
+// GetNoteVersioned 读取笔记及其版本号
+func (s *NoteDAO) GetNoteVersioned(title, folderName string) (*model.Note, error) {
+	return s.findNote(title, folderName)
+}
+
+// SaveNoteIfVersion 带版本检查的保存
+func (s *NoteDAO) SaveNoteIfVersion(title, folderName, content string, expected uint) (*model.Note, error) {
+	return s.saveNote(title, folderName, content, &expected, true)
+}
@@ -0,0 +1,269 @@
+// Package diff 行级文本比较 (Myers 算法) 与 unified diff 输出
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Op 编辑操作类型
+type Op int
+
+const (
+	Equal  Op = iota // 两边相同
+	Insert           // 仅出现在新文本
+	Delete           // 仅出现在旧文本
+)
+
+// Edit 一行文本上的编辑操作
+type Edit struct {
+	Op   Op
+	Text string
+}
+
+// maxEditDistance Myers 搜索的最大编辑距离
+// 超过后不再寻找最短编辑脚本，直接按 "整段删除 + 整段插入" 处理，避免极端输入占用过多内存
+const maxEditDistance = 2000
+
+// SplitLines 按 '\n' 切分文本，strings.Join(lines, "\n") 可以还原原文
+func SplitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// Lines 计算 a -> b 的行级编辑脚本
+func Lines(a, b []string) []Edit {
+	// 先去掉公共前后缀，Myers 只处理中间真正变化的部分
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	edits := make([]Edit, 0, len(a)+len(b)-pre-suf)
+	for _, line := range a[:pre] {
+		edits = append(edits, Edit{Op: Equal, Text: line})
+	}
+	edits = append(edits, myers(a[pre:len(a)-suf], b[pre:len(b)-suf])...)
+	for _, line := range a[len(a)-suf:] {
+		edits = append(edits, Edit{Op: Equal, Text: line})
+	}
+	return edits
+}
+
+// Strings 计算两段文本的行级编辑脚本
+func Strings(a, b string) []Edit {
+	return Lines(SplitLines(a), SplitLines(b))
+}
+
+// ChangedLines 编辑脚本中插入 / 删除的行数
+func ChangedLines(edits []Edit) int {
+	n := 0
+	for _, e := range edits {
+		if e.Op != Equal {
+			n++
+		}
+	}
+	return n
+}
+
+func myers(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return replaceAll(a, b)
+	}
+
+	// trace[d][k+d] = 第 d 步时对角线 k 上能走到的最远 x
+	var trace [][]int
+	var prev []int
+	for d := 0; d <= n+m; d++ {
+		if d > maxEditDistance {
+			return replaceAll(a, b)
+		}
+		cur := make([]int, 2*d+1)
+		for k := -d; k <= d; k += 2 {
+			var x int
+			switch {
+			case d == 0:
+				x = 0
+			case k == -d || (k != d && prev[k-1+d-1] < prev[k+1+d-1]):
+				x = prev[k+1+d-1] // 从 k+1 向下走：插入
+			default:
+				x = prev[k-1+d-1] + 1 // 从 k-1 向右走：删除
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			cur[k+d] = x
+			if x >= n && y >= m {
+				trace = append(trace, cur)
+				return backtrack(a, b, trace)
+			}
+		}
+		trace = append(trace, cur)
+		prev = cur
+	}
+	return replaceAll(a, b) // 不可达
+}
+
+func backtrack(a, b []string, trace [][]int) []Edit {
+	x, y := len(a), len(b)
+	var rev []Edit
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && prev[k-1+d-1] < prev[k+1+d-1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := prev[prevK+d-1]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			rev = append(rev, Edit{Op: Equal, Text: a[x-1]})
+			x--
+			y--
+		}
+		if prevK == k+1 {
+			rev = append(rev, Edit{Op: Insert, Text: b[y-1]})
+		} else {
+			rev = append(rev, Edit{Op: Delete, Text: a[x-1]})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		rev = append(rev, Edit{Op: Equal, Text: a[x-1]})
+		x--
+		y--
+	}
+
+	edits := make([]Edit, len(rev))
+	for i, e := range rev {
+		edits[len(rev)-1-i] = e
+	}
+	return edits
+}
+
+func replaceAll(a, b []string) []Edit {
+	edits := make([]Edit, 0, len(a)+len(b))
+	for _, line := range a {
+		edits = append(edits, Edit{Op: Delete, Text: line})
+	}
+	for _, line := range b {
+		edits = append(edits, Edit{Op: Insert, Text: line})
+	}
+	return edits
+}
+
+// Hunk 一段连续修改 (含上下文)
+// AStart / BStart 为 0 起始的行号
+type Hunk struct {
+	AStart, ALen int
+	BStart, BLen int
+	Edits        []Edit
+}
+
+// Hunks 把编辑脚本按修改位置分组，每组前后保留 context 行上下文；
+// 两组之间相同的行不超过 2*context 时合并为一组
+func Hunks(edits []Edit, context int) []Hunk {
+	// aPos[i] / bPos[i]：第 i 个编辑之前两边已消耗的行数
+	aPos := make([]int, len(edits)+1)
+	bPos := make([]int, len(edits)+1)
+	for i, e := range edits {
+		aPos[i+1], bPos[i+1] = aPos[i], bPos[i]
+		if e.Op != Insert {
+			aPos[i+1]++
+		}
+		if e.Op != Delete {
+			bPos[i+1]++
+		}
+	}
+
+	var hunks []Hunk
+	i := 0
+	for i < len(edits) {
+		if edits[i].Op == Equal {
+			i++
+			continue
+		}
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for {
+			for end < len(edits) && edits[end].Op != Equal {
+				end++
+			}
+			j := end
+			for j < len(edits) && edits[j].Op == Equal {
+				j++
+			}
+			if j < len(edits) && j-end <= 2*context {
+				end = j
+				continue
+			}
+			break
+		}
+		stop := end + context
+		if stop > len(edits) {
+			stop = len(edits)
+		}
+		hunks = append(hunks, Hunk{
+			AStart: aPos[start],
+			ALen:   aPos[stop] - aPos[start],
+			BStart: bPos[start],
+			BLen:   bPos[stop] - bPos[start],
+			Edits:  edits[start:stop],
+		})
+		i = stop
+	}
+	return hunks
+}
+
+// Unified 生成 unified diff 格式文本，两段文本相同时返回空字符串
+func Unified(fromName, toName, a, b string, context int) string {
+	hunks := Hunks(Strings(a, b), context)
+	if len(hunks) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+	for _, h := range hunks {
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(h.AStart, h.ALen), hunkRange(h.BStart, h.BLen))
+		for _, e := range h.Edits {
+			switch e.Op {
+			case Equal:
+				sb.WriteByte(' ')
+			case Insert:
+				sb.WriteByte('+')
+			case Delete:
+				sb.WriteByte('-')
+			}
+			sb.WriteString(e.Text)
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
+
+// hunkRange 与 GNU diff 一致：行号从 1 开始，空范围时行号指向前一行
+func hunkRange(start, length int) string {
+	switch length {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	default:
+		return fmt.Sprintf("%d,%d", start+1, length)
+	}
+}
@@ -0,0 +1,151 @@
+package diff
+
+import (
+	"sort"
+	"strings"
+)
+
+// region 一侧相对 base 的一处修改：把 base[Start:End] 替换为 Lines
+type region struct {
+	Start, End int
+	Lines      []string
+	side       int // 0 = ours, 1 = theirs
+}
+
+// regions 从编辑脚本中提取相对 base 的修改区域
+func regions(edits []Edit, side int) []region {
+	var out []region
+	i := 0 // base 中的行号
+	for k := 0; k < len(edits); {
+		if edits[k].Op == Equal {
+			i++
+			k++
+			continue
+		}
+		r := region{Start: i, side: side}
+		for k < len(edits) && edits[k].Op != Equal {
+			if edits[k].Op == Delete {
+				i++
+			} else {
+				r.Lines = append(r.Lines, edits[k].Text)
+			}
+			k++
+		}
+		r.End = i
+		out = append(out, r)
+	}
+	return out
+}
+
+// Conflict 一处无法自动合并的冲突
+type Conflict struct {
+	Line   int      `json:"line"`       // 冲突标记在合并结果中的起始行 (0 起始)
+	Base   []string `json:"base"`       // 共同祖先中的内容
+	Ours   []string `json:"ours"`       // 服务器当前内容
+	Theirs []string `json:"theirs"`     // 客户端提交的内容
+	BaseAt int      `json:"base_start"` // 在共同祖先中的起始行 (0 起始)
+}
+
+// MergeResult 三方合并结果
+type MergeResult struct {
+	// Content 合并后的文本，冲突处带 git 风格的冲突标记
+	Content   string
+	Conflicts []Conflict
+}
+
+// Clean 是否无冲突
+func (r MergeResult) Clean() bool {
+	return len(r.Conflicts) == 0
+}
+
+// Merge3 行级三方合并：base 为双方共同的祖先，ours / theirs 为各自修改后的版本
+// 只有一方修改的区域直接采用；双方修改同一区域且结果不同时产生冲突，
+// 冲突区域用 <<<<<<< oursLabel / ======= / >>>>>>> theirsLabel 标记
+func Merge3(base, ours, theirs, oursLabel, theirsLabel string) MergeResult {
+	baseLines := SplitLines(base)
+	all := append(regions(Strings(base, ours), 0), regions(Strings(base, theirs), 1)...)
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].Start != all[j].Start {
+			return all[i].Start < all[j].Start
+		}
+		return all[i].side < all[j].side
+	})
+
+	var out []string
+	var conflicts []Conflict
+	pos := 0 // base 中已输出到的位置
+	for k := 0; k < len(all); {
+		// 把首尾相接或重叠的修改归为一组
+		lo, hi := all[k].Start, all[k].End
+		group := []region{all[k]}
+		k++
+		for k < len(all) && all[k].Start <= hi {
+			if all[k].End > hi {
+				hi = all[k].End
+			}
+			group = append(group, all[k])
+			k++
+		}
+
+		out = append(out, baseLines[pos:lo]...)
+		pos = hi
+
+		var sides [2][]region
+		for _, r := range group {
+			sides[r.side] = append(sides[r.side], r)
+		}
+		switch {
+		case len(sides[1]) == 0:
+			out = append(out, apply(baseLines, lo, hi, sides[0])...)
+		case len(sides[0]) == 0:
+			out = append(out, apply(baseLines, lo, hi, sides[1])...)
+		default:
+			o := apply(baseLines, lo, hi, sides[0])
+			t := apply(baseLines, lo, hi, sides[1])
+			if equalLines(o, t) {
+				// 双方做了相同的修改
+				out = append(out, o...)
+				continue
+			}
+			conflicts = append(conflicts, Conflict{
+				Line:   len(out),
+				Base:   append([]string(nil), baseLines[lo:hi]...),
+				Ours:   o,
+				Theirs: t,
+				BaseAt: lo,
+			})
+			out = append(out, "<<<<<<< "+oursLabel)
+			out = append(out, o...)
+			out = append(out, "=======")
+			out = append(out, t...)
+			out = append(out, ">>>>>>> "+theirsLabel)
+		}
+	}
+	out = append(out, baseLines[pos:]...)
+
+	return MergeResult{Content: strings.Join(out, "\n"), Conflicts: conflicts}
+}
+
+// apply 把同一侧的若干修改应用到 base[lo:hi] 上
+func apply(base []string, lo, hi int, rs []region) []string {
+	var out []string
+	pos := lo
+	for _, r := range rs {
+		out = append(out, base[pos:r.Start]...)
+		out = append(out, r.Lines...)
+		pos = r.End
+	}
+	return append(out, base[pos:hi]...)
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
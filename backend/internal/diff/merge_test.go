@@ -0,0 +1,48 @@
+package diff
+
+import "testing"
+
+func TestMerge3(t *testing.T) {
+	base := "a\nb\nc\nd\ne"
+	tests := []struct {
+		name      string
+		ours      string
+		theirs    string
+		want      string
+		conflicts int
+	}{
+		{"都没改", base, base, base, 0},
+		{"只有一方修改", "a\nB\nc\nd\ne", base, "a\nB\nc\nd\ne", 0},
+		{"只有另一方修改", base, "a\nb\nc\nD\ne", "a\nb\nc\nD\ne", 0},
+		{"修改不同的行", "A\nb\nc\nd\ne", "a\nb\nc\nd\nE", "A\nb\nc\nd\nE", 0},
+		{"一方插入一方删除", "a\nb\nx\nc\nd\ne", "a\nb\nc\nd", "a\nb\nx\nc\nd", 0},
+		{"相同的修改", "a\nB\nc\nd\ne", "a\nB\nc\nd\ne", "a\nB\nc\nd\ne", 0},
+		{"修改同一行", "a\nX\nc\nd\ne", "a\nY\nc\nd\ne", "a\n<<<<<<< ours\nX\n=======\nY\n>>>>>>> theirs\nc\nd\ne", 1},
+		{"相邻的修改算作冲突", "a\nB\nc\nd\ne", "a\nb\nC\nd\ne", "a\n<<<<<<< ours\nB\nc\n=======\nb\nC\n>>>>>>> theirs\nd\ne", 1},
+		{"多处冲突", "X\nb\nc\nd\nX", "Y\nb\nc\nd\nY", "<<<<<<< ours\nX\n=======\nY\n>>>>>>> theirs\nb\nc\nd\n<<<<<<< ours\nX\n=======\nY\n>>>>>>> theirs", 2},
+	}
+	for _, tt := range tests {
+		r := Merge3(base, tt.ours, tt.theirs, "ours", "theirs")
+		if r.Content != tt.want {
+			t.Errorf("%s: 合并结果\n%s\n期望\n%s", tt.name, r.Content, tt.want)
+		}
+		if len(r.Conflicts) != tt.conflicts || r.Clean() != (tt.conflicts == 0) {
+			t.Errorf("%s: %d 处冲突，期望 %d 处", tt.name, len(r.Conflicts), tt.conflicts)
+		}
+	}
+}
+
+func TestMerge3Conflict(t *testing.T) {
+	r := Merge3("a\nb\nc", "a\nX\nc", "a\nY\nc", "server", "client")
+	if len(r.Conflicts) != 1 {
+		t.Fatalf("得到 %d 处冲突", len(r.Conflicts))
+	}
+	c := r.Conflicts[0]
+	if c.Line != 1 || c.BaseAt != 1 || !equalLines(c.Base, []string{"b"}) ||
+		!equalLines(c.Ours, []string{"X"}) || !equalLines(c.Theirs, []string{"Y"}) {
+		t.Errorf("冲突信息 %+v", c)
+	}
+	if lines := SplitLines(r.Content); lines[c.Line] != "<<<<<<< server" {
+		t.Errorf("Line 应指向冲突标记，得到 %q", lines[c.Line])
+	}
+}
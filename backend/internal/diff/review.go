@@ -0,0 +1,57 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Change 一处可以单独接受或拒绝的修改：把原文的 [Start, End) 行替换为 New
+// 同一对文本每次计算的结果相同，客户端用 Index 指明接受哪些修改
+type Change struct {
+	Index int      `json:"index"`
+	Start int      `json:"start"` // 在原文中的起始行 (0 起始)
+	End   int      `json:"end"`
+	Old   []string `json:"old"` // 原文中被替换的行，纯插入时为空
+	New   []string `json:"new"` // 替换后的行，纯删除时为空
+}
+
+// Changes 逐处列出 a -> b 的修改，相邻的修改行归为一处 (通常对应一个段落)
+func Changes(a, b string) []Change {
+	aLines := SplitLines(a)
+	var out []Change
+	for i, r := range regions(Strings(a, b), 0) {
+		out = append(out, Change{
+			Index: i,
+			Start: r.Start,
+			End:   r.End,
+			Old:   append([]string{}, aLines[r.Start:r.End]...),
+			New:   append([]string{}, r.Lines...),
+		})
+	}
+	return out
+}
+
+// ApplyChanges 只把 accepted 中列出的修改 (按 Index) 应用到 a 上，changes 须为 Changes(a, b) 的结果
+func ApplyChanges(a string, changes []Change, accepted []int) (string, error) {
+	take := make(map[int]bool, len(accepted))
+	for _, i := range accepted {
+		if i < 0 || i >= len(changes) {
+			return "", fmt.Errorf("修改编号 %d 不存在", i)
+		}
+		take[i] = true
+	}
+
+	lines := SplitLines(a)
+	var out []string
+	pos := 0
+	for _, ch := range changes {
+		if !take[ch.Index] {
+			continue
+		}
+		out = append(out, lines[pos:ch.Start]...)
+		out = append(out, ch.New...)
+		pos = ch.End
+	}
+	out = append(out, lines[pos:]...)
+	return strings.Join(out, "\n"), nil
+}
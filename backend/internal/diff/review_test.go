@@ -0,0 +1,58 @@
+package diff
+
+import "testing"
+
+func TestChanges(t *testing.T) {
+	a := "p1\n\np2\n\np3"
+	b := "P1\n\np2\n\np3\nnew"
+	changes := Changes(a, b)
+	if len(changes) != 2 {
+		t.Fatalf("得到 %d 处修改 %+v，期望 2 处", len(changes), changes)
+	}
+	if c := changes[0]; c.Index != 0 || c.Start != 0 || c.End != 1 || !equalLines(c.Old, []string{"p1"}) || !equalLines(c.New, []string{"P1"}) {
+		t.Errorf("第一处修改 %+v", c)
+	}
+	if c := changes[1]; c.Index != 1 || c.Start != 5 || c.End != 5 || len(c.Old) != 0 || !equalLines(c.New, []string{"new"}) {
+		t.Errorf("第二处修改 (纯插入) %+v", c)
+	}
+	if got := Changes(a, a); len(got) != 0 {
+		t.Errorf("内容相同时得到 %+v", got)
+	}
+}
+
+func TestApplyChanges(t *testing.T) {
+	a := "p1\n\np2\n\np3"
+	b := "P1\n\np3\nnew"
+	changes := Changes(a, b)
+
+	tests := []struct {
+		name     string
+		accepted []int
+		want     string
+	}{
+		{"全部拒绝", nil, a},
+		{"全部接受", []int{0, 1, 2}, b},
+		{"只接受第一处", []int{0}, "P1\n\np2\n\np3"},
+		{"只接受删除", []int{1}, "p1\n\np3"},
+		{"顺序和重复无关", []int{2, 0, 2}, "P1\n\np2\n\np3\nnew"},
+	}
+	if len(changes) != 3 {
+		t.Fatalf("得到 %d 处修改 %+v，期望 3 处", len(changes), changes)
+	}
+	for _, tt := range tests {
+		got, err := ApplyChanges(a, changes, tt.accepted)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: 得到 %q，期望 %q", tt.name, got, tt.want)
+		}
+	}
+
+	for _, bad := range []int{-1, 3} {
+		if _, err := ApplyChanges(a, changes, []int{bad}); err == nil {
+			t.Errorf("修改编号 %d 应报错", bad)
+		}
+	}
+}
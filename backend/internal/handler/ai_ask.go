@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"ai-notes/internal/ai"
+	"ai-notes/internal/model"
+	"ai-notes/internal/search"
+	"ai-notes/internal/semantic"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 问答检索：默认 / 最多引用的笔记数，以及发送给模型的笔记内容总长度上限 (字符数)
+const (
+	defaultAskSources = 5
+	maxAskSources     = 10
+	maxAskContext     = 12000
+)
+
+const askSystemPrompt = `你是用户的笔记助手。请只根据下面提供的笔记片段回答问题，不要编造笔记中没有的内容。
+每个片段都有编号，如 [1]。回答中引用某个片段的内容时，在句末用相同的编号标注出处，例如 "部署使用 Docker Compose [2]。"。
+如果笔记中没有足够的信息回答问题，请直接说明。使用与问题相同的语言回答，保持 Markdown 格式。`
+
+// askSource 问答引用的一段笔记，编号与回答中的 [n] 对应
+type askSource struct {
+	N       int     `json:"n"`
+	ID      uint    `json:"id,omitempty"` // 不支持 ID 的后端为 0，前端用 title + folder 打开
+	Title   string  `json:"title"`
+	Folder  string  `json:"folder"`
+	Heading string  `json:"heading,omitempty"`
+	Score   float64 `json:"score"`
+	text    string
+}
+
+// Ask 基于笔记内容回答问题
+// POST /api/ai/ask {"question": "...", "folder": "Work", "tag": "ops", "limit": 5}
+// 响应为 SSE：先发送一条 {"type":"sources"} 事件列出引用的笔记，之后是模型的流式输出 (见 streamChat)，回答中用 [n] 标注出处
+func (h *AIHandler) Ask(c *gin.Context) {
+	var req model.AskRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+	req.Question = strings.TrimSpace(req.Question)
+	if req.Question == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少问题"})
+		return
+	}
+	if req.Limit < 1 || req.Limit > maxAskSources {
+		req.Limit = defaultAskSources
+	}
+
+	sources, err := h.retrieve(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(sources) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "没有找到与问题相关的笔记"})
+		return
+	}
+
+	var ctx strings.Builder
+	for _, s := range sources {
+		fmt.Fprintf(&ctx, "[%d] 《%s》", s.N, s.Title)
+		if s.Heading != "" {
+			fmt.Fprintf(&ctx, " - %s", s.Heading)
+		}
+		fmt.Fprintf(&ctx, "\n%s\n\n", s.text)
+	}
+	messages := []model.Message{
+		{Role: "system", Content: askSystemPrompt},
+		{Role: "user", Content: "笔记片段：\n\n" + ctx.String() + "问题：" + req.Question},
+	}
+
+	h.streamChat(c, ai.ChatRequest{Messages: messages}, func() {
+		writeEvent(c, gin.H{"type": "sources", "sources": sources})
+	}, nil)
+}
+
+// retrieve 检索与问题相关的笔记片段：配置了向量模型时按语义检索，否则 (或向量接口出错、向量还没生成时) 用全文索引
+func (h *AIHandler) retrieve(ctx context.Context, req model.AskRequest) ([]askSource, error) {
+	var sources []askSource
+	if h.Semantic != nil {
+		hits, err := h.Semantic.Search(ctx, req.Question, semantic.Options{Folder: req.Folder, Tag: req.Tag, Limit: req.Limit})
+		if err == nil && len(hits) > 0 {
+			for _, hit := range hits {
+				sources = append(sources, askSource{ID: hit.ID, Title: hit.Title, Folder: hit.Folder, Heading: hit.Heading, Score: hit.Score, text: hit.Text})
+			}
+			return numberSources(sources), nil
+		}
+		if err != nil {
+			log.Println("语义检索失败，改用全文检索:", err)
+		}
+	}
+
+	q := search.ParseLooseQuery(req.Question)
+	if q.Empty() {
+		return nil, nil
+	}
+	res := h.Index.Search(q, search.Options{Folder: req.Folder, Tag: req.Tag, Limit: req.Limit})
+	for _, hit := range res.Hits {
+		content, err := h.Store.GetNote(hit.Title, hit.Folder)
+		if err != nil {
+			continue // 索引还没跟上删除
+		}
+		chunk := bestChunk(content, q)
+		sources = append(sources, askSource{ID: hit.ID, Title: hit.Title, Folder: hit.Folder, Heading: chunk.Heading, Score: hit.Score, text: chunk.Text})
+	}
+	return numberSources(sources), nil
+}
+
+// numberSources 编号，并按总长度上限截断每段内容
+func numberSources(sources []askSource) []askSource {
+	if len(sources) == 0 {
+		return nil
+	}
+	per := maxAskContext / len(sources)
+	for i := range sources {
+		sources[i].N = i + 1
+		if utf8.RuneCountInString(sources[i].text) > per {
+			sources[i].text = string([]rune(sources[i].text)[:per]) + "…"
+		}
+	}
+	return sources
+}
+
+// bestChunk 笔记中包含查询词最多的一段 (按标题切分)
+func bestChunk(content string, q search.Query) semantic.Chunk {
+	chunks := semantic.SplitChunks(content)
+	if len(chunks) == 0 {
+		return semantic.Chunk{Text: content}
+	}
+	best, bestScore := 0, -1
+	for i, ch := range chunks {
+		terms := map[string]bool{}
+		for _, t := range search.Tokenize(ch.Heading + "\n" + ch.Text) {
+			terms[t.Term] = true
+		}
+		score := 0
+		for _, cl := range q.Clauses {
+			if terms[cl.Terms[0]] {
+				score++
+			}
+		}
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return chunks[best]
+}
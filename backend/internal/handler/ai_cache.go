@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"ai-notes/internal/ai"
+	"ai-notes/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cacheKey 模板标记为可缓存且启用了缓存时返回请求的缓存键，否则返回空
+func (h *AIHandler) cacheKey(p *model.Prompt, req ai.ChatRequest) string {
+	if h.Cache == nil || !p.Cacheable {
+		return ""
+	}
+	if req.Model == "" {
+		req.Model = h.Provider.DefaultModel()
+	}
+	return ai.CacheKey(h.Provider.Name(), req)
+}
+
+// cached 查找缓存，并在响应头 X-AI-Cache 中标明 hit / miss / bypass
+// 请求头带 X-AI-Cache: bypass 时不读缓存 (新结果仍会写入，相当于刷新)
+func (h *AIHandler) cached(c *gin.Context, key string) (string, bool) {
+	if c.GetHeader("X-AI-Cache") == "bypass" {
+		c.Header("X-AI-Cache", "bypass")
+		return "", false
+	}
+	if text, ok := h.Cache.Get(key); ok {
+		c.Header("X-AI-Cache", "hit")
+		return text, true
+	}
+	c.Header("X-AI-Cache", "miss")
+	return "", false
+}
@@ -0,0 +1,263 @@
+package handler
+
+import (
+	"ai-notes/internal/ai"
+	"ai-notes/internal/dao"
+	"ai-notes/internal/model"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 对话发送给模型的输入上限 (估算的 token 数，见 ai.EstimateTokens)，笔记内容最多占一半；
+// 超出时从最早的历史消息开始省略
+const (
+	chatContextTokens = 12000
+	chatTitleRunes    = 50
+)
+
+const chatSystemPrompt = `你是用户的写作助手，正在和用户讨论下面这篇笔记。
+用户可能要求解释、总结、改写或续写笔记的内容，也可能对你之前的回答提出修改 (如 "再短一点"、"换成英文")。
+需要给出改写结果时直接输出结果，保持 Markdown 格式，不要额外解释。
+
+笔记标题：《%s》
+笔记内容：
+%s`
+
+// chatStore 当前存储后端是否支持对话，不支持时直接返回 501
+func (h *AIHandler) chatStore(c *gin.Context) (dao.ChatStore, bool) {
+	cs, ok := h.Store.(dao.ChatStore)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "当前存储后端不支持 AI 对话"})
+	}
+	return cs, ok
+}
+
+// Chat 围绕一篇笔记与 AI 多轮对话，历史消息保存在服务端
+// POST /api/ai/chat {"note_id": 3, "message": "帮我总结一下"} 新建对话
+// POST /api/ai/chat {"session_id": 7, "message": "再短一点"}    继续对话
+// 响应为 SSE：模型的流式输出 (见 streamChat)；回答完整结束后，这一轮的提问和回答才会写入历史，写入失败时以 error 事件结束。
+// 对话信息 {"type":"session","session":{...},"omitted":n} (omitted 为超出上下文而省略的早期消息数) 在继续对话时最先发送；
+// 新对话在第一轮写入历史时才创建，因此在 done 之前发送，模型调用失败时不会留下空对话
+func (h *AIHandler) Chat(c *gin.Context) {
+	cs, ok := h.chatStore(c)
+	if !ok {
+		return
+	}
+	var req model.AIChatRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+	req.Message = strings.TrimSpace(req.Message)
+	if req.Message == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少消息"})
+		return
+	}
+
+	var session *model.ChatSession
+	var history []model.ChatMessage
+	if req.SessionID != 0 {
+		var err error
+		if session, err = cs.GetChatSession(req.SessionID); err != nil {
+			writeChatError(c, err)
+			return
+		}
+		if history, err = cs.ListChatMessages(session.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "读取对话失败"})
+			return
+		}
+	} else if req.NoteID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少 session_id 或 note_id"})
+		return
+	}
+
+	noteID := req.NoteID
+	if session != nil {
+		noteID = session.NoteID
+	}
+	note, err := h.Store.(dao.IDStore).GetNoteByID(noteID) // 支持对话的后端都支持 ID 寻址
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+	if req.Content == "" {
+		req.Content = note.Content
+	}
+
+	messages, omitted, err := chatMessages(note.Title, req.Content, history, req.Message)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created := session == nil
+	if created {
+		session = &model.ChatSession{NoteID: note.ID, Title: excerpt(req.Message, chatTitleRunes)}
+	}
+
+	chat := ai.ChatRequest{Model: req.Model, Temperature: req.Temperature, Messages: messages}
+	var before func()
+	if !created {
+		before = func() {
+			writeEvent(c, gin.H{"type": "session", "session": session, "omitted": omitted})
+		}
+	}
+	after := func(output string) error {
+		turn := []model.ChatMessage{
+			{Role: "user", Content: req.Message},
+			{Role: "assistant", Content: output},
+		}
+		if err := cs.AppendChatMessages(session, turn); err != nil {
+			log.Printf("保存对话 (笔记 %d, 对话 %d) 失败: %v", session.NoteID, session.ID, err)
+			if created {
+				return errors.New("回答已生成，但创建对话失败")
+			}
+			return errors.New("回答已生成，但保存对话失败，继续对话时不会包含这一轮")
+		}
+		if created {
+			writeEvent(c, gin.H{"type": "session", "session": session, "omitted": omitted})
+		}
+		return nil
+	}
+	h.streamChat(c, chat, before, after)
+}
+
+// chatMessages 组装发送给模型的消息：系统提示 (含笔记内容)、放得下的最近历史、新消息
+// 返回因超出上下文而省略的历史消息数
+func chatMessages(title, content string, history []model.ChatMessage, message string) ([]model.Message, int, error) {
+	budget := chatContextTokens - ai.EstimateTokens(message)
+	if budget <= 0 {
+		return nil, 0, fmt.Errorf("消息太长 (约 %d tokens)，请缩短后再试", ai.EstimateTokens(message))
+	}
+	if limit := chatContextTokens / 2; ai.EstimateTokens(content) > limit {
+		content = truncateTokens(content, limit) + "\n\n(笔记过长，后面的内容已省略)"
+	}
+	system := fmt.Sprintf(chatSystemPrompt, title, content)
+	budget -= ai.EstimateTokens(system)
+
+	// 从最近的消息往前取，直到放不下为止
+	start := len(history)
+	for start > 0 {
+		cost := ai.EstimateTokens(history[start-1].Content)
+		if cost > budget {
+			break
+		}
+		budget -= cost
+		start--
+	}
+	// 历史须以用户消息开头 (部分服务商要求 user / assistant 交替)
+	for start < len(history) && history[start].Role != "user" {
+		start++
+	}
+
+	messages := make([]model.Message, 0, len(history)-start+2)
+	messages = append(messages, model.Message{Role: "system", Content: system})
+	for _, m := range history[start:] {
+		messages = append(messages, model.Message{Role: m.Role, Content: m.Content})
+	}
+	messages = append(messages, model.Message{Role: "user", Content: message})
+	return messages, start, nil
+}
+
+// truncateTokens 截取 s 的开头，使估算的 token 数不超过 n
+func truncateTokens(s string, n int) string {
+	ascii, other := 0, 0
+	for i, r := range s {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+		if (ascii+3)/4+other > n {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// excerpt 取文本的第一行，超过 n 个字符时截断
+func excerpt(s string, n int) string {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), "\n")
+	if r := []rune(s); len(r) > n {
+		return string(r[:n]) + "…"
+	}
+	return s
+}
+
+// ListChatSessions GET /api/ai/chat/sessions?note_id=3 笔记的对话列表，不带 note_id 时为全部
+func (h *AIHandler) ListChatSessions(c *gin.Context) {
+	cs, ok := h.chatStore(c)
+	if !ok {
+		return
+	}
+	var noteID uint
+	if v := c.Query("note_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "非法的 note_id"})
+			return
+		}
+		noteID = uint(id)
+	}
+	list, err := cs.ListChatSessions(noteID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取对话失败"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// GetChatSession GET /api/ai/chat/sessions/:id 对话及其全部消息
+func (h *AIHandler) GetChatSession(c *gin.Context) {
+	cs, ok := h.chatStore(c)
+	if !ok {
+		return
+	}
+	id, ok := paramID(c, "id", false)
+	if !ok {
+		return
+	}
+	session, err := cs.GetChatSession(id)
+	if err != nil {
+		writeChatError(c, err)
+		return
+	}
+	messages, err := cs.ListChatMessages(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取对话失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"session": session, "messages": messages})
+}
+
+// DeleteChatSession DELETE /api/ai/chat/sessions/:id
+func (h *AIHandler) DeleteChatSession(c *gin.Context) {
+	cs, ok := h.chatStore(c)
+	if !ok {
+		return
+	}
+	id, ok := paramID(c, "id", false)
+	if !ok {
+		return
+	}
+	if err := cs.DeleteChatSession(id); err != nil {
+		writeChatError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+func writeChatError(c *gin.Context, err error) {
+	if errors.Is(err, dao.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"ai-notes/internal/ai"
+	"ai-notes/internal/model"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// history n 轮对话，每条消息约 tokens 个 token
+func history(n, tokens int) []model.ChatMessage {
+	var msgs []model.ChatMessage
+	for i := 0; i < n; i++ {
+		msgs = append(msgs,
+			model.ChatMessage{Role: "user", Content: fmt.Sprintf("q%d ", i) + strings.Repeat("问", tokens)},
+			model.ChatMessage{Role: "assistant", Content: fmt.Sprintf("a%d ", i) + strings.Repeat("答", tokens)},
+		)
+	}
+	return msgs
+}
+
+func TestChatMessages(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		history  []model.ChatMessage
+		omitted  int
+		messages int // 含系统提示和新消息
+	}{
+		{"没有历史", "note", nil, 0, 2},
+		{"历史全部放得下", "note", history(3, 100), 0, 8},
+		{"省略最早的历史", "note", history(10, 2000), 16, 6},
+		{"长笔记挤占历史", strings.Repeat("字", chatContextTokens), history(10, 1000), 16, 6},
+		{"放不下的单条消息", "note", history(1, chatContextTokens), 2, 2},
+	}
+	for _, tt := range tests {
+		msgs, omitted, err := chatMessages("title", tt.content, tt.history, "new")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if omitted != tt.omitted || len(msgs) != tt.messages {
+			t.Errorf("%s: 省略 %d 条、发送 %d 条，期望省略 %d 条、发送 %d 条", tt.name, omitted, len(msgs), tt.omitted, tt.messages)
+			continue
+		}
+		if msgs[0].Role != "system" || msgs[len(msgs)-1].Content != "new" {
+			t.Errorf("%s: 首尾应为系统提示和新消息", tt.name)
+		}
+		if len(msgs) > 2 && msgs[1].Role != "user" {
+			t.Errorf("%s: 历史应以用户消息开头，得到 %s", tt.name, msgs[1].Role)
+		}
+		total := 0
+		for _, m := range msgs {
+			total += ai.EstimateTokens(m.Content)
+		}
+		if total > chatContextTokens {
+			t.Errorf("%s: 共约 %d tokens，超出上限 %d", tt.name, total, chatContextTokens)
+		}
+	}
+
+	if _, _, err := chatMessages("title", "note", nil, strings.Repeat("字", chatContextTokens)); err == nil {
+		t.Error("消息本身超出上限时应报错")
+	}
+}
+
+func TestTruncateTokens(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"abcdefgh", 2, "abcdefgh"},
+		{"abcdefghi", 2, "abcdefgh"},
+		{"中文内容", 2, "中文"},
+		{"ab中文", 2, "ab中"},
+		{"", 5, ""},
+	}
+	for _, tt := range tests {
+		if got := truncateTokens(tt.s, tt.n); got != tt.want {
+			t.Errorf("truncateTokens(%q, %d) = %q，期望 %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"  hello\nworld", 10, "hello"},
+		{"帮我总结一下这篇笔记", 4, "帮我总结…"},
+		{"short", 5, "short"},
+	}
+	for _, tt := range tests {
+		if got := excerpt(tt.s, tt.n); got != tt.want {
+			t.Errorf("excerpt(%q, %d) = %q，期望 %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestForwardAfterError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name  string
+		after func(string) error
+		last  string
+	}{
+		{"成功", func(string) error { return nil }, `{"type":"done"}`},
+		{"保存失败", func(string) error { return errors.New("保存对话失败") }, `{"type":"error","error":"保存对话失败"}`},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		var got string
+		forward(c, ai.Replay("hi"), nil, func(output string) error {
+			got = output
+			return tt.after(output)
+		})
+		if got != "hi" {
+			t.Errorf("%s: after 收到 %q", tt.name, got)
+		}
+		events := strings.Split(strings.TrimSpace(w.Body.String()), "\n\n")
+		if last := strings.TrimPrefix(events[len(events)-1], "data: "); last != tt.last {
+			t.Errorf("%s: 最后一个事件 %s，期望 %s", tt.name, last, tt.last)
+		}
+		if len(events) != 2 {
+			t.Errorf("%s: 得到 %d 个事件 %q", tt.name, len(events), events)
+		}
+	}
+}
@@ -1,93 +1,172 @@
 package handler
 
 import (
-	"ai-notes/internal/model"
-	"bufio"
-	"bytes"
+	"ai-notes/internal/ai"
+	"ai-notes/internal/dao"
+	"ai-notes/internal/jobs"
+	"ai-notes/internal/search"
+	"ai-notes/internal/semantic"
+	"ai-notes/internal/usage"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"os"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-type AIHandler struct{}
+type AIHandler struct {
+	Provider ai.AIProvider
+	Store    dao.NoteStore
+	Index    *search.Indexer   // 问答检索用的全文索引
+	Semantic *semantic.Indexer // 向量索引，未配置向量模型时为 nil
+	Meter    *usage.Meter      // 用量记录、预算与限流
+	Cache    *ai.Cache         // 可缓存动作的结果缓存，未启用时为 nil
+	Jobs     *jobs.Runner      // 后台批量任务，存储后端不支持时为 nil
+	streams  *streamRegistry   // 进行中的请求，见 begin / Cancel
+}
+
+func NewAIHandler(p ai.AIProvider, s dao.NoteStore, ix *search.Indexer, sem *semantic.Indexer, m *usage.Meter, cache *ai.Cache, jr *jobs.Runner) *AIHandler {
+	return &AIHandler{Provider: p, Store: s, Index: ix, Semantic: sem, Meter: m, Cache: cache, Jobs: jr, streams: newStreamRegistry()}
+}
 
+// Polish / Format 使用模板库中的 polish / format 模板，等价于 POST /api/ai/run/polish|format
+// 输出完毕后总会推送修改列表 (见 run)
 func (h *AIHandler) Polish(c *gin.Context) {
-	h.callAI(c, "请直接润色以下内容，不要废话，保持 Markdown 格式：\n\n")
+	h.run(c, "polish", true)
 }
 
 func (h *AIHandler) Format(c *gin.Context) {
-	h.callAI(c, "请将以下内容进行 Markdown 格式化（修正层级、列表、代码块等），直接返回格式化后的结果，不要有任何开场白或解释：\n\n")
+	h.run(c, "format", true)
 }
 
-func (h *AIHandler) callAI(c *gin.Context, promptPrefix string) {
-	// 1. 解析请求
-	var req model.AiPolishRequest
-	if err := c.BindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": "Invalid JSON"})
+// streamChat 调用大模型，把输出转换为统一的 SSE 事件流发给前端，每条 data 是一个 ai.Event：
+//
+//	data: {"type":"delta","text":"..."}
+//	data: {"type":"usage","usage":{"input_tokens":12,"output_tokens":34}}
+//	data: {"type":"done"}               (正常结束)
+//	data: {"type":"error","error":"..."} (中途出错)
+//
+// 与服务商无关；连接失败或上游返回错误状态码时还没有开始输出，直接返回 JSON 错误
+// before 不为 nil 时在响应头写出之后、转发模型输出之前调用，用于先推送额外的事件 (如问答的引用来源)；
+// after 不为 nil 时在模型正常输出完毕、done 事件之前以完整输出调用 (如推送修改列表)，返回错误时以 error 事件结束
+func (h *AIHandler) streamChat(c *gin.Context, req ai.ChatRequest, before func(), after func(output string) error) {
+	ctx, done := h.begin(c)
+	defer done()
+	stream, err := h.Provider.ChatStream(ctx, req)
+	if err != nil {
+		writeAIError(c, err)
 		return
 	}
+	defer stream.Close()
 
-	apiKey := os.Getenv("AI_API_KEY")
-	baseUrl := os.Getenv("AI_BASE_URL")
-	if baseUrl == "" {
-		baseUrl = "https://api.deepseek.com"
-	}
-
-	modelName := os.Getenv("AI_MODEL_NAME")
-	if modelName == "" {
-		modelName = "deepseek-chat"
-	}
-
-	prompt := promptPrefix + req.Content
-
-	chatReq := model.ChatRequest{
-		Model:    modelName,
-		Stream:   true,
-		Messages: []model.Message{{Role: "user", Content: prompt}},
-	}
-	reqBytes, _ := json.Marshal(chatReq)
-
-	httpReq, _ := http.NewRequest("POST", baseUrl+"/chat/completions", bytes.NewBuffer(reqBytes))
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+apiKey)
-
-	client := &http.Client{}
-	resp, err := client.Do(httpReq)
-	if err != nil {
-		log.Println("AI 连接失败:", err)
-		c.JSON(500, gin.H{"error": "AI Service Connection Failed"})
-		return
+	startSSE(c)
+	if before != nil {
+		before()
 	}
-	defer resp.Body.Close()
+	forward(c, stream, nil, after)
+}
 
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		log.Printf("AI 报错 (Code %d): %s", resp.StatusCode, string(body))
-		c.JSON(resp.StatusCode, gin.H{"error": fmt.Sprintf("AI Error: %s", string(body))})
+// writeAIError 还没开始输出时，把上游错误转换为 JSON 响应
+func writeAIError(c *gin.Context, err error) {
+	var apiErr *ai.APIError
+	if errors.As(err, &apiErr) {
+		log.Printf("AI 报错 (Code %d): %s", apiErr.Status, apiErr.Body)
+		c.JSON(apiErr.Status, gin.H{"error": fmt.Sprintf("AI Error: %s", apiErr.Body)})
 		return
 	}
+	log.Println("AI 连接失败:", err)
+	c.JSON(500, gin.H{"error": "AI Service Connection Failed"})
+}
 
+// startSSE 写出事件流的响应头
+func startSSE(c *gin.Context) {
 	c.Writer.Header().Set("Content-Type", "text/event-stream")
 	c.Writer.Header().Set("Cache-Control", "no-cache")
 	c.Writer.Header().Set("Connection", "keep-alive")
 	c.Writer.Header().Set("Transfer-Encoding", "chunked")
+}
 
-	reader := bufio.NewReader(resp.Body)
+// forward 把模型输出转发为统一事件，以 done 或 error 结束
+// extra 不为 nil 时计入 usage 事件 (如 map-reduce 中间步骤消耗的 token)，after 见 streamChat
+func forward(c *gin.Context, stream ai.Stream, extra *ai.Usage, after func(output string) error) {
+	var out strings.Builder
 	for {
-		line, err := reader.ReadBytes('\n')
-		if err != nil {
-			if err == io.EOF {
-				break
+		ev, err := stream.Recv()
+		if err == io.EOF {
+			if after != nil {
+				if err := after(out.String()); err != nil {
+					writeEvent(c, ai.Event{Type: ai.EventError, Error: err.Error()})
+					return
+				}
 			}
-			log.Println("读取流出错:", err)
-			break
+			writeEvent(c, ai.Event{Type: ai.EventDone})
+			return
+		}
+		if err != nil {
+			writeStreamError(c, err)
+			return
 		}
-		c.Writer.Write(line)
-		c.Writer.Flush()
+		if ev.Type == ai.EventUsage && extra != nil {
+			ev.Usage.InputTokens += extra.InputTokens
+			ev.Usage.OutputTokens += extra.OutputTokens
+		}
+		if ev.Type == ai.EventDelta && after != nil {
+			out.WriteString(ev.Text)
+		}
+		if writeEvent(c, ev) != nil {
+			return // 客户端已断开，上游请求已被 writeEvent 取消
+		}
+	}
+}
+
+// writeStreamError 开始输出之后出错 (包括被取消)，以 error 事件结束
+func writeStreamError(c *gin.Context, err error) {
+	if errors.Is(err, context.Canceled) {
+		writeEvent(c, ai.Event{Type: ai.EventError, Error: "已取消"})
+		return
+	}
+	log.Println("读取流出错:", err)
+	writeEvent(c, ai.Event{Type: ai.EventError, Error: err.Error()})
+}
+
+// complete 非流式调用当前服务商
+func (h *AIHandler) complete(ctx context.Context, req ai.ChatRequest) (string, ai.Usage, error) {
+	return ai.Complete(ctx, h.Provider, req)
+}
+
+// writeEvent 写出一条 SSE 消息；写出失败说明客户端已断开，同时取消当前的上游请求 (见 begin)
+func writeEvent(c *gin.Context, v any) error {
+	data, _ := json.Marshal(v)
+	if _, err := fmt.Fprintf(c.Writer, "data: %s\n\n", data); err != nil {
+		if cancel, ok := c.Get(streamCancelKey); ok {
+			cancel.(context.CancelFunc)()
+		}
+		return err
+	}
+	c.Writer.Flush()
+	return nil
+}
+
+// Models GET /api/ai/models 当前服务商可用的模型
+func (h *AIHandler) Models(c *gin.Context) {
+	models, err := h.Provider.ListModels(c.Request.Context())
+	if err != nil {
+		var apiErr *ai.APIError
+		if errors.As(err, &apiErr) {
+			c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("AI Error: %s", apiErr.Body)})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
 	}
+	c.JSON(http.StatusOK, gin.H{
+		"provider": h.Provider.Name(),
+		"default":  h.Provider.DefaultModel(),
+		"models":   models,
+	})
 }
@@ -0,0 +1,264 @@
+package handler
+
+import (
+	"ai-notes/internal/dao"
+	"ai-notes/internal/jobs"
+	"ai-notes/internal/model"
+	"ai-notes/internal/search"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxJobNotes    = 5000             // 一个任务最多包含的笔记数
+	listJobsLimit  = 50               // 任务列表返回最近的任务数
+	jobEventsCheck = 15 * time.Second // 进度流兜底刷新的间隔 (进度推送可能被丢弃)
+)
+
+// jobRunner 当前存储后端是否支持后台任务，不支持时直接返回 501
+func (h *AIHandler) jobRunner(c *gin.Context) (*jobs.Runner, bool) {
+	if h.Jobs == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "当前存储后端不支持后台 AI 任务"})
+		return nil, false
+	}
+	return h.Jobs, true
+}
+
+// CreateJob 创建后台任务：对一批笔记执行模板动作，结果作为新版本写回笔记
+// POST /api/ai/jobs {"action": "format", "folder": "Imported"} | {"action": "polish", "tag": "draft"} | {"action": "format", "ids": [1, 2]}
+func (h *AIHandler) CreateJob(c *gin.Context) {
+	runner, ok := h.jobRunner(c)
+	if !ok {
+		return
+	}
+	var req model.AIJobRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid JSON"})
+		return
+	}
+	if _, err := h.prompt(req.Action); err != nil {
+		if errors.Is(err, dao.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "未知的 AI 动作: " + req.Action})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	notes, selector, ok := h.jobNotes(c, req)
+	if !ok {
+		return
+	}
+	if len(notes) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "没有符合条件的笔记"})
+		return
+	}
+	if len(notes) > maxJobNotes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("笔记太多 (%d 篇)，一个任务最多 %d 篇", len(notes), maxJobNotes)})
+		return
+	}
+
+	items := make([]model.AIJobItem, 0, len(notes))
+	for _, n := range notes {
+		items = append(items, model.AIJobItem{NoteID: n.ID, Title: n.Title, Folder: n.Folder})
+	}
+	job := &model.AIJob{Action: req.Action, Selector: selector, Language: req.Language, Model: req.Model}
+	if err := runner.Submit(job, items); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建任务失败"})
+		return
+	}
+	c.JSON(http.StatusAccepted, job)
+}
+
+// jobNotes 按文件夹 (含子文件夹)、标签或 ID 列表选出任务的笔记，按文件夹、标题排序；失败时已写出响应
+func (h *AIHandler) jobNotes(c *gin.Context, req model.AIJobRequest) ([]model.NoteSummary, string, bool) {
+	selectors := 0
+	for _, set := range []bool{req.Folder != "", req.Tag != "", len(req.IDs) > 0} {
+		if set {
+			selectors++
+		}
+	}
+	if selectors != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "folder、tag、ids 必须且只能指定一个"})
+		return nil, "", false
+	}
+
+	var notes []model.NoteSummary
+	var selector string
+	switch {
+	case req.Folder != "":
+		all, err := h.Store.ListNotes()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取列表失败"})
+			return nil, "", false
+		}
+		for _, n := range all {
+			if search.MatchFolder(n.Folder, req.Folder) {
+				notes = append(notes, n)
+			}
+		}
+		selector = "folder:" + req.Folder
+	case req.Tag != "":
+		ts, ok := h.Store.(dao.TagStore)
+		if !ok {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": "当前存储后端不支持标签"})
+			return nil, "", false
+		}
+		list, err := ts.ListNotesByTag(req.Tag)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取列表失败"})
+			return nil, "", false
+		}
+		notes = list
+		selector = "tag:" + req.Tag
+	default:
+		is := h.Store.(dao.IDStore) // 支持任务的后端都支持 ID 寻址 (见 jobs.New)
+		seen := map[uint]bool{}
+		for _, id := range req.IDs {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			n, err := is.GetNoteByID(id)
+			if errors.Is(err, dao.ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("笔记 %d 不存在", id)})
+				return nil, "", false
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "获取笔记失败"})
+				return nil, "", false
+			}
+			notes = append(notes, n.NoteSummary)
+		}
+		selector = "ids"
+	}
+
+	sort.Slice(notes, func(i, j int) bool {
+		if notes[i].Folder != notes[j].Folder {
+			return notes[i].Folder < notes[j].Folder
+		}
+		return notes[i].Title < notes[j].Title
+	})
+	return notes, selector, true
+}
+
+// ListJobs GET /api/ai/jobs 最近的任务
+func (h *AIHandler) ListJobs(c *gin.Context) {
+	runner, ok := h.jobRunner(c)
+	if !ok {
+		return
+	}
+	list, err := runner.List(listJobsLimit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取任务失败"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// GetJob GET /api/ai/jobs/:id 任务进度及每篇笔记的状态
+func (h *AIHandler) GetJob(c *gin.Context) {
+	runner, ok := h.jobRunner(c)
+	if !ok {
+		return
+	}
+	id, ok := paramID(c, "id", false)
+	if !ok {
+		return
+	}
+	job, items, err := runner.Get(id)
+	if err != nil {
+		writeJobError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"job": job, "items": items})
+}
+
+// CancelJob POST /api/ai/jobs/:id/cancel 取消任务：未开始的笔记不再处理，正在处理的立即中断，已写回的结果保留
+func (h *AIHandler) CancelJob(c *gin.Context) {
+	runner, ok := h.jobRunner(c)
+	if !ok {
+		return
+	}
+	id, ok := paramID(c, "id", false)
+	if !ok {
+		return
+	}
+	job, err := runner.Cancel(id)
+	if err != nil {
+		writeJobError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// JobEvents GET /api/ai/jobs/:id/events 以 SSE 推送任务进度，任务结束后关闭：
+//
+//	data: {"type":"progress","job":{...},"item":{...}}  (每处理完一篇笔记；连接时先推送一次当前状态，不带 item)
+//	data: {"type":"done","job":{...}}                   (任务完成或被取消)
+func (h *AIHandler) JobEvents(c *gin.Context) {
+	runner, ok := h.jobRunner(c)
+	if !ok {
+		return
+	}
+	id, ok := paramID(c, "id", false)
+	if !ok {
+		return
+	}
+	// 先订阅再读取当前状态，避免漏掉两者之间的进度
+	updates, unsubscribe := runner.Subscribe(id)
+	defer unsubscribe()
+	job, _, err := runner.Get(id)
+	if err != nil {
+		writeJobError(c, err)
+		return
+	}
+
+	startSSE(c)
+	ticker := time.NewTicker(jobEventsCheck)
+	defer ticker.Stop()
+	p := jobs.Progress{Job: job}
+	for {
+		if jobFinished(p.Job) {
+			writeEvent(c, jobEvent{Type: "done", Progress: jobs.Progress{Job: p.Job}})
+			return
+		}
+		if writeEvent(c, jobEvent{Type: "progress", Progress: p}) != nil {
+			return
+		}
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case p = <-updates:
+		case <-ticker.C:
+			if job, _, err = runner.Get(id); err != nil {
+				writeEvent(c, gin.H{"type": "error", "error": err.Error()})
+				return
+			}
+			p = jobs.Progress{Job: job}
+		}
+	}
+}
+
+// jobEvent 进度流中的一条消息
+type jobEvent struct {
+	Type string `json:"type"`
+	jobs.Progress
+}
+
+func jobFinished(job *model.AIJob) bool {
+	return job.Status == dao.JobDone || job.Status == dao.JobCancelled
+}
+
+func writeJobError(c *gin.Context, err error) {
+	if errors.Is(err, dao.ErrJobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,266 @@
+package handler
+
+import (
+	"ai-notes/internal/ai"
+	"ai-notes/internal/dao"
+	"ai-notes/internal/diff"
+	"ai-notes/internal/model"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 模板名即动作名，出现在 URL 中
+var promptName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,99}$`)
+
+// promptStore 当前存储后端是否支持模板库，不支持时直接返回 501
+func (h *AIHandler) promptStore(c *gin.Context) (dao.PromptStore, bool) {
+	ps, ok := h.Store.(dao.PromptStore)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "当前存储后端不支持自定义模板，只能使用内置模板"})
+	}
+	return ps, ok
+}
+
+// prompt 按动作名查找模板
+func (h *AIHandler) prompt(name string) (*model.Prompt, error) {
+	return dao.FindPrompt(h.Store, name)
+}
+
+// Run 用模板执行 AI 动作，流式返回结果
+// POST /api/ai/run/:action {"content": "...", "selection": "...", "title": "...", "language": "English", "diff": true}
+func (h *AIHandler) Run(c *gin.Context) {
+	h.run(c, c.Param("action"), false)
+}
+
+// run 执行模板；withDiff 为 true (或请求中 diff 为 true) 时，输出完毕后推送一条
+// {"type":"diff","output":"...","changes":[...]} 事件：output 为应用全部修改后的整篇笔记
+// (有选区时把 AI 输出放回选区所在位置)，changes 为 content 到 output 的逐处修改；
+// 前端逐处确认后把 content、output 和接受的修改编号交给 POST /api/ai/apply 写回笔记
+func (h *AIHandler) run(c *gin.Context, action string, withDiff bool) {
+	p, err := h.prompt(action)
+	if errors.Is(err, dao.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "未知的 AI 动作: " + action})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req model.AIRunRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid JSON"})
+		return
+	}
+	var after func(string) error
+	if withDiff || req.Diff {
+		// 修改列表总是针对整篇笔记，与 /api/ai/apply 的三方合并基准一致
+		prefix, suffix, ok := selectionContext(req)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "selection 不在 content 中，无法计算修改"})
+			return
+		}
+		original := prefix + req.Selection + suffix
+		if req.Selection == "" {
+			original = req.Content
+		}
+		after = func(output string) error {
+			if req.Selection != "" {
+				output = prefix + output + suffix
+			}
+			writeEvent(c, gin.H{"type": "diff", "output": output, "changes": diff.Changes(original, output)})
+			return nil
+		}
+	}
+	chat := promptRequest(p, req, nil)
+	if key := h.cacheKey(p, chat); key != "" {
+		if text, ok := h.cached(c, key); ok {
+			startSSE(c)
+			forward(c, ai.Replay(text), nil, after)
+			return
+		}
+		// 只缓存完整的输出：出错或被取消时不会调用 after
+		inner := after
+		after = func(output string) error {
+			h.Cache.Put(key, output)
+			if inner != nil {
+				return inner(output)
+			}
+			return nil
+		}
+	}
+	h.streamChat(c, chat, nil, after)
+}
+
+// selectionContext 选区之前和之后的内容；selection_start 为选区在 content 中的起始位置 (按字符计)，
+// 未指定时取 selection 在 content 中第一次出现的位置。没有选区或没有 content 时前后均为空
+func selectionContext(req model.AIRunRequest) (prefix, suffix string, ok bool) {
+	if req.Selection == "" || req.Content == "" {
+		return "", "", true
+	}
+	start := strings.Index(req.Content, req.Selection)
+	if req.SelectionStart != nil {
+		start = -1
+		if n := *req.SelectionStart; n >= 0 && n <= utf8.RuneCountInString(req.Content) {
+			start = len(string([]rune(req.Content)[:n]))
+		}
+		if start >= 0 && !strings.HasPrefix(req.Content[start:], req.Selection) {
+			start = -1
+		}
+	}
+	if start < 0 {
+		return "", "", false
+	}
+	return req.Content[:start], req.Content[start+len(req.Selection):], true
+}
+
+// promptRequest 渲染模板，生成对话请求；请求中的模型 / 温度优先于模板中的设置
+// extra 为内置动作额外提供的变量 (如推荐标签时的 {{tags}})
+func promptRequest(p *model.Prompt, req model.AIRunRequest, extra map[string]string) ai.ChatRequest {
+	if req.Selection == "" {
+		req.Selection = req.Content
+	}
+	if req.Language == "" {
+		req.Language = "中文"
+	}
+	vars := map[string]string{
+		"content":   req.Content,
+		"selection": req.Selection,
+		"title":     req.Title,
+		"language":  req.Language,
+	}
+	for k, v := range extra {
+		vars[k] = v
+	}
+
+	chat := ai.PromptRequest(p, vars)
+	if req.Model != "" {
+		chat.Model = req.Model
+	}
+	if req.Temperature != nil {
+		chat.Temperature = req.Temperature
+	}
+	return chat
+}
+
+// ListPrompts GET /api/ai/prompts
+func (h *AIHandler) ListPrompts(c *gin.Context) {
+	ps, ok := h.Store.(dao.PromptStore)
+	if !ok {
+		c.JSON(http.StatusOK, dao.DefaultPrompts())
+		return
+	}
+	prompts, err := ps.ListPrompts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取模板失败"})
+		return
+	}
+	c.JSON(http.StatusOK, prompts)
+}
+
+// GetPrompt GET /api/ai/prompts/:name
+func (h *AIHandler) GetPrompt(c *gin.Context) {
+	p, err := h.prompt(c.Param("name"))
+	if err != nil {
+		writePromptError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// CreatePrompt POST /api/ai/prompts
+func (h *AIHandler) CreatePrompt(c *gin.Context) {
+	ps, ok := h.promptStore(c)
+	if !ok {
+		return
+	}
+	var p model.Prompt
+	if err := c.BindJSON(&p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+	p.Name = strings.TrimSpace(p.Name)
+	if !promptName.MatchString(p.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "模板名只能包含小写字母、数字、- 和 _"})
+		return
+	}
+	if !validPrompt(c, &p) {
+		return
+	}
+	if err := ps.CreatePrompt(&p); err != nil {
+		writePromptError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, p)
+}
+
+// UpdatePrompt PUT /api/ai/prompts/:name 修改模板 (名称不变)
+func (h *AIHandler) UpdatePrompt(c *gin.Context) {
+	ps, ok := h.promptStore(c)
+	if !ok {
+		return
+	}
+	var p model.Prompt
+	if err := c.BindJSON(&p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+	if !validPrompt(c, &p) {
+		return
+	}
+	name := c.Param("name")
+	if err := ps.UpdatePrompt(name, &p); err != nil {
+		writePromptError(c, err)
+		return
+	}
+	updated, err := ps.GetPrompt(name)
+	if err != nil {
+		writePromptError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeletePrompt DELETE /api/ai/prompts/:name
+func (h *AIHandler) DeletePrompt(c *gin.Context) {
+	ps, ok := h.promptStore(c)
+	if !ok {
+		return
+	}
+	if err := ps.DeletePrompt(c.Param("name")); err != nil {
+		writePromptError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+// validPrompt 检查模板内容
+func validPrompt(c *gin.Context, p *model.Prompt) bool {
+	if strings.TrimSpace(p.Template) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "模板内容不能为空"})
+		return false
+	}
+	if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "temperature 需在 0 到 2 之间"})
+		return false
+	}
+	return true
+}
+
+func writePromptError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, dao.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "模板不存在"})
+	case errors.Is(err, dao.ErrPromptExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, dao.ErrPromptBuiltin):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamCancelKey gin.Context 中保存当前 AI 请求取消函数的键，writeEvent 写出失败时用它中止上游请求
+const streamCancelKey = "ai_stream_cancel"
+
+// streamRegistry 进行中的 AI 请求，按 stream id 取消
+type streamRegistry struct {
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+func newStreamRegistry() *streamRegistry {
+	return &streamRegistry{cancels: map[string]context.CancelFunc{}}
+}
+
+func (r *streamRegistry) add(cancel context.CancelFunc) string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	id := hex.EncodeToString(b)
+	r.mu.Lock()
+	r.cancels[id] = cancel
+	r.mu.Unlock()
+	return id
+}
+
+func (r *streamRegistry) remove(id string) {
+	r.mu.Lock()
+	delete(r.cancels, id)
+	r.mu.Unlock()
+}
+
+// cancel 取消请求，id 不存在 (或已结束) 时返回 false
+func (r *streamRegistry) cancel(id string) bool {
+	r.mu.Lock()
+	cancel, ok := r.cancels[id]
+	delete(r.cancels, id)
+	r.mu.Unlock()
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// begin 为一次 AI 请求创建上下文：客户端断开、写出失败或调用 POST /api/ai/cancel 时取消，上游请求随之中止
+// stream id 通过响应头 X-Stream-Id 返回；请求结束时调用返回的 done
+func (h *AIHandler) begin(c *gin.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	id := h.streams.add(cancel)
+	c.Header("X-Stream-Id", id)
+	c.Set(streamCancelKey, cancel)
+	return ctx, func() {
+		h.streams.remove(id)
+		cancel()
+	}
+}
+
+// Cancel 取消进行中的 AI 请求
+// POST /api/ai/cancel {"stream_id": "..."}
+func (h *AIHandler) Cancel(c *gin.Context) {
+	var req struct {
+		StreamID string `json:"stream_id"`
+	}
+	if err := c.BindJSON(&req); err != nil || req.StreamID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少 stream_id"})
+		return
+	}
+	if !h.streams.cancel(req.StreamID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "请求不存在或已结束"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "cancelled"})
+}
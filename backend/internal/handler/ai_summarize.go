@@ -0,0 +1,379 @@
+package handler
+
+import (
+	"ai-notes/internal/ai"
+	"ai-notes/internal/dao"
+	"ai-notes/internal/model"
+	"ai-notes/internal/search"
+	"ai-notes/internal/semantic"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// summaryChunkRunes 每次发给模型的内容长度上限 (字符数)，超过时分段总结再合并
+	summaryChunkRunes = 6000
+	// maxSummaryNotes 一次最多总结的笔记数
+	maxSummaryNotes = 200
+	// maxExistingTags 推荐标签时最多提供给模型的已有标签数
+	maxExistingTags = 100
+)
+
+// noteDoc 一篇待处理的笔记
+type noteDoc struct {
+	Title   string
+	Folder  string
+	Content string
+}
+
+// Summarize 总结一篇笔记或整个文件夹
+// POST /api/ai/summarize {"id": 12} | {"title": "...", "folder": "..."} | {"content": "..."} | {"folder": "Work"}
+// 内容不超过 summaryChunkRunes 时直接流式返回；否则先逐段总结 (推送 {"type":"progress"} 事件)，再流式返回合并后的总结
+func (h *AIHandler) Summarize(c *gin.Context) {
+	var req model.AINoteRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+	if req.Language == "" {
+		req.Language = "中文"
+	}
+
+	var docs []noteDoc
+	if req.Content != "" || req.ID != 0 || req.Title != "" {
+		doc, ok := h.noteInput(c, req)
+		if !ok {
+			return
+		}
+		docs = []noteDoc{doc}
+	} else {
+		var ok bool
+		if docs, ok = h.folderDocs(c, req.Folder); !ok {
+			return
+		}
+	}
+
+	summarize, err := h.prompt("summarize")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	parts := packDocs(docs, len(docs) > 1)
+	if len(parts) == 1 {
+		h.streamChat(c, promptRequest(summarize, model.AIRunRequest{Content: parts[0], Title: req.Title, Language: req.Language}, nil), nil, nil)
+		return
+	}
+	merge, err := h.prompt("summarize_merge")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 分段总结耗时较长，先开始输出，之后的错误以 error 事件返回
+	ctx, done := h.begin(c)
+	defer done()
+	startSSE(c)
+	var usage ai.Usage
+	summaries := make([]string, 0, len(parts))
+	for i, part := range parts {
+		writeEvent(c, gin.H{"type": "progress", "stage": "map", "done": i, "total": len(parts)})
+		text, u, err := h.complete(ctx, promptRequest(summarize, model.AIRunRequest{Content: part, Language: req.Language}, nil))
+		if err != nil {
+			writeStreamError(c, err)
+			return
+		}
+		usage.InputTokens += u.InputTokens
+		usage.OutputTokens += u.OutputTokens
+		summaries = append(summaries, strings.TrimSpace(text))
+	}
+	writeEvent(c, gin.H{"type": "progress", "stage": "map", "done": len(parts), "total": len(parts)})
+
+	// 分段总结合起来仍然太长时逐层合并，直到能一次发给模型
+	for {
+		groups := packTexts(summaries)
+		if len(groups) == 1 || len(groups) == len(summaries) {
+			break
+		}
+		merged := make([]string, 0, len(groups))
+		for i, g := range groups {
+			writeEvent(c, gin.H{"type": "progress", "stage": "reduce", "done": i, "total": len(groups)})
+			text, u, err := h.complete(ctx, promptRequest(merge, model.AIRunRequest{Content: g, Language: req.Language}, nil))
+			if err != nil {
+				writeStreamError(c, err)
+				return
+			}
+			usage.InputTokens += u.InputTokens
+			usage.OutputTokens += u.OutputTokens
+			merged = append(merged, strings.TrimSpace(text))
+		}
+		summaries = merged
+	}
+
+	stream, err := h.Provider.ChatStream(ctx, promptRequest(merge, model.AIRunRequest{Content: strings.Join(summaries, "\n\n---\n\n"), Language: req.Language}, nil))
+	if err != nil {
+		writeStreamError(c, err)
+		return
+	}
+	defer stream.Close()
+	forward(c, stream, &usage, nil)
+}
+
+// Title 为笔记拟定标题
+// POST /api/ai/title {"content": "..."} -> {"titles": ["...", "..."], "usage": {...}}
+func (h *AIHandler) Title(c *gin.Context) {
+	var req model.AINoteRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+	doc, ok := h.noteInput(c, req)
+	if !ok {
+		return
+	}
+
+	var out struct {
+		Titles []string `json:"titles"`
+	}
+	usage, ok := h.completeJSON(c, "title", doc.Content, req.Language, nil, &out)
+	if !ok {
+		return
+	}
+	titles := []string{}
+	for _, t := range out.Titles {
+		if t = strings.TrimSpace(t); t != "" {
+			titles = append(titles, t)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"titles": titles, "usage": usage})
+}
+
+// SuggestTags 为笔记推荐标签，优先复用已有标签
+// POST /api/ai/tags {"content": "..."} -> {"tags": ["go", "work/infra"], "new": ["work/infra"], "usage": {...}}
+// new 为尚未使用过的标签 (不支持标签的后端无法判断，返回空)
+func (h *AIHandler) SuggestTags(c *gin.Context) {
+	var req model.AINoteRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+	doc, ok := h.noteInput(c, req)
+	if !ok {
+		return
+	}
+
+	existing := map[string]bool{}
+	var names []string
+	ts, hasTags := h.Store.(dao.TagStore)
+	if hasTags {
+		counts, err := ts.ListTags()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取标签失败"})
+			return
+		}
+		for _, t := range counts {
+			existing[t.Name] = true
+			if len(names) < maxExistingTags {
+				names = append(names, t.Name)
+			}
+		}
+	}
+	known := "无"
+	if len(names) > 0 {
+		known = strings.Join(names, ", ")
+	}
+
+	var out struct {
+		Tags []string `json:"tags"`
+	}
+	usage, ok := h.completeJSON(c, "tags", doc.Content, req.Language, map[string]string{"tags": known}, &out)
+	if !ok {
+		return
+	}
+	tags, fresh := []string{}, []string{}
+	seen := map[string]bool{}
+	for _, t := range out.Tags {
+		t, valid := dao.CleanTag(t)
+		if !valid || seen[t] {
+			continue
+		}
+		seen[t] = true
+		tags = append(tags, t)
+		if hasTags && !existing[t] {
+			fresh = append(fresh, t)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"tags": tags, "new": fresh, "usage": usage})
+}
+
+// completeJSON 用返回 JSON 的模板调用模型并解析结果；出错时已写出响应，返回 false
+// 只取内容开头的 summaryChunkRunes 个字符，拟标题 / 推荐标签不需要全文
+func (h *AIHandler) completeJSON(c *gin.Context, action, content, language string, extra map[string]string, out any) (ai.Usage, bool) {
+	p, err := h.prompt(action)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return ai.Usage{}, false
+	}
+	if language == "" {
+		language = "与笔记内容相同的语言"
+	}
+	if utf8.RuneCountInString(content) > summaryChunkRunes {
+		content = string([]rune(content)[:summaryChunkRunes])
+	}
+	chat := promptRequest(p, model.AIRunRequest{Content: content, Language: language}, extra)
+	key := h.cacheKey(p, chat)
+	var text string
+	var usage ai.Usage
+	hit := false
+	if key != "" {
+		text, hit = h.cached(c, key)
+	}
+	if !hit {
+		ctx, done := h.begin(c)
+		defer done()
+		if text, usage, err = h.complete(ctx, chat); err != nil {
+			writeAIError(c, err)
+			return usage, false
+		}
+	}
+	if err := json.Unmarshal([]byte(extractJSON(text)), out); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "模型返回的不是有效的 JSON", "raw": text})
+		return usage, false
+	}
+	if key != "" && !hit {
+		h.Cache.Put(key, text)
+	}
+	return usage, true
+}
+
+// extractJSON 从模型输出中取出 JSON 对象：去掉 ```json 代码块和前后的说明文字
+func extractJSON(text string) string {
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return text
+	}
+	return text[start : end+1]
+}
+
+// noteInput 取得请求指定的笔记：优先使用 Content，其次按 ID、Title + Folder 读取；出错时已写出响应
+func (h *AIHandler) noteInput(c *gin.Context, req model.AINoteRequest) (noteDoc, bool) {
+	switch {
+	case req.Content != "":
+		return noteDoc{Title: req.Title, Folder: req.Folder, Content: req.Content}, true
+	case req.ID != 0:
+		is, ok := h.Store.(dao.IDStore)
+		if !ok {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": "当前存储后端不支持按 ID 访问"})
+			return noteDoc{}, false
+		}
+		note, err := is.GetNoteByID(req.ID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "笔记不存在"})
+			return noteDoc{}, false
+		}
+		return noteDoc{Title: note.Title, Folder: note.Folder, Content: note.Content}, true
+	case req.Title != "":
+		content, err := h.Store.GetNote(req.Title, req.Folder)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "笔记不存在"})
+			return noteDoc{}, false
+		}
+		return noteDoc{Title: req.Title, Folder: req.Folder, Content: content}, true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "缺少笔记内容"})
+	return noteDoc{}, false
+}
+
+// folderDocs 文件夹 (含子文件夹) 下的所有笔记，按路径排序；出错时已写出响应
+func (h *AIHandler) folderDocs(c *gin.Context, folder string) ([]noteDoc, bool) {
+	notes, err := h.Store.ListNotes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取列表失败"})
+		return nil, false
+	}
+	var matched []model.NoteSummary
+	for _, n := range notes {
+		if search.MatchFolder(n.Folder, folder) {
+			matched = append(matched, n)
+		}
+	}
+	if len(matched) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "文件夹中没有笔记"})
+		return nil, false
+	}
+	if len(matched) > maxSummaryNotes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("笔记太多 (%d 篇)，一次最多总结 %d 篇，请选择更小的文件夹", len(matched), maxSummaryNotes)})
+		return nil, false
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if matched[i].Folder != matched[j].Folder {
+			return matched[i].Folder < matched[j].Folder
+		}
+		return matched[i].Title < matched[j].Title
+	})
+
+	docs := make([]noteDoc, 0, len(matched))
+	for _, n := range matched {
+		content, err := h.Store.GetNote(n.Title, n.Folder)
+		if err != nil {
+			continue
+		}
+		docs = append(docs, noteDoc{Title: n.Title, Folder: n.Folder, Content: content})
+	}
+	return docs, true
+}
+
+// packDocs 把笔记拼成若干段，每段不超过 summaryChunkRunes；过长的笔记按标题切开
+// withTitle 为 true 时 (总结多篇笔记) 每篇前面加上 "## 《标题》"，方便模型区分
+func packDocs(docs []noteDoc, withTitle bool) []string {
+	var pieces []string
+	for _, d := range docs {
+		head := ""
+		if withTitle {
+			head = fmt.Sprintf("## 《%s》\n", d.Title)
+		}
+		text := head + d.Content
+		if utf8.RuneCountInString(text) <= summaryChunkRunes {
+			pieces = append(pieces, text)
+			continue
+		}
+		for _, ch := range semantic.SplitChunks(d.Content) {
+			piece := ch.Text
+			if ch.Heading != "" {
+				piece = "### " + ch.Heading + "\n" + piece
+			}
+			pieces = append(pieces, head+piece)
+		}
+	}
+	return packTexts(pieces)
+}
+
+// packTexts 依次合并相邻的文本，每组不超过 summaryChunkRunes (单段本身超长时独占一组)
+func packTexts(texts []string) []string {
+	var groups []string
+	var cur strings.Builder
+	n := 0
+	for _, t := range texts {
+		size := utf8.RuneCountInString(t)
+		if n > 0 && n+size > summaryChunkRunes {
+			groups = append(groups, cur.String())
+			cur.Reset()
+			n = 0
+		}
+		if n > 0 {
+			cur.WriteString("\n\n")
+		}
+		cur.WriteString(t)
+		n += size + 2
+	}
+	if n > 0 {
+		groups = append(groups, cur.String())
+	}
+	return groups
+}
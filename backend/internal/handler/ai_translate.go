@@ -0,0 +1,276 @@
+package handler
+
+import (
+	"ai-notes/internal/ai"
+	"ai-notes/internal/model"
+	"ai-notes/internal/semantic"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// translateChunkRunes 每次翻译的内容长度上限 (字符数)；译文长度与原文相近，太长容易超出模型的输出上限
+const translateChunkRunes = 3000
+
+// langSuffixes 另存译文时标题后缀的常见写法，其余语言直接使用目标语言原文
+var langSuffixes = map[string]string{
+	"english": "EN", "en": "EN", "英文": "EN", "英语": "EN",
+	"中文": "中文", "chinese": "中文", "zh": "中文", "简体中文": "中文",
+	"日本語": "JA", "japanese": "JA", "ja": "JA", "日语": "JA", "日文": "JA",
+}
+
+// mdSegment 笔记的一段：Verbatim 为 true 的 (代码块、frontmatter) 原样保留，不发给模型
+type mdSegment struct {
+	Text     string
+	Verbatim bool
+}
+
+// Translate 翻译笔记，保留 Markdown 结构
+// POST /api/ai/translate {"id": 12, "source": "中文", "target": "English", "save": true}
+// 笔记按章节分段翻译，响应为 SSE：每段先发送 {"type":"section","index":i,"total":n,"source":"原文"}，
+// 之后是这一段译文的 delta 事件 (代码块直接作为 delta 原样返回)，前端可据此逐段对照显示；
+// 最后是合计的 usage，save 为 true 时还有 {"type":"saved","title":"...","folder":"..."}，以 done 或 error 结束；
+// 译文标题与已有笔记相同且没有指定 overwrite 时，翻译前直接返回 409
+func (h *AIHandler) Translate(c *gin.Context) {
+	var req model.TranslateRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+	req.Target = strings.TrimSpace(req.Target)
+	if req.Target == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少目标语言"})
+		return
+	}
+	if req.Source == "" {
+		req.Source = "原文的语言"
+	}
+	doc, ok := h.noteInput(c, model.AINoteRequest{Content: req.Content, ID: req.ID, Title: req.Title, Folder: req.Folder})
+	if !ok {
+		return
+	}
+	saveTitle := strings.TrimSpace(req.SaveTitle)
+	if req.Save && saveTitle == "" {
+		if doc.Title == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "另存译文需要原笔记标题或 save_title"})
+			return
+		}
+		saveTitle = translatedTitle(doc.Title, req.Target)
+	}
+	if req.Save && saveTitle == doc.Title {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "译文标题不能与原笔记相同"})
+		return
+	}
+	if req.Save && !req.Overwrite && h.noteExists(doc.Folder, saveTitle) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("笔记 %s 已存在，如需覆盖请指定 overwrite", saveTitle), "title": saveTitle})
+		return
+	}
+	p, err := h.prompt("translate")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	parts := translateParts(doc.Content)
+	ctx, done := h.begin(c)
+	defer done()
+	startSSE(c)
+	var out strings.Builder
+	var usage ai.Usage
+	emit := func(text string) error {
+		if text == "" {
+			return nil
+		}
+		out.WriteString(text)
+		return writeEvent(c, ai.Event{Type: ai.EventDelta, Text: text})
+	}
+	for i, part := range parts {
+		writeEvent(c, gin.H{"type": "section", "index": i, "total": len(parts), "source": part.Text})
+		body := strings.TrimSpace(part.Text)
+		if part.Verbatim || body == "" {
+			if emit(part.Text) != nil {
+				return
+			}
+			continue
+		}
+
+		// 模型通常不保留首尾空行，原样补上，保证段与段之间的 Markdown 结构不变
+		lead := part.Text[:strings.Index(part.Text, body)]
+		trail := part.Text[len(lead)+len(body):]
+		emit(lead)
+		stream, err := h.Provider.ChatStream(ctx, promptRequest(p, model.AIRunRequest{Content: body, Title: doc.Title, Language: req.Target}, map[string]string{"source": req.Source}))
+		if err != nil {
+			writeStreamError(c, err)
+			return
+		}
+		err = relay(stream, emit, &usage)
+		stream.Close()
+		if err != nil {
+			writeStreamError(c, err)
+			return
+		}
+		emit(trail)
+	}
+	writeEvent(c, ai.Event{Type: ai.EventUsage, Usage: &usage})
+
+	if req.Save {
+		// 翻译期间可能有同名笔记被创建
+		if !req.Overwrite && h.noteExists(doc.Folder, saveTitle) {
+			writeEvent(c, ai.Event{Type: ai.EventError, Error: fmt.Sprintf("笔记 %s 已存在，译文没有保存", saveTitle)})
+			return
+		}
+		if err := h.Store.SaveNote(saveTitle, doc.Folder, out.String()); err != nil {
+			writeEvent(c, ai.Event{Type: ai.EventError, Error: "保存译文失败: " + err.Error()})
+			return
+		}
+		writeEvent(c, gin.H{"type": "saved", "title": saveTitle, "folder": doc.Folder})
+	}
+	writeEvent(c, ai.Event{Type: ai.EventDone})
+}
+
+// noteExists 文件夹下是否已有该标题的笔记
+func (h *AIHandler) noteExists(folder, title string) bool {
+	_, err := h.Store.GetNote(title, folder)
+	return err == nil
+}
+
+// relay 把一次模型输出的文字交给 emit，token 用量累加到 usage；emit 出错 (客户端断开) 时停止
+func relay(stream ai.Stream, emit func(string) error, usage *ai.Usage) error {
+	for {
+		ev, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch ev.Type {
+		case ai.EventDelta:
+			if err := emit(ev.Text); err != nil {
+				return err
+			}
+		case ai.EventUsage:
+			usage.InputTokens += ev.Usage.InputTokens
+			usage.OutputTokens += ev.Usage.OutputTokens
+		}
+	}
+}
+
+// translatedTitle 译文笔记的标题，如 "部署手册 (EN)"
+func translatedTitle(title, target string) string {
+	suffix, ok := langSuffixes[strings.ToLower(target)]
+	if !ok {
+		suffix = target
+	}
+	return fmt.Sprintf("%s (%s)", title, suffix)
+}
+
+// translateParts 把笔记切成翻译的单位：代码块和 frontmatter 单独成段原样保留，
+// 其余内容按章节合并，每段不超过 translateChunkRunes
+func translateParts(content string) []mdSegment {
+	var parts []mdSegment
+	for _, seg := range splitMarkdown(content) {
+		if seg.Verbatim {
+			parts = append(parts, seg)
+			continue
+		}
+		for _, text := range packSections(seg.Text) {
+			parts = append(parts, mdSegment{Text: text})
+		}
+	}
+	return parts
+}
+
+// splitMarkdown 分出代码块和开头的 frontmatter，拼接各段的 Text 即为原文
+func splitMarkdown(content string) []mdSegment {
+	var segs []mdSegment
+	var buf strings.Builder
+	flush := func(verbatim bool) {
+		if buf.Len() > 0 {
+			segs = append(segs, mdSegment{Text: buf.String(), Verbatim: verbatim})
+			buf.Reset()
+		}
+	}
+
+	lines := strings.SplitAfter(content, "\n")
+	start := 0
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == "---" {
+		for i := 1; i < len(lines); i++ {
+			if t := strings.TrimSpace(lines[i]); t == "---" || t == "..." {
+				buf.WriteString(strings.Join(lines[:i+1], ""))
+				flush(true)
+				start = i + 1
+				break
+			}
+		}
+	}
+
+	fence := ""
+	for _, line := range lines[start:] {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			buf.WriteString(line)
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+				flush(true)
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			flush(false)
+			fence = trimmed[:3]
+		}
+		buf.WriteString(line)
+	}
+	// 没有闭合的代码块也原样保留
+	flush(fence != "")
+	return segs
+}
+
+// packSections 按标题把文字切成章节，相邻的章节合并到 translateChunkRunes 以内；过长的章节再按空行切分
+func packSections(text string) []string {
+	var sections []string
+	var buf strings.Builder
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if level, _ := semantic.ParseHeading(strings.TrimSpace(line)); level > 0 && buf.Len() > 0 {
+			sections = append(sections, buf.String())
+			buf.Reset()
+		}
+		buf.WriteString(line)
+	}
+	if buf.Len() > 0 {
+		sections = append(sections, buf.String())
+	}
+
+	var pieces []string
+	for _, s := range sections {
+		if utf8.RuneCountInString(s) <= translateChunkRunes {
+			pieces = append(pieces, s)
+			continue
+		}
+		pieces = append(pieces, strings.SplitAfter(s, "\n\n")...)
+	}
+
+	// 合并时直接拼接，不插入额外的空行，各段原有的换行保持不变
+	var groups []string
+	var cur strings.Builder
+	n := 0
+	for _, p := range pieces {
+		size := utf8.RuneCountInString(p)
+		if n > 0 && n+size > translateChunkRunes {
+			groups = append(groups, cur.String())
+			cur.Reset()
+			n = 0
+		}
+		cur.WriteString(p)
+		n += size
+	}
+	if n > 0 {
+		groups = append(groups, cur.String())
+	}
+	return groups
+}
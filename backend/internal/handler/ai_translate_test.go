@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"ai-notes/internal/dao/daotest"
+	"ai-notes/internal/usage"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTranslateExistingTarget(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := daotest.New(t)
+	for title, content := range map[string]string{"n": "你好", "n (EN)": "old"} {
+		if err := s.SaveNote(title, "", content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ah := NewAIHandler(&fixedProvider{output: "Hello"}, s, nil, nil, usage.NewMeter(nil, 0, 0), nil, nil)
+	r := gin.New()
+	r.POST("/api/ai/translate", ah.Translate)
+
+	// 译文笔记已存在时不翻译、不覆盖
+	w := postJSON(r, "/api/ai/translate", gin.H{"title": "n", "target": "English", "save": true})
+	if w.Code != http.StatusConflict {
+		t.Fatalf("译文笔记已存在时返回 %d，期望 409: %s", w.Code, w.Body)
+	}
+	if got, _ := s.GetNote("n (EN)", ""); got != "old" {
+		t.Errorf("已有笔记被改成了 %q", got)
+	}
+
+	w = postJSON(r, "/api/ai/translate", gin.H{"title": "n", "target": "English", "save": true, "overwrite": true})
+	if w.Code != http.StatusOK {
+		t.Fatalf("指定覆盖时返回 %d: %s", w.Code, w.Body)
+	}
+	if got, _ := s.GetNote("n (EN)", ""); got != "Hello" {
+		t.Errorf("覆盖后内容 %q，期望 %q", got, "Hello")
+	}
+}
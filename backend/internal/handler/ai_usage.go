@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"ai-notes/internal/ai"
+	"ai-notes/internal/usage"
+	"errors"
+	"math"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limit 调用大模型的接口前检查请求频率和每日预算，超出时返回 429；
+// 通过时把动作名和客户端放入请求的 context，记录用量时使用 (见 ai.Metered)
+func (h *AIHandler) Limit(c *gin.Context) {
+	client := c.ClientIP()
+	if err := h.Meter.Allow(client); err != nil {
+		if err.RetryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(err.RetryAfter.Seconds()))))
+		}
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Message})
+		return
+	}
+	action := c.Param("action")
+	if action == "" {
+		action = path.Base(c.FullPath()) // /api/ai/polish -> polish
+	}
+	c.Request = c.Request.WithContext(ai.WithCall(c.Request.Context(), ai.Call{Action: action, Client: client}))
+	c.Next()
+}
+
+// Usage AI 用量统计
+// GET /api/ai/usage?period=day&days=30 | ?period=month&months=12
+func (h *AIHandler) Usage(c *gin.Context) {
+	period := c.DefaultQuery("period", "day")
+	now := time.Now()
+	var from time.Time
+	switch period {
+	case "day":
+		days, _ := strconv.Atoi(c.DefaultQuery("days", "30"))
+		if days < 1 || days > 366 {
+			days = 30
+		}
+		y, m, d := now.Date()
+		from = time.Date(y, m, d-days+1, 0, 0, 0, 0, now.Location())
+	case "month":
+		months, _ := strconv.Atoi(c.DefaultQuery("months", "12"))
+		if months < 1 || months > 60 {
+			months = 12
+		}
+		y, m, _ := now.Date()
+		from = time.Date(y, m-time.Month(months-1), 1, 0, 0, 0, 0, now.Location())
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period 只能是 day 或 month"})
+		return
+	}
+
+	buckets, err := h.Meter.Summary(period, from)
+	if errors.Is(err, usage.ErrNoStore) {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取用量失败"})
+		return
+	}
+	used, budget := h.Meter.Today()
+	c.JSON(http.StatusOK, gin.H{
+		"period":  period,
+		"today":   gin.H{"tokens": used, "budget": budget},
+		"buckets": buckets,
+	})
+}
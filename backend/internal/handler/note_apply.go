@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"ai-notes/internal/diff"
+	"ai-notes/internal/model"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ApplyAIEdit 把 AI 修改中被接受的部分写回笔记
+// POST /api/ai/apply {"title": "...", "folder": "...", "original": "修改前的整篇笔记", "output": "diff 事件中的 output", "accept": [0, 2]}
+// 服务器重新计算 original -> output 的修改列表 (与 diff 事件中的相同)，只应用 accept 中的修改；
+// 笔记在此期间被修改过时与 saveWithMerge 一样做三方合并。写入会产生一个独立的历史版本，可以单独撤销
+func (h *NoteHandler) ApplyAIEdit(c *gin.Context) {
+	var req model.AIApplyRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if req.Title == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少笔记标题"})
+		return
+	}
+	if len(req.Accept) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "没有接受任何修改"})
+		return
+	}
+
+	content, err := diff.ApplyChanges(req.Original, diff.Changes(req.Original, req.Output), req.Accept)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.saveWithMerge(c, &model.NoteRequest{
+		Title:       req.Title,
+		Folder:      req.Folder,
+		Content:     content,
+		BaseContent: &req.Original,
+	}, true)
+}
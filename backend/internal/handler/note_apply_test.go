@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"ai-notes/internal/ai"
+	"ai-notes/internal/dao/daotest"
+	"ai-notes/internal/model"
+	"ai-notes/internal/usage"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fixedProvider 总是输出同一段文本的假服务商
+type fixedProvider struct {
+	output string
+}
+
+func (p *fixedProvider) Name() string         { return "fake" }
+func (p *fixedProvider) DefaultModel() string { return "fake-model" }
+
+func (p *fixedProvider) ChatStream(ctx context.Context, req ai.ChatRequest) (ai.Stream, error) {
+	return ai.Replay(p.output), nil
+}
+
+func (p *fixedProvider) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	return nil, ai.ErrNotSupported
+}
+
+func (p *fixedProvider) ListModels(ctx context.Context) ([]string, error) {
+	return nil, ai.ErrNotSupported
+}
+
+// diffEvent 从 SSE 响应中取出 diff 事件
+func diffEvent(t *testing.T, body string) (output string, changes []int) {
+	t.Helper()
+	for _, line := range strings.Split(body, "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		var ev struct {
+			Type    string `json:"type"`
+			Output  string `json:"output"`
+			Changes []struct {
+				Index int `json:"index"`
+			} `json:"changes"`
+		}
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			t.Fatalf("解析事件 %q: %v", data, err)
+		}
+		if ev.Type == "diff" {
+			for _, ch := range ev.Changes {
+				changes = append(changes, ch.Index)
+			}
+			return ev.Output, changes
+		}
+	}
+	t.Fatalf("响应中没有 diff 事件: %s", body)
+	return "", nil
+}
+
+func postJSON(r http.Handler, path string, body any) *httptest.ResponseRecorder {
+	data, _ := json.Marshal(body)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data)))
+	return w
+}
+
+func TestApplySelectionEdit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const content = "A\nC\nB\nC\nD"
+	tests := []struct {
+		name  string
+		start *int
+		want  string
+	}{
+		{"第一次出现", nil, "A\nC2\nB\nC\nD"},
+		{"指定位置", intPtr(6), "A\nC\nB\nC2\nD"},
+	}
+	for _, tt := range tests {
+		s := daotest.New(t)
+		if err := s.SaveNote("n", "", content); err != nil {
+			t.Fatal(err)
+		}
+		ah := NewAIHandler(&fixedProvider{output: "C2"}, s, nil, nil, usage.NewMeter(nil, 0, 0), nil, nil)
+		nh := NewNoteHandler(s, nil, nil)
+		r := gin.New()
+		r.POST("/api/ai/polish", ah.Polish)
+		r.POST("/api/ai/apply", nh.ApplyAIEdit)
+
+		w := postJSON(r, "/api/ai/polish", gin.H{"title": "n", "content": content, "selection": "C", "selection_start": tt.start})
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: 润色返回 %d: %s", tt.name, w.Code, w.Body)
+		}
+		output, changes := diffEvent(t, w.Body.String())
+		if output != tt.want {
+			t.Errorf("%s: diff 事件的 output = %q，期望 %q", tt.name, output, tt.want)
+		}
+
+		w = postJSON(r, "/api/ai/apply", gin.H{"title": "n", "original": content, "output": output, "accept": changes})
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: 写回返回 %d: %s", tt.name, w.Code, w.Body)
+		}
+		got, err := s.GetNote("n", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: 写回后内容 %q，期望 %q", tt.name, got, tt.want)
+		}
+
+		// 选区不在 content 中时无法计算修改，不调用模型
+		if w := postJSON(r, "/api/ai/polish", gin.H{"content": content, "selection": "X"}); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: 选区不在内容中时返回 %d，期望 400", tt.name, w.Code)
+		}
+	}
+}
+
+func TestSelectionContext(t *testing.T) {
+	tests := []struct {
+		name               string
+		content, selection string
+		start              *int
+		prefix, suffix     string
+		ok                 bool
+	}{
+		{"没有选区", "abc", "", nil, "", "", true},
+		{"第一次出现", "xaxa", "a", nil, "x", "xa", true},
+		{"按字符计的位置", "中文中文", "中", intPtr(2), "中文", "文", true},
+		{"位置与选区不符", "abc", "b", intPtr(0), "", "", false},
+		{"位置越界", "abc", "b", intPtr(9), "", "", false},
+		{"选区不在内容中", "abc", "x", nil, "", "", false},
+	}
+	for _, tt := range tests {
+		req := model.AIRunRequest{Content: tt.content, Selection: tt.selection, SelectionStart: tt.start}
+		prefix, suffix, ok := selectionContext(req)
+		if ok != tt.ok || prefix != tt.prefix || suffix != tt.suffix {
+			t.Errorf("%s: 得到 (%q, %q, %v)，期望 (%q, %q, %v)", tt.name, prefix, suffix, ok, tt.prefix, tt.suffix, tt.ok)
+		}
+	}
+}
+
+func intPtr(n int) *int { return &n }
@@ -3,28 +3,58 @@ package handler
 import (
 	"ai-notes/internal/model" // 请确认你的 go.mod 名字，如果是 inkflow 请改为 inkflow
 	"ai-notes/internal/dao"
+	"ai-notes/internal/search"
+	"ai-notes/internal/semantic"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
 type NoteHandler struct {
-	Store *dao.NoteDAO
+	Store dao.NoteStore
+	Index *search.Indexer // 全文搜索索引
+	// Semantic 向量索引，未配置向量模型时为 nil
+	Semantic *semantic.Indexer
 }
 
-func NewNoteHandler(s *dao.NoteDAO) *NoteHandler {
-	return &NoteHandler{Store: s}
+func NewNoteHandler(s dao.NoteStore, ix *search.Indexer, sem *semantic.Indexer) *NoteHandler {
+	return &NoteHandler{Store: s, Index: ix, Semantic: sem}
 }
 
 // List 获取列表
 // 返回结构示例: [{"title": "笔记A", "folder": "工作"}, {"title": "笔记B", "folder": ""}]
+// 可选过滤：?tag=work 只返回带该标签 (或其下级标签) 的笔记，?folder=工作 只返回该文件夹下的笔记 (空值表示根目录)，两者可组合
 func (h *NoteHandler) List(c *gin.Context) {
-	// Store 层需要返回包含 Folder 信息的列表
-	notes, err := h.Store.ListNotes()
+	var notes []model.NoteSummary
+	var err error
+	if tag := c.Query("tag"); tag != "" {
+		ts, ok := h.tagStore(c)
+		if !ok {
+			return
+		}
+		notes, err = ts.ListNotesByTag(tag)
+	} else {
+		// Store 层需要返回包含 Folder 信息的列表
+		notes, err = h.Store.ListNotes()
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取列表失败"})
 		return
 	}
+
+	if folder, ok := c.GetQuery("folder"); ok {
+		folder = strings.Trim(folder, "/")
+		filtered := []model.NoteSummary{}
+		for _, n := range notes {
+			if n.Folder == folder {
+				filtered = append(filtered, n)
+			}
+		}
+		notes = filtered
+	}
 	c.JSON(http.StatusOK, notes)
 }
 
@@ -39,6 +69,24 @@ func (h *NoteHandler) Get(c *gin.Context) {
 		return
 	}
 
+	// 支持版本号的后端额外返回 version / ETag，前端保存时带回用于冲突检测
+	if vs, ok := h.Store.(dao.VersionedStore); ok {
+		note, err := vs.GetNoteVersioned(title, folder)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "读取失败"})
+			return
+		}
+		c.Header("ETag", etag(note.Version))
+		c.JSON(http.StatusOK, gin.H{
+			"title":   title,
+			"folder":  folder,
+			"content": note.Content,
+			"version": note.Version,
+			"hash":    dao.ContentHash(note.Content),
+		})
+		return
+	}
+
 	// 传递 folder 给 Store
 	content, err := h.Store.GetNote(title, folder)
 	if err != nil {
@@ -50,6 +98,7 @@ func (h *NoteHandler) Get(c *gin.Context) {
 		"title":   title,
 		"folder":  folder,
 		"content": content,
+		"hash":    dao.ContentHash(content),
 	})
 }
 
@@ -61,6 +110,48 @@ func (h *NoteHandler) Save(c *gin.Context) {
 		return
 	}
 
+	// 带了编辑基准 (base_content / base_hash) 时尝试三方合并
+	if req.BaseContent != nil || req.BaseHash != "" {
+		h.saveWithMerge(c, &req, false)
+		return
+	}
+
+	// 带了期望版本号 (If-Match 头或 body.version) 时做乐观并发检查，版本过期返回 409 和服务器上的当前内容
+	// 不支持版本号的后端无法检查，返回 412 而不是直接覆盖
+	expected, hasExpected := req.Version, req.Version != nil
+	if v, ok := parseIfMatch(c.GetHeader("If-Match")); ok {
+		expected, hasExpected = &v, true
+	}
+	vs, versioned := h.Store.(dao.VersionedStore)
+	if hasExpected && !versioned {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "当前存储后端不支持版本号，无法按 If-Match / version 检查，请改用 base_content 或不带版本号保存"})
+		return
+	}
+	if hasExpected {
+		note, err := vs.SaveNoteIfVersion(req.Title, req.Folder, req.Content, *expected)
+		var conflict *dao.VersionConflictError
+		if errors.As(err, &conflict) {
+			resp := gin.H{"error": conflict.Error()}
+			if conflict.Current != nil {
+				resp["current"] = gin.H{
+					"title":   req.Title,
+					"folder":  req.Folder,
+					"content": conflict.Current.Content,
+					"version": conflict.Current.Version,
+				}
+			}
+			c.JSON(http.StatusConflict, resp)
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("ETag", etag(note.Version))
+		c.JSON(http.StatusOK, gin.H{"status": "ok", "version": note.Version})
+		return
+	}
+
 	// 🔥 新增：将 req.Folder 传给 Store
 	if err := h.Store.SaveNote(req.Title, req.Folder, req.Content); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -69,6 +160,22 @@ func (h *NoteHandler) Save(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
 
+// etag 由版本号生成 ETag
+func etag(version uint) string {
+	return `"` + strconv.FormatUint(uint64(version), 10) + `"`
+}
+
+// parseIfMatch 解析 If-Match 头中的版本号，支持 "3" 和 W/"3"；"*" 或无法解析时视为未提供
+func parseIfMatch(header string) (uint, bool) {
+	v := strings.TrimPrefix(strings.TrimSpace(header), "W/")
+	v = strings.Trim(v, `"`)
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return uint(n), true
+}
+
 // Delete 删除笔记
 func (h *NoteHandler) Delete(c *gin.Context) {
 	title := c.Query("title")
@@ -83,6 +190,10 @@ func (h *NoteHandler) Delete(c *gin.Context) {
 
 	// 传递 folder 给 Store
 	if err := h.Store.DeleteNote(title, folder); err != nil {
+		if errors.Is(err, dao.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -122,6 +233,7 @@ func (h *NoteHandler) Move(c *gin.Context) {
 }
 
 // RenameFolder 重命名文件夹
+// oldName / newName 为完整路径，newName 可以位于其他上级下 (同时移动)
 func (h *NoteHandler) RenameFolder(c *gin.Context) {
 	var req struct {
 		OldName string `json:"oldName"`
@@ -134,13 +246,39 @@ func (h *NoteHandler) RenameFolder(c *gin.Context) {
 	}
 
 	if err := h.Store.RenameFolder(req.OldName, req.NewName); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeFolderError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"status": "folder renamed"})
 }
 
-// CreateFolder 创建空文件夹
+// MoveFolder 把文件夹 (连同子文件夹和笔记) 移动到 newParent 下，newParent 为空表示顶级
+func (h *NoteHandler) MoveFolder(c *gin.Context) {
+	var req struct {
+		Path      string `json:"path"`
+		NewParent string `json:"newParent"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if err := h.Store.MoveFolder(req.Path, req.NewParent); err != nil {
+		writeFolderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "folder moved"})
+}
+
+// writeFolderError 形成环的移动是请求本身有误，返回 400
+func writeFolderError(c *gin.Context, err error) {
+	if errors.Is(err, dao.ErrFolderCycle) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// CreateFolder 创建空文件夹，name 可以是 "Work/ProjectA" 这样的路径
 func (h *NoteHandler) CreateFolder(c *gin.Context) {
 	var req struct {
 		Name string `json:"name"`
@@ -166,7 +304,17 @@ func (h *NoteHandler) ListFolders(c *gin.Context) {
 	c.JSON(http.StatusOK, folders)
 }
 
-// DeleteFolder 删除文件夹
+// FolderTree 获取文件夹树
+func (h *NoteHandler) FolderTree(c *gin.Context) {
+	tree, err := h.Store.ListFolderTree()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, tree)
+}
+
+// DeleteFolder 删除文件夹 (包括子文件夹)
 func (h *NoteHandler) DeleteFolder(c *gin.Context) {
 	name := c.Query("name")
 	if name == "" {
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"ai-notes/internal/dao"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSaveVersionOnUnversionedStore(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := dao.NewFileNoteStore(t.TempDir())
+	if err := s.SaveNote("n", "", "old"); err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.POST("/api/notes", NewNoteHandler(s, nil, nil).Save)
+
+	// 文件存储不支持版本号，带了期望版本的写入不能直接覆盖
+	w := postJSON(r, "/api/notes", gin.H{"title": "n", "content": "new", "version": 1})
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("带 version 时返回 %d，期望 412", w.Code)
+	}
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/notes", strings.NewReader(`{"title":"n","content":"new"}`))
+	req.Header.Set("If-Match", `"1"`)
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("带 If-Match 时返回 %d，期望 412", w.Code)
+	}
+	if got, _ := s.GetNote("n", ""); got != "old" {
+		t.Errorf("笔记被改成了 %q", got)
+	}
+
+	// 不带版本号照常保存
+	if w := postJSON(r, "/api/notes", gin.H{"title": "n", "content": "new"}); w.Code != http.StatusOK {
+		t.Errorf("不带版本号时返回 %d: %s", w.Code, w.Body)
+	}
+	if got, _ := s.GetNote("n", ""); got != "new" {
+		t.Errorf("保存后内容 %q，期望 %q", got, "new")
+	}
+}
@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"ai-notes/internal/dao"
+	"ai-notes/internal/model"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// v2 接口：按 Note.ID / Folder.ID 寻址，重命名、移动不影响正在进行的请求和笔记间的链接
+// v1 (title + folder 查询参数) 接口保持不变
+
+// idStore 当前存储后端是否支持 ID 寻址，不支持时直接返回 501
+func (h *NoteHandler) idStore(c *gin.Context) (dao.IDStore, bool) {
+	is, ok := h.Store.(dao.IDStore)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "当前存储后端不支持按 ID 访问"})
+	}
+	return is, ok
+}
+
+// paramID 解析路径参数中的 ID，allowZero 为 true 时允许 0 (表示根目录)
+func paramID(c *gin.Context, key string, allowZero bool) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(key), 10, 64)
+	if err != nil || (id == 0 && !allowZero) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "非法的 " + key})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// writeStoreError 把存储层错误转换为对应的 HTTP 状态码
+func writeStoreError(c *gin.Context, err error) {
+	var conflict *dao.VersionConflictError
+	switch {
+	case errors.Is(err, dao.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, dao.ErrTitleTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.As(err, &conflict):
+		resp := gin.H{"error": conflict.Error()}
+		if conflict.Current != nil {
+			resp["current"] = gin.H{
+				"id":      conflict.Current.ID,
+				"title":   conflict.Current.Title,
+				"content": conflict.Current.Content,
+				"version": conflict.Current.Version,
+			}
+		}
+		c.JSON(http.StatusConflict, resp)
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// GetNoteV2 GET /api/v2/notes/:id
+func (h *NoteHandler) GetNoteV2(c *gin.Context) {
+	is, ok := h.idStore(c)
+	if !ok {
+		return
+	}
+	id, ok := paramID(c, "id", false)
+	if !ok {
+		return
+	}
+	note, err := is.GetNoteByID(id)
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+	c.Header("ETag", etag(note.Version))
+	c.JSON(http.StatusOK, note)
+}
+
+// PutNoteV2 PUT /api/v2/notes/:id 整体替换标题、文件夹和内容
+// 省略 folder_id 表示放到根目录
+func (h *NoteHandler) PutNoteV2(c *gin.Context) {
+	h.updateNoteV2(c, true)
+}
+
+// PatchNoteV2 PATCH /api/v2/notes/:id 只修改请求体中出现的字段
+func (h *NoteHandler) PatchNoteV2(c *gin.Context) {
+	h.updateNoteV2(c, false)
+}
+
+func (h *NoteHandler) updateNoteV2(c *gin.Context, replace bool) {
+	is, ok := h.idStore(c)
+	if !ok {
+		return
+	}
+	id, ok := paramID(c, "id", false)
+	if !ok {
+		return
+	}
+	var patch model.NotePatch
+	if err := c.BindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if replace {
+		if patch.Title == nil || patch.Content == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "PUT 需要完整的 title 和 content"})
+			return
+		}
+		if !patch.FolderID.Set {
+			patch.FolderID = model.OptionalID{Set: true}
+		}
+	}
+
+	var expected *uint
+	if v, ok := parseIfMatch(c.GetHeader("If-Match")); ok {
+		expected = &v
+	}
+	note, err := is.UpdateNoteByID(id, patch, expected)
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+	c.Header("ETag", etag(note.Version))
+	c.JSON(http.StatusOK, note)
+}
+
+// DeleteNoteV2 DELETE /api/v2/notes/:id 移入回收站
+func (h *NoteHandler) DeleteNoteV2(c *gin.Context) {
+	is, ok := h.idStore(c)
+	if !ok {
+		return
+	}
+	id, ok := paramID(c, "id", false)
+	if !ok {
+		return
+	}
+	if err := is.DeleteNoteByID(id); err != nil {
+		writeStoreError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListFolderNotesV2 GET /api/v2/folders/:id/notes，id 为 0 表示根目录
+func (h *NoteHandler) ListFolderNotesV2(c *gin.Context) {
+	is, ok := h.idStore(c)
+	if !ok {
+		return
+	}
+	id, ok := paramID(c, "id", true)
+	if !ok {
+		return
+	}
+	notes, err := is.ListNotesInFolder(id)
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, notes)
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"ai-notes/internal/dao"
+	"ai-notes/internal/diff"
+	"ai-notes/internal/model"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 并发写入时合并后保存的最大重试次数
+const maxMergeAttempts = 3
+
+// resolveBase 取出客户端编辑时基于的内容：优先 base_content，否则按 base_hash 在历史版本中查找
+func (h *NoteHandler) resolveBase(req *model.NoteRequest) (string, bool) {
+	if req.BaseContent != nil {
+		return *req.BaseContent, true
+	}
+	rs, ok := h.Store.(dao.RevisionStore)
+	if !ok {
+		return "", false
+	}
+	rev, err := rs.FindRevisionByHash(req.Title, req.Folder, req.BaseHash)
+	if err != nil || rev == nil {
+		return "", false
+	}
+	return rev.Content, true
+}
+
+// currentNote 读取服务器上的当前内容和版本号 (不支持版本号的后端版本号为 0)
+func (h *NoteHandler) currentNote(title, folder string) (content string, version uint, found bool) {
+	if vs, ok := h.Store.(dao.VersionedStore); ok {
+		note, err := vs.GetNoteVersioned(title, folder)
+		if err != nil {
+			return "", 0, false
+		}
+		return note.Content, note.Version, true
+	}
+	content, err := h.Store.GetNote(title, folder)
+	if err != nil {
+		return "", 0, false
+	}
+	return content, 0, true
+}
+
+// saveWithMerge 基于客户端提供的编辑基准保存笔记
+//   - 服务器内容仍等于基准：直接保存
+//   - 服务器内容已变化：对 基准 / 服务器内容 / 提交内容 做行级三方合并，
+//     无冲突时保存合并结果并返回 (status = "merged")，有冲突时返回 409 以及带冲突标记的文本和冲突列表
+//
+// checkpoint 为 true 时 (后端支持的话) 保存为独立的历史版本，不与最近的自动保存合并
+func (h *NoteHandler) saveWithMerge(c *gin.Context, req *model.NoteRequest, checkpoint bool) {
+	base, ok := h.resolveBase(req)
+	if !ok {
+		c.JSON(http.StatusConflict, gin.H{"error": "找不到编辑基准版本，无法自动合并"})
+		return
+	}
+
+	vs, versioned := h.Store.(dao.VersionedStore)
+	var save func(title, folderName, content string, expected uint) (*model.Note, error)
+	if versioned {
+		save = vs.SaveNoteIfVersion
+		if rs, ok := h.Store.(dao.RevisionStore); ok && checkpoint {
+			save = rs.SaveNoteRevision
+		}
+	}
+	for attempt := 0; attempt < maxMergeAttempts; attempt++ {
+		current, version, found := h.currentNote(req.Title, req.Folder)
+
+		content, status := req.Content, "ok"
+		if found && current != base && current != req.Content {
+			result := diff.Merge3(base, current, req.Content, "server", "yours")
+			if !result.Clean() {
+				c.JSON(http.StatusConflict, gin.H{
+					"error":     "存在无法自动合并的冲突",
+					"merged":    result.Content,
+					"conflicts": result.Conflicts,
+					"current": gin.H{
+						"title":   req.Title,
+						"folder":  req.Folder,
+						"content": current,
+						"version": version,
+					},
+				})
+				return
+			}
+			content, status = result.Content, "merged"
+		}
+
+		if !versioned {
+			if err := h.Store.SaveNote(req.Title, req.Folder, content); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"status": status, "content": content})
+			return
+		}
+
+		// 以读到的版本号为条件写入；期间又被其他请求修改时重新读取并合并
+		note, err := save(req.Title, req.Folder, content, version)
+		var conflict *dao.VersionConflictError
+		if errors.As(err, &conflict) {
+			continue
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("ETag", etag(note.Version))
+		c.JSON(http.StatusOK, gin.H{"status": status, "content": content, "version": note.Version})
+		return
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "笔记正在被频繁修改，请稍后重试"})
+}
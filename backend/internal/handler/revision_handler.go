@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"ai-notes/internal/dao"
+	"ai-notes/internal/diff"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// revisionStore 当前存储后端是否支持历史版本，不支持时直接返回 501
+func (h *NoteHandler) revisionStore(c *gin.Context) (dao.RevisionStore, bool) {
+	rs, ok := h.Store.(dao.RevisionStore)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "当前存储后端不支持历史版本"})
+	}
+	return rs, ok
+}
+
+// queryID 解析 uint 类型的查询参数
+func queryID(c *gin.Context, key string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Query(key), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少或非法的参数 " + key})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// History 笔记历史版本列表
+// 前端请求示例: /api/notes/history?title=笔记A&folder=工作
+func (h *NoteHandler) History(c *gin.Context) {
+	rs, ok := h.revisionStore(c)
+	if !ok {
+		return
+	}
+	title := c.Query("title")
+	if title == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少标题"})
+		return
+	}
+
+	revs, err := rs.ListRevisions(title, c.Query("folder"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, revs)
+}
+
+// Revision 获取单个历史版本内容
+// 前端请求示例: /api/notes/revision?id=12
+func (h *NoteHandler) Revision(c *gin.Context) {
+	rs, ok := h.revisionStore(c)
+	if !ok {
+		return
+	}
+	id, ok := queryID(c, "id")
+	if !ok {
+		return
+	}
+
+	rev, err := rs.GetRevision(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rev)
+}
+
+// Diff 两个版本之间的 unified diff
+// 前端请求示例: /api/notes/diff?from=10&to=12 (from 省略时与上一个版本比较)
+func (h *NoteHandler) Diff(c *gin.Context) {
+	rs, ok := h.revisionStore(c)
+	if !ok {
+		return
+	}
+	toID, ok := queryID(c, "to")
+	if !ok {
+		return
+	}
+	to, err := rs.GetRevision(toID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	var fromID uint
+	fromContent := ""
+	if c.Query("from") != "" {
+		if fromID, ok = queryID(c, "from"); !ok {
+			return
+		}
+		from, err := rs.GetRevision(fromID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		fromContent = from.Content
+	} else {
+		prev, err := rs.PreviousRevision(toID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if prev != nil {
+			fromID, fromContent = prev.ID, prev.Content
+		}
+	}
+
+	context := 3
+	if v, err := strconv.Atoi(c.Query("context")); err == nil && v >= 0 {
+		context = v
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from": fromID,
+		"to":   toID,
+		"diff": diff.Unified(fmt.Sprintf("revision/%d", fromID), fmt.Sprintf("revision/%d", toID), fromContent, to.Content, context),
+	})
+}
+
+// Restore 恢复到指定历史版本
+func (h *NoteHandler) Restore(c *gin.Context) {
+	rs, ok := h.revisionStore(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		ID uint `json:"id"`
+	}
+	if err := c.BindJSON(&req); err != nil || req.ID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	note, err := rs.RestoreRevision(req.ID)
+	if errors.Is(err, dao.ErrRevisionNotFound) || errors.Is(err, dao.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "restored", "title": note.Title, "content": note.Content})
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"ai-notes/internal/search"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 搜索结果分页：默认每页条数和上限
+const (
+	defaultSearchSize = 20
+	maxSearchSize     = 100
+)
+
+// Search 全文搜索标题和内容
+// GET /api/search?q=关键词&folder=Work&tag=idea&page=1&size=20
+// q 支持：多个词 (AND)、"短语"、前缀 foo*；中文按 bigram 分词，可直接输入任意长度的词
+func (h *NoteHandler) Search(c *gin.Context) {
+	q := search.ParseQuery(c.Query("q"))
+	if q.Empty() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少搜索关键词"})
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	size, _ := strconv.Atoi(c.DefaultQuery("size", strconv.Itoa(defaultSearchSize)))
+	if size < 1 || size > maxSearchSize {
+		size = defaultSearchSize
+	}
+
+	result := h.Index.Search(q, search.Options{
+		Folder: c.Query("folder"),
+		Tag:    c.Query("tag"),
+		Offset: (page - 1) * size,
+		Limit:  size,
+	})
+	c.JSON(http.StatusOK, gin.H{
+		"total": result.Total,
+		"page":  page,
+		"size":  size,
+		"hits":  result.Hits,
+	})
+}
+
+// IndexStats GET /api/admin/index 搜索索引统计信息
+func (h *NoteHandler) IndexStats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.Index.Stats())
+}
+
+// Reindex POST /api/admin/reindex 全量重建搜索索引
+func (h *NoteHandler) Reindex(c *gin.Context) {
+	if err := h.Index.Reindex(); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, h.Index.Stats())
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"ai-notes/internal/dao"
+	"ai-notes/internal/semantic"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 语义检索 / 相关笔记：默认和最多返回的笔记数
+const (
+	defaultSemanticLimit = 10
+	maxSemanticLimit     = 50
+)
+
+// semantic 是否启用了向量检索，未配置 AI_EMBEDDING_MODEL 时直接返回 501
+func (h *NoteHandler) semantic(c *gin.Context) (*semantic.Indexer, bool) {
+	if h.Semantic == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "未配置向量模型 (AI_EMBEDDING_MODEL)，语义检索不可用"})
+		return nil, false
+	}
+	return h.Semantic, true
+}
+
+func semanticLimit(c *gin.Context) int {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSemanticLimit)))
+	if limit < 1 || limit > maxSemanticLimit {
+		limit = defaultSemanticLimit
+	}
+	return limit
+}
+
+// SemanticSearch 按语义检索笔记，每篇笔记返回最相关的一段
+// GET /api/search/semantic?q=如何部署&folder=Work&tag=idea&limit=10
+func (h *NoteHandler) SemanticSearch(c *gin.Context) {
+	sem, ok := h.semantic(c)
+	if !ok {
+		return
+	}
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少搜索内容"})
+		return
+	}
+
+	hits, err := sem.Search(c.Request.Context(), q, semantic.Options{
+		Folder: c.Query("folder"),
+		Tag:    c.Query("tag"),
+		Limit:  semanticLimit(c),
+	})
+	if err != nil {
+		log.Println("语义检索失败:", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "生成查询向量失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"hits": hits})
+}
+
+// Related 与指定笔记内容相近的笔记
+// GET /api/notes/related?id=12 (支持 ID 的后端) 或 ?title=笔记A&folder=工作
+func (h *NoteHandler) Related(c *gin.Context) {
+	sem, ok := h.semantic(c)
+	if !ok {
+		return
+	}
+	var id uint
+	title, folder := c.Query("title"), c.Query("folder")
+	if raw := c.Query("id"); raw != "" {
+		n, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil || n == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "非法的 id"})
+			return
+		}
+		id = uint(n)
+	} else if title == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少 id 或标题"})
+		return
+	}
+
+	hits, err := sem.Related(id, folder, title, semantic.Options{
+		Folder: c.Query("in_folder"),
+		Tag:    c.Query("tag"),
+		Limit:  semanticLimit(c),
+	})
+	if errors.Is(err, semantic.ErrNotIndexed) {
+		// 区分笔记不存在和向量还在生成中
+		if !h.noteExists(id, folder, title) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "笔记不存在"})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"hits": hits})
+}
+
+// noteExists 按 ID 或 (文件夹, 标题) 检查笔记是否存在
+func (h *NoteHandler) noteExists(id uint, folder, title string) bool {
+	if id != 0 {
+		is, ok := h.Store.(dao.IDStore)
+		if !ok {
+			return false
+		}
+		_, err := is.GetNoteByID(id)
+		return err == nil
+	}
+	_, err := h.Store.GetNote(title, folder)
+	return err == nil
+}
+
+// EmbeddingStats GET /api/admin/embeddings 向量索引统计信息
+func (h *NoteHandler) EmbeddingStats(c *gin.Context) {
+	sem, ok := h.semantic(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, sem.Stats())
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"ai-notes/internal/dao"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tagStore 当前存储后端是否支持标签，不支持时直接返回 501
+func (h *NoteHandler) tagStore(c *gin.Context) (dao.TagStore, bool) {
+	ts, ok := h.Store.(dao.TagStore)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "当前存储后端不支持标签"})
+	}
+	return ts, ok
+}
+
+// ListTags 标签列表及使用次数
+func (h *NoteHandler) ListTags(c *gin.Context) {
+	ts, ok := h.tagStore(c)
+	if !ok {
+		return
+	}
+	tags, err := ts.ListTags()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取标签失败"})
+		return
+	}
+	c.JSON(http.StatusOK, tags)
+}
+
+// RenameTag 重命名标签，新名称已存在时合并到该标签
+// 会改写所有相关笔记内容中的 #标签 和 frontmatter tags
+func (h *NoteHandler) RenameTag(c *gin.Context) {
+	ts, ok := h.tagStore(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		OldName string `json:"oldName"`
+		NewName string `json:"newName"`
+	}
+	if err := c.BindJSON(&req); err != nil || req.OldName == "" || req.NewName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	changed, err := ts.RenameTag(req.OldName, req.NewName)
+	if errors.Is(err, dao.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "标签不存在"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "tag renamed", "notes": changed})
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"ai-notes/internal/dao"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// trashStore 当前存储后端是否支持回收站，不支持时直接返回 501
+func (h *NoteHandler) trashStore(c *gin.Context) (dao.TrashStore, bool) {
+	ts, ok := h.Store.(dao.TrashStore)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "当前存储后端不支持回收站"})
+	}
+	return ts, ok
+}
+
+// ListTrash 回收站列表
+func (h *NoteHandler) ListTrash(c *gin.Context) {
+	ts, ok := h.trashStore(c)
+	if !ok {
+		return
+	}
+	items, err := ts.ListTrash()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取回收站失败"})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// RestoreTrash 从回收站恢复笔记
+// 返回恢复后的标题 (同名冲突时会被改名) 和文件夹
+func (h *NoteHandler) RestoreTrash(c *gin.Context) {
+	ts, ok := h.trashStore(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		ID uint `json:"id"`
+	}
+	if err := c.BindJSON(&req); err != nil || req.ID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	item, err := ts.RestoreTrash(req.ID)
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "restored", "title": item.Title, "folder": item.Folder})
+}
+
+// PurgeTrash 彻底删除回收站中的笔记
+// 前端请求示例: DELETE /api/trash?id=1&id=2，不带 id 表示清空回收站
+func (h *NoteHandler) PurgeTrash(c *gin.Context) {
+	ts, ok := h.trashStore(c)
+	if !ok {
+		return
+	}
+	var ids []uint
+	for _, v := range c.QueryArray("id") {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil || id == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "非法的 id: " + v})
+			return
+		}
+		ids = append(ids, uint(id))
+	}
+
+	n, err := ts.PurgeTrash(ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "purged", "count": n})
+}
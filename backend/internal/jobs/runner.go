@@ -0,0 +1,351 @@
+// Package jobs 后台批量 AI 任务：对一批笔记执行同一个模板动作，结果作为新版本写回笔记
+//   - 任务和条目持久化在数据库中 (dao.JobStore)，worker 从数据库领取条目，重启后继续执行未完成的条目
+//   - 固定数量的 worker 并发调用服务商，遇到 429 / 5xx / 连接失败时指数退避重试
+//   - 每处理完一个条目推送一次进度，可随时取消
+package jobs
+
+import (
+	"ai-notes/internal/ai"
+	"ai-notes/internal/dao"
+	"ai-notes/internal/model"
+	"ai-notes/internal/usage"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxAttempts = 4                // 每个条目最多调用几次大模型 (含首次)
+	baseBackoff = 2 * time.Second  // 第一次重试前的等待，之后每次翻倍
+	maxBackoff  = time.Minute      // 最长重试间隔
+	idlePoll    = 10 * time.Second // 队列为空时检查的间隔 (提交新任务会立即唤醒 worker)
+	budgetWait  = time.Minute      // 今日预算用完时，隔多久再检查一次
+)
+
+// Progress 推送给订阅者的进度：任务的最新计数，以及刚处理完的条目
+type Progress struct {
+	Job  *model.AIJob     `json:"job"`
+	Item *model.AIJobItem `json:"item,omitempty"`
+}
+
+// Runner 任务执行器，并发安全
+type Runner struct {
+	store    dao.NoteStore
+	jobs     dao.JobStore
+	notes    dao.IDStore
+	saver    dao.RevisionStore
+	provider ai.AIProvider
+	meter    *usage.Meter
+	workers  int
+	signal   chan struct{}
+
+	mu      sync.Mutex
+	running map[uint]map[uint]context.CancelFunc // 任务 ID -> 条目 ID -> 取消正在进行的调用
+	subs    map[uint]map[chan Progress]struct{}
+}
+
+// New 创建执行器并启动 workers 个 worker；存储后端不支持任务 / ID 寻址 / 历史版本时返回 nil
+func New(store dao.NoteStore, provider ai.AIProvider, meter *usage.Meter, workers int) *Runner {
+	js, ok := store.(dao.JobStore)
+	if !ok {
+		return nil
+	}
+	ids, ok := store.(dao.IDStore)
+	if !ok {
+		return nil
+	}
+	rs, ok := store.(dao.RevisionStore)
+	if !ok {
+		return nil
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	r := &Runner{
+		store:    store,
+		jobs:     js,
+		notes:    ids,
+		saver:    rs,
+		provider: provider,
+		meter:    meter,
+		workers:  workers,
+		signal:   make(chan struct{}, workers),
+		running:  map[uint]map[uint]context.CancelFunc{},
+		subs:     map[uint]map[chan Progress]struct{}{},
+	}
+	if n, err := js.RequeueJobItems(); err != nil {
+		log.Println("恢复中断的 AI 任务失败:", err)
+	} else if n > 0 {
+		log.Printf("继续执行上次中断的 %d 个 AI 任务条目", n)
+	}
+	for i := 0; i < workers; i++ {
+		go r.work()
+	}
+	return r
+}
+
+// Submit 保存任务并唤醒 worker
+func (r *Runner) Submit(job *model.AIJob, items []model.AIJobItem) error {
+	if err := r.jobs.CreateJob(job, items); err != nil {
+		return err
+	}
+	for i := 0; i < r.workers; i++ {
+		select {
+		case r.signal <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Get 任务及其所有条目
+func (r *Runner) Get(id uint) (*model.AIJob, []model.AIJobItem, error) {
+	job, err := r.jobs.GetJob(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	items, err := r.jobs.ListJobItems(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return job, items, nil
+}
+
+// List 最近的 limit 个任务
+func (r *Runner) List(limit int) ([]model.AIJob, error) {
+	return r.jobs.ListJobs(limit)
+}
+
+// Cancel 取消任务，正在进行的调用立即中断
+func (r *Runner) Cancel(id uint) (*model.AIJob, error) {
+	job, err := r.jobs.CancelJob(id)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	for _, cancel := range r.running[id] {
+		cancel()
+	}
+	r.mu.Unlock()
+	r.publish(Progress{Job: job})
+	return job, nil
+}
+
+// Subscribe 订阅任务进度，用完后调用返回的函数退订
+// 订阅者处理不过来时会丢弃中间的进度 (每条进度都带有完整计数，不影响结果)
+func (r *Runner) Subscribe(jobID uint) (<-chan Progress, func()) {
+	ch := make(chan Progress, 16)
+	r.mu.Lock()
+	if r.subs[jobID] == nil {
+		r.subs[jobID] = map[chan Progress]struct{}{}
+	}
+	r.subs[jobID][ch] = struct{}{}
+	r.mu.Unlock()
+	return ch, func() {
+		r.mu.Lock()
+		delete(r.subs[jobID], ch)
+		if len(r.subs[jobID]) == 0 {
+			delete(r.subs, jobID)
+		}
+		r.mu.Unlock()
+	}
+}
+
+func (r *Runner) publish(p Progress) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for ch := range r.subs[p.Job.ID] {
+		select {
+		case ch <- p:
+		default:
+		}
+	}
+}
+
+// work worker 主循环：领取条目并处理，队列为空时等待唤醒
+func (r *Runner) work() {
+	for {
+		if r.meter != nil {
+			if err := r.meter.OverBudget(); err != nil {
+				time.Sleep(budgetWait)
+				continue
+			}
+		}
+		item, err := r.jobs.ClaimJobItem()
+		if err != nil {
+			log.Println("领取 AI 任务条目失败:", err)
+		}
+		if item == nil {
+			select {
+			case <-r.signal:
+			case <-time.After(idlePoll):
+			}
+			continue
+		}
+		r.process(item)
+	}
+}
+
+// process 处理一个条目并保存结果
+func (r *Runner) process(item *model.AIJobItem) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r.track(item, cancel)
+	defer r.untrack(item)
+
+	// 登记之后再读任务状态：领取之后、登记之前被取消的任务在这里发现
+	job, err := r.jobs.GetJob(item.JobID)
+	switch {
+	case err != nil:
+		item.Status, item.Error = dao.JobFailed, err.Error()
+	case job.Status == dao.JobCancelled:
+		item.Status = dao.JobCancelled
+	default:
+		err = r.run(ctx, job, item)
+		var limit *usage.LimitError
+		switch {
+		case errors.As(err, &limit):
+			// 处理期间今日预算用完：条目放回队列，不算失败，预算恢复后重新处理 (见 work)
+			if err := r.jobs.ReleaseJobItem(item.ID); err != nil {
+				log.Printf("AI 任务条目 %d 放回队列失败: %v", item.ID, err)
+			}
+			return
+		case err == nil:
+			item.Status = dao.JobDone
+		case ctx.Err() != nil:
+			item.Status = dao.JobCancelled
+		default:
+			item.Status, item.Error = dao.JobFailed, truncate(err.Error(), 500)
+		}
+	}
+
+	updated, err := r.jobs.FinishJobItem(item)
+	if err != nil {
+		log.Printf("保存 AI 任务条目 %d 失败: %v", item.ID, err)
+		return
+	}
+	r.publish(Progress{Job: updated, Item: item})
+}
+
+func (r *Runner) track(item *model.AIJobItem, cancel context.CancelFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running[item.JobID] == nil {
+		r.running[item.JobID] = map[uint]context.CancelFunc{}
+	}
+	r.running[item.JobID][item.ID] = cancel
+}
+
+func (r *Runner) untrack(item *model.AIJobItem) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.running[item.JobID], item.ID)
+	if len(r.running[item.JobID]) == 0 {
+		delete(r.running, item.JobID)
+	}
+}
+
+// run 读取笔记、调用大模型，输出与原文不同时作为独立的历史版本写回
+func (r *Runner) run(ctx context.Context, job *model.AIJob, item *model.AIJobItem) error {
+	note, err := r.notes.GetNoteByID(item.NoteID)
+	if errors.Is(err, dao.ErrNotFound) {
+		return errors.New("笔记已被删除")
+	}
+	if err != nil {
+		return err
+	}
+	p, err := dao.FindPrompt(r.store, job.Action)
+	if err != nil {
+		return fmt.Errorf("读取模板 %s 失败: %w", job.Action, err)
+	}
+	language := job.Language
+	if language == "" {
+		language = "中文"
+	}
+	chat := ai.PromptRequest(p, map[string]string{
+		"content":   note.Content,
+		"selection": note.Content,
+		"title":     note.Title,
+		"language":  language,
+	})
+	if job.Model != "" {
+		chat.Model = job.Model
+	}
+
+	ctx = ai.WithCall(ctx, ai.Call{Action: job.Action, Client: fmt.Sprintf("job:%d", job.ID)})
+	output, err := r.complete(ctx, chat, item)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(output) == "" {
+		return errors.New("模型没有输出")
+	}
+	if output == note.Content {
+		return nil
+	}
+	// 带上读取时的版本号：处理期间笔记被用户修改时不覆盖
+	_, err = r.saver.SaveNoteRevision(note.Title, note.Folder, output, note.Version)
+	var conflict *dao.VersionConflictError
+	if errors.As(err, &conflict) {
+		return fmt.Errorf("处理期间笔记被修改，结果未写回 (%v)", err)
+	}
+	if err != nil {
+		return err
+	}
+	item.Changed = true
+	return nil
+}
+
+// complete 调用大模型，可重试的错误按指数退避重试，item.Attempts 记录调用次数
+func (r *Runner) complete(ctx context.Context, chat ai.ChatRequest, item *model.AIJobItem) (string, error) {
+	for {
+		// 多个 worker 同时运行，领取条目时还有预算，调用前 (包括重试前) 可能已经用完
+		if r.meter != nil {
+			if err := r.meter.OverBudget(); err != nil {
+				return "", err
+			}
+		}
+		item.Attempts++
+		output, _, err := ai.Complete(ctx, r.provider, chat)
+		if err == nil || item.Attempts >= maxAttempts || !retryable(err) || ctx.Err() != nil {
+			return output, err
+		}
+		wait := backoff(item.Attempts)
+		log.Printf("AI 任务 %d 的条目 %d 第 %d 次调用失败，%s 后重试: %v", item.JobID, item.ID, item.Attempts, wait, err)
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// retryable 限流 (429)、服务端错误 (5xx) 和连接失败 / 超时可以重试，其余 (如 400、401) 重试也没用
+func retryable(err error) bool {
+	var apiErr *ai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Status == 429 || apiErr.Status >= 500
+	}
+	return !errors.Is(err, context.Canceled)
+}
+
+// backoff 第 attempt 次失败后的等待时间：指数增长，加上随机抖动，避免多个 worker 同时重试
+func backoff(attempt int) time.Duration {
+	d := baseBackoff << (attempt - 1)
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
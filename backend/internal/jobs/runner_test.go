@@ -0,0 +1,165 @@
+package jobs
+
+import (
+	"ai-notes/internal/ai"
+	"ai-notes/internal/dao"
+	"ai-notes/internal/dao/daotest"
+	"ai-notes/internal/model"
+	"ai-notes/internal/usage"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"限流", &ai.APIError{Status: 429}, true},
+		{"服务端错误", &ai.APIError{Status: 503}, true},
+		{"包装后的服务端错误", fmt.Errorf("调用失败: %w", &ai.APIError{Status: 500}), true},
+		{"请求错误", &ai.APIError{Status: 400}, false},
+		{"认证失败", &ai.APIError{Status: 401}, false},
+		{"连接失败", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{"超时", context.DeadlineExceeded, true},
+		{"取消", context.Canceled, false},
+		{"包装后的取消", fmt.Errorf("读取失败: %w", context.Canceled), false},
+	}
+	for _, tt := range tests {
+		if got := retryable(tt.err); got != tt.want {
+			t.Errorf("%s: retryable = %v，期望 %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		base    time.Duration // 不含抖动的等待时间，实际在 [base/2, base] 之间
+	}{
+		{1, baseBackoff},
+		{2, 2 * baseBackoff},
+		{3, 4 * baseBackoff},
+		{10, maxBackoff},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if d := backoff(tt.attempt); d < tt.base/2 || d > tt.base {
+				t.Fatalf("backoff(%d) = %v，期望在 [%v, %v] 之间", tt.attempt, d, tt.base/2, tt.base)
+			}
+		}
+	}
+}
+
+// fakeProvider 把笔记内容转成大写的假服务商，记录调用次数
+type fakeProvider struct {
+	calls int
+}
+
+func (p *fakeProvider) Name() string         { return "fake" }
+func (p *fakeProvider) DefaultModel() string { return "fake-model" }
+
+func (p *fakeProvider) ChatStream(ctx context.Context, req ai.ChatRequest) (ai.Stream, error) {
+	p.calls++
+	last := req.Messages[len(req.Messages)-1].Content
+	return ai.Replay(strings.ToUpper(last[strings.LastIndex(last, "\n")+1:])), nil
+}
+
+func (p *fakeProvider) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	return nil, ai.ErrNotSupported
+}
+
+func (p *fakeProvider) ListModels(ctx context.Context) ([]string, error) {
+	return nil, ai.ErrNotSupported
+}
+
+// newTestRunner 不启动 worker 的执行器，由测试直接调用 process
+func newTestRunner(t *testing.T, meter *usage.Meter) (*Runner, *dao.NoteDAO, *fakeProvider) {
+	t.Helper()
+	s := daotest.New(t)
+	p := &fakeProvider{}
+	r := &Runner{
+		store: s, jobs: s, notes: s, saver: s, provider: p, meter: meter, workers: 1,
+		signal:  make(chan struct{}, 1),
+		running: map[uint]map[uint]context.CancelFunc{},
+		subs:    map[uint]map[chan Progress]struct{}{},
+	}
+	return r, s, p
+}
+
+// submitAndClaim 为一篇笔记创建任务并领取它的条目
+func submitAndClaim(t *testing.T, s *dao.NoteDAO, content string) *model.AIJobItem {
+	t.Helper()
+	if err := s.SaveNote("a", "", content); err != nil {
+		t.Fatal(err)
+	}
+	note, err := s.GetNoteByID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := &model.AIJob{Action: "format"}
+	if err := s.CreateJob(job, []model.AIJobItem{{NoteID: note.ID, Title: note.Title}}); err != nil {
+		t.Fatal(err)
+	}
+	item, err := s.ClaimJobItem()
+	if err != nil || item == nil {
+		t.Fatalf("领取条目失败: %v %v", item, err)
+	}
+	return item
+}
+
+func TestProcess(t *testing.T) {
+	r, s, p := newTestRunner(t, usage.NewMeter(nil, 1000, 0))
+	item := submitAndClaim(t, s, "hello")
+	r.process(item)
+
+	items, err := s.ListJobItems(item.JobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := items[0]; got.Status != dao.JobDone || !got.Changed || got.Attempts != 1 {
+		t.Fatalf("条目 %+v", got)
+	}
+	if content, _ := s.GetNote("a", ""); content != "HELLO" {
+		t.Errorf("结果未写回，笔记内容 %q", content)
+	}
+	if p.calls != 1 {
+		t.Errorf("调用了 %d 次", p.calls)
+	}
+}
+
+func TestProcessReleasesWhenOverBudget(t *testing.T) {
+	meter := usage.NewMeter(nil, 10, 0)
+	r, s, p := newTestRunner(t, meter)
+	item := submitAndClaim(t, s, "hello")
+	// 领取之后、调用之前预算被其他请求用完
+	meter.Record(model.AIUsage{Action: "polish", InputTokens: 10})
+	r.process(item)
+
+	if p.calls != 0 {
+		t.Errorf("预算用完后不应再调用服务商，调用了 %d 次", p.calls)
+	}
+	items, err := s.ListJobItems(item.JobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := items[0]; got.Status != dao.JobPending || got.Attempts != 0 || got.Error != "" {
+		t.Errorf("条目应放回队列，得到 %+v", got)
+	}
+	job, err := s.GetJob(item.JobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Failed != 0 {
+		t.Errorf("放回队列不算失败，任务 %+v", job)
+	}
+	if again, err := s.ClaimJobItem(); err != nil || again == nil || again.ID != item.ID {
+		t.Errorf("放回的条目应能重新领取: %v %v", again, err)
+	}
+}
@@ -0,0 +1,177 @@
+package model
+
+import "time"
+
+// Prompt AI 动作的提示词模板，Name 即动作名，对应 POST /api/ai/run/:action
+// Template 中可以使用变量 {{content}} {{selection}} {{title}} {{language}}，调用时替换为请求中的值
+type Prompt struct {
+	ID          uint      `gorm:"primaryKey" json:"id,omitempty"`
+	CreatedAt   time.Time `json:"created_at,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at,omitempty"`
+	Name        string    `gorm:"uniqueIndex;size:100;not null" json:"name"`
+	Description string    `gorm:"size:255" json:"description"`
+	System      string    `gorm:"type:text" json:"system"`
+	Template    string    `gorm:"type:text;not null" json:"template"`
+	// Model / Temperature 为空时使用服务商的默认值
+	Model       string   `gorm:"size:100" json:"model"`
+	Temperature *float64 `json:"temperature"`
+	// Cacheable 相同的输入 (渲染后的提示词、模型、温度) 直接返回缓存的结果，适合格式化这类结果确定的动作
+	Cacheable bool `json:"cacheable"`
+	// Builtin 内置模板 (润色、格式化等)，可以修改但不能删除
+	Builtin bool `json:"builtin"`
+}
+
+// AINoteRequest 针对一篇笔记或一个文件夹的 AI 动作 (总结、标题、标签)
+// 优先使用 Content (编辑器中尚未保存的内容)，其次按 ID 或 Title + Folder 读取笔记；
+// 总结时三者都没有表示总结整个 Folder (含子文件夹，为空表示全部笔记)
+type AINoteRequest struct {
+	Content  string `json:"content"`
+	ID       uint   `json:"id"`
+	Title    string `json:"title"`
+	Folder   string `json:"folder"`
+	Language string `json:"language"` // 输出语言，为空时与笔记相同 (总结默认中文)
+}
+
+// TranslateRequest POST /api/ai/translate 请求体，笔记的指定方式同 AINoteRequest
+type TranslateRequest struct {
+	Content string `json:"content"`
+	ID      uint   `json:"id"`
+	Title   string `json:"title"`
+	Folder  string `json:"folder"`
+	Source  string `json:"source"` // 源语言，为空时由模型自动识别
+	Target  string `json:"target"` // 目标语言，如 "English"、"中文"
+	// Save 翻译完成后另存为同一文件夹下的笔记，标题为 SaveTitle，为空时为 "原标题 (EN)" 这样的形式；
+	// 同名笔记已存在时拒绝翻译，Overwrite 为 true 时覆盖
+	Save      bool   `json:"save"`
+	SaveTitle string `json:"save_title"`
+	Overwrite bool   `json:"overwrite"`
+}
+
+// AIRunRequest POST /api/ai/run/:action 请求体，字段对应模板变量
+type AIRunRequest struct {
+	Content   string `json:"content"`
+	Selection string `json:"selection"` // 为空时等于 content
+	// SelectionStart 选区在 content 中的起始位置 (按字符计)，选区文本在笔记中出现多次时用于定位；
+	// 为空时取第一次出现的位置
+	SelectionStart *int   `json:"selection_start"`
+	Title          string `json:"title"`
+	Language       string `json:"language"` // 为空时为 "中文"
+	// 临时覆盖模板中的模型 / 温度
+	Model       string   `json:"model"`
+	Temperature *float64 `json:"temperature"`
+	// Diff 输出完毕后推送与原文的逐处修改 (润色 / 格式化总是推送)
+	Diff bool `json:"diff"`
+}
+
+// AIApplyRequest POST /api/ai/apply 请求体：把 AI 输出中被接受的修改写回笔记
+// Original 为 AI 修改前的整篇笔记 (即请求中的 content，也是写回时三方合并的基准)，
+// Output 为 diff 事件中的 output (对选区的修改已放回整篇笔记中)，
+// Accept 为接受的修改编号 (diff 事件中 changes 的 index)
+type AIApplyRequest struct {
+	Title    string `json:"title"`
+	Folder   string `json:"folder"`
+	Original string `json:"original"`
+	Output   string `json:"output"`
+	Accept   []int  `json:"accept"`
+}
+
+// AIUsage 一次大模型调用的用量记录
+type AIUsage struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+	Action    string    `gorm:"size:100;index" json:"action"` // polish / ask / embed ...
+	Provider  string    `gorm:"size:50" json:"provider"`
+	Model     string    `gorm:"size:100" json:"model"`
+	Client    string    `gorm:"size:64" json:"client"` // 客户端 IP
+	// InputTokens / OutputTokens 服务商返回的用量；服务商没有返回时按字数估算，Estimated 为 true
+	InputTokens  int    `json:"input_tokens"`
+	OutputTokens int    `json:"output_tokens"`
+	Estimated    bool   `json:"estimated"`
+	LatencyMs    int64  `json:"latency_ms"`
+	Status       string `gorm:"size:20" json:"status"` // ok / error / cancelled
+	Error        string `gorm:"size:500" json:"error,omitempty"`
+}
+
+// UsageTotal 一个时间段内某个动作的用量合计 (数据库汇总的结果)
+type UsageTotal struct {
+	Bucket       int // 时间段的序号
+	Action       string
+	Requests     int
+	Errors       int // 失败 (不含取消) 的次数
+	InputTokens  int64
+	OutputTokens int64
+}
+
+// AIJobRequest POST /api/ai/jobs 请求体：对一批笔记执行同一个 AI 动作，结果写回笔记
+// 笔记由 Folder (含子文件夹)、Tag 或 IDs 指定，三者只能用一个
+type AIJobRequest struct {
+	Action   string `json:"action"`
+	Folder   string `json:"folder"`
+	Tag      string `json:"tag"`
+	IDs      []uint `json:"ids"`
+	Language string `json:"language"` // 模板变量 {{language}}，为空时为 "中文"
+	Model    string `json:"model"`    // 临时覆盖模板中的模型
+}
+
+// AIJob 后台批量 AI 任务，与其中的条目一起持久化，重启后继续执行
+type AIJob struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Action    string    `gorm:"size:100" json:"action"`
+	Selector  string    `gorm:"type:text" json:"selector"` // 笔记范围的说明，如 "folder:Work"、"tag:todo"、"ids"；文件夹路径不限长度
+	Language  string    `gorm:"size:50" json:"language"`
+	Model     string    `gorm:"size:100" json:"model"`
+	Status    string    `gorm:"size:20;index" json:"status"` // pending / running / done / cancelled
+	Total     int       `json:"total"`
+	Succeeded int       `json:"succeeded"`
+	Failed    int       `json:"failed"`
+	Cancelled int       `json:"cancelled"`
+	// FinishedAt 所有条目处理完或任务被取消的时间
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// AIJobItem 任务中的一篇笔记
+type AIJobItem struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UpdatedAt time.Time `json:"updated_at"`
+	JobID     uint      `gorm:"index;not null" json:"job_id"`
+	NoteID    uint      `json:"note_id"`
+	Title     string    `gorm:"type:text" json:"title"` // 创建任务时的标题和文件夹 (完整路径，不限长度)，仅用于展示
+	Folder    string    `gorm:"type:text" json:"folder"`
+	Status    string    `gorm:"size:20;index" json:"status"` // pending / running / done / failed / cancelled
+	Attempts  int       `json:"attempts"`                    // 调用大模型的次数 (含重试)
+	Changed   bool      `json:"changed"`                     // 输出与原文不同，已写回笔记
+	Error     string    `gorm:"size:500" json:"error,omitempty"`
+}
+
+// ChatSession 针对一篇笔记的 AI 对话，历史消息由服务端保存
+type ChatSession struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"` // 最后一次对话的时间
+	NoteID    uint      `gorm:"index;not null" json:"note_id"`
+	Title     string    `gorm:"size:255" json:"title"` // 第一条消息的开头
+	Messages  int       `json:"messages"`              // 消息数
+}
+
+// ChatMessage 对话中的一条消息
+type ChatMessage struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	SessionID uint      `gorm:"index;not null" json:"session_id"`
+	Role      string    `gorm:"size:20" json:"role"` // user / assistant
+	Content   string    `json:"content"`             // 与 Note.Content 相同不写死 type：MySQL 下为 longtext，回答可能超过 text 的 64KB
+}
+
+// AIChatRequest POST /api/ai/chat 请求体
+// SessionID 为 0 时为 NoteID 指定的笔记新建会话；Content 为编辑器中尚未保存的内容，为空时使用笔记当前内容
+type AIChatRequest struct {
+	SessionID uint   `json:"session_id"`
+	NoteID    uint   `json:"note_id"`
+	Message   string `json:"message"`
+	Content   string `json:"content"`
+	// 临时覆盖服务商的默认模型 / 温度
+	Model       string   `json:"model"`
+	Temperature *float64 `json:"temperature"`
+}
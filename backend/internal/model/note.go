@@ -1,6 +1,7 @@
 package model
 
 import (
+	"encoding/json"
 	"time"
 
 	"gorm.io/gorm"
@@ -11,11 +12,14 @@ import (
 // ==============================
 
 // Folder 文件夹模型
+// 支持嵌套：ParentID 为空表示顶级文件夹，同一父文件夹下名称唯一
 type Folder struct {
 	ID        uint           `gorm:"primaryKey" json:"id"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
-	Name      string         `gorm:"uniqueIndex;size:100;not null" json:"name"`
+	Name      string         `gorm:"uniqueIndex:idx_folder_name_parent;size:100;not null" json:"name"`
+	ParentID  *uint          `gorm:"uniqueIndex:idx_folder_name_parent;default:null" json:"parent_id"`
+	Parent    *Folder        `json:"-"`
 	Notes     []Note         `json:"-"`
 }
 
@@ -32,8 +36,36 @@ type Note struct {
 	
 	// Legacy: We don't map the string column anymore, but we need to handle migration manually
 	
-	// 内容使用 text 类型，防止过长截断
-	Content   string         `gorm:"type:longtext" json:"content"`
+	// 乐观锁版本号：内容每次保存 +1，作为 ETag 返回给前端
+	Version   uint           `gorm:"not null;default:1" json:"version"`
+
+	// 回收站：删除时记录原文件夹名 (folder_id 会被置空，原文件夹可能随后被删除)
+	TrashFolder string       `gorm:"size:255" json:"-"` // 完整路径
+
+	// 内容不限定长度，防止过长截断
+	// 不写死 type：MySQL 下映射为 longtext，Postgres / SQLite 下映射为 text
+	Content   string         `json:"content"`
+
+	// 标签：保存时从内容中的 #hashtag 和 frontmatter tags: 自动提取
+	Tags      []Tag          `gorm:"many2many:note_tags" json:"-"`
+}
+
+// Tag 标签，名称统一为小写
+// 与文件夹不同，一篇笔记可以有多个标签
+type Tag struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Name      string    `gorm:"uniqueIndex;size:100;not null" json:"name"`
+}
+
+// NoteRevision 笔记历史版本
+// 每次保存写入一条，短时间内的小改动合并到上一条 (见 dao.RevisionPolicy)
+type NoteRevision struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	NoteID    uint      `gorm:"index;not null" json:"note_id"`
+	Content   string    `json:"content"`
 }
 
 // ==============================
@@ -45,26 +77,104 @@ type NoteRequest struct {
 	Title   string `json:"title"`
 	Folder  string `json:"folder"` // 🔥 接收文件夹参数
 	Content string `json:"content"`
+	// Version 客户端编辑时基于的版本号 (也可以用 If-Match 头传递)，为空时直接覆盖
+	Version *uint `json:"version,omitempty"`
+	// BaseContent / BaseHash 客户端编辑时基于的内容 (或其 sha256)，
+	// 服务器内容已变化时据此做三方合并，见 handler.saveWithMerge
+	BaseContent *string `json:"base_content,omitempty"`
+	BaseHash    string  `json:"base_hash,omitempty"`
 }
 
 // NoteSummary 用于列表接口，不返回 Content 以减小流量
 type NoteSummary struct {
 	Title  string `json:"title"`
-	Folder string `json:"folder"` // 🔥 返回文件夹信息
+	Folder string `json:"folder"` // 🔥 返回文件夹信息 (完整路径，如 "Work/ProjectA")
+
+	// 以下字段由支持 ID 的后端填充 (v2 接口使用)，文件系统后端没有 ID
+	ID        uint      `json:"id,omitempty"`
+	FolderID  *uint     `json:"folder_id,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	Size      int       `json:"size"` // 内容字节数
+	Tags      []string  `json:"tags,omitempty"`
 }
 
-// ==============================
-// 3. AI 相关结构
-// ==============================
+// TagCount 标签列表项
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"` // 使用该标签的笔记数 (不含回收站)
+}
+
+// FolderNode 文件夹树节点
+type FolderNode struct {
+	ID       uint          `json:"id,omitempty"` // 文件系统后端没有 ID
+	Name     string        `json:"name"`
+	Path     string        `json:"path"` // 完整路径，如 "Work/ProjectA"
+	Children []*FolderNode `json:"children"`
+}
 
-type AiPolishRequest struct {
+// NoteDetail v2 接口返回的单篇笔记
+type NoteDetail struct {
+	NoteSummary
 	Content string `json:"content"`
+	Version uint   `json:"version"`
 }
 
-type ChatRequest struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
-	Stream   bool      `json:"stream"`
+// NotePatch v2 PUT / PATCH 请求体，未出现的字段保持不变
+type NotePatch struct {
+	Title    *string    `json:"title"`
+	Content  *string    `json:"content"`
+	FolderID OptionalID `json:"folder_id"`
+}
+
+// OptionalID 区分 "字段未出现" 和 "显式传 null" (null 表示根目录)
+type OptionalID struct {
+	Set   bool
+	Value *uint
+}
+
+func (o *OptionalID) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Value = nil
+		return nil
+	}
+	var v uint
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	o.Value = &v
+	return nil
+}
+
+// RevisionSummary 历史版本列表项，不返回 Content
+type RevisionSummary struct {
+	ID        uint      `json:"id"`
+	NoteID    uint      `json:"note_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Size      int       `json:"size"` // 内容字节数
+}
+
+// TrashItem 回收站列表项
+type TrashItem struct {
+	ID        uint      `json:"id"`
+	Title     string    `json:"title"`
+	Folder    string    `json:"folder"` // 删除前所在的文件夹
+	DeletedAt time.Time `json:"deleted_at"`
+	Size      int       `json:"size"`
+}
+
+// ==============================
+// 3. AI 相关结构
+// ==============================
+
+// AskRequest 基于笔记的问答请求，Folder / Tag 可选，用于限定检索范围
+type AskRequest struct {
+	Question string `json:"question"`
+	Folder   string `json:"folder"`
+	Tag      string `json:"tag"`
+	Limit    int    `json:"limit"` // 引用的笔记数上限，默认 5
 }
 
 type Message struct {
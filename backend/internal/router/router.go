@@ -1,8 +1,13 @@
 package router
 
 import (
+	"ai-notes/internal/ai"
 	"ai-notes/internal/handler"
 	"ai-notes/internal/dao"
+	"ai-notes/internal/jobs"
+	"ai-notes/internal/search"
+	"ai-notes/internal/semantic"
+	"ai-notes/internal/usage"
 	"embed"
 	"io/fs"
 	"net/http"
@@ -10,12 +15,14 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRouter(s *dao.NoteDAO, staticFiles embed.FS) *gin.Engine {
+func SetupRouter(p ai.AIProvider, s dao.NoteStore, ix *search.Indexer, sem *semantic.Indexer, m *usage.Meter, cache *ai.Cache, jr *jobs.Runner, staticFiles embed.FS) *gin.Engine {
 	r := gin.Default()
 
 	// 1. 初始化控制层
-	noteHandler := handler.NewNoteHandler(s)
-	aiHandler := &handler.AIHandler{}
+	noteHandler := handler.NewNoteHandler(s, ix, sem)
+	aiHandler := handler.NewAIHandler(p, s, ix, sem, m, cache, jr)
+	// 调用大模型的接口先经过限流 / 预算检查
+	limit := aiHandler.Limit
 
 	// 2. 路由注册
 	api := r.Group("/api")
@@ -25,12 +32,63 @@ func SetupRouter(s *dao.NoteDAO, staticFiles embed.FS) *gin.Engine {
 		api.POST("/notes", noteHandler.Save)
 		api.DELETE("/notes", noteHandler.Delete)
 		api.POST("/notes/move", noteHandler.Move)
+		api.GET("/notes/history", noteHandler.History)
+		api.GET("/notes/revision", noteHandler.Revision)
+		api.GET("/notes/diff", noteHandler.Diff)
+		api.POST("/notes/restore", noteHandler.Restore)
+		api.GET("/notes/related", noteHandler.Related)
 		api.POST("/folders/rename", noteHandler.RenameFolder)
+		api.POST("/folders/move", noteHandler.MoveFolder)
+		api.GET("/folders/tree", noteHandler.FolderTree)
 		api.POST("/folders", noteHandler.CreateFolder)
 		api.GET("/folders", noteHandler.ListFolders)
 		api.DELETE("/folders", noteHandler.DeleteFolder)
-		api.POST("/ai/polish", aiHandler.Polish)
-		api.POST("/ai/format", aiHandler.Format)
+		api.GET("/search", noteHandler.Search)
+		api.GET("/search/semantic", noteHandler.SemanticSearch)
+		api.GET("/tags", noteHandler.ListTags)
+		api.POST("/tags/rename", noteHandler.RenameTag)
+		api.GET("/trash", noteHandler.ListTrash)
+		api.POST("/trash/restore", noteHandler.RestoreTrash)
+		api.DELETE("/trash", noteHandler.PurgeTrash)
+		api.GET("/admin/index", noteHandler.IndexStats)
+		api.POST("/admin/reindex", noteHandler.Reindex)
+		api.GET("/admin/embeddings", noteHandler.EmbeddingStats)
+		api.POST("/ai/polish", limit, aiHandler.Polish)
+		api.POST("/ai/format", limit, aiHandler.Format)
+		api.POST("/ai/ask", limit, aiHandler.Ask)
+		api.POST("/ai/summarize", limit, aiHandler.Summarize)
+		api.POST("/ai/title", limit, aiHandler.Title)
+		api.POST("/ai/tags", limit, aiHandler.SuggestTags)
+		api.POST("/ai/translate", limit, aiHandler.Translate)
+		api.POST("/ai/apply", noteHandler.ApplyAIEdit)
+		api.POST("/ai/cancel", aiHandler.Cancel)
+		api.GET("/ai/usage", aiHandler.Usage)
+		api.GET("/ai/models", aiHandler.Models)
+		api.POST("/ai/run/:action", limit, aiHandler.Run)
+		api.POST("/ai/chat", limit, aiHandler.Chat)
+		api.GET("/ai/chat/sessions", aiHandler.ListChatSessions)
+		api.GET("/ai/chat/sessions/:id", aiHandler.GetChatSession)
+		api.DELETE("/ai/chat/sessions/:id", aiHandler.DeleteChatSession)
+		api.POST("/ai/jobs", aiHandler.CreateJob)
+		api.GET("/ai/jobs", aiHandler.ListJobs)
+		api.GET("/ai/jobs/:id", aiHandler.GetJob)
+		api.GET("/ai/jobs/:id/events", aiHandler.JobEvents)
+		api.POST("/ai/jobs/:id/cancel", aiHandler.CancelJob)
+		api.GET("/ai/prompts", aiHandler.ListPrompts)
+		api.GET("/ai/prompts/:name", aiHandler.GetPrompt)
+		api.POST("/ai/prompts", aiHandler.CreatePrompt)
+		api.PUT("/ai/prompts/:name", aiHandler.UpdatePrompt)
+		api.DELETE("/ai/prompts/:name", aiHandler.DeletePrompt)
+	}
+
+	// v2：按 ID 寻址的资源式接口
+	v2 := r.Group("/api/v2")
+	{
+		v2.GET("/notes/:id", noteHandler.GetNoteV2)
+		v2.PUT("/notes/:id", noteHandler.PutNoteV2)
+		v2.PATCH("/notes/:id", noteHandler.PatchNoteV2)
+		v2.DELETE("/notes/:id", noteHandler.DeleteNoteV2)
+		v2.GET("/folders/:id/notes", noteHandler.ListFolderNotesV2)
 	}
 
 	// 3. 静态资源托管
@@ -0,0 +1,424 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Document 被索引的笔记
+type Document struct {
+	ID        uint // 不支持 ID 的后端为 0
+	Title     string
+	Folder    string // 完整路径
+	Tags      []string
+	Content   string
+	UpdatedAt time.Time
+}
+
+// posting 一个词在一篇笔记中出现的位置
+type posting struct {
+	Title   []int
+	Content []int
+}
+
+type docEntry struct {
+	Document
+	TitleLen   int // 词数，BM25 长度归一化用
+	ContentLen int
+}
+
+// Index 内存倒排索引：词 -> 笔记 -> 出现位置
+// 笔记以 (文件夹, 标题) 唯一标识，所有存储后端通用
+type Index struct {
+	mu       sync.RWMutex
+	docs     map[string]*docEntry
+	postings map[string]map[string]*posting
+
+	titleTotal, contentTotal int // 所有笔记的词数之和，用于计算平均长度
+}
+
+// NewIndex 创建空索引
+func NewIndex() *Index {
+	return &Index{
+		docs:     make(map[string]*docEntry),
+		postings: make(map[string]map[string]*posting),
+	}
+}
+
+// docKey 笔记在索引中的键
+func docKey(folder, title string) string {
+	return folder + "\x00" + title
+}
+
+// Len 已索引的笔记数
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.docs)
+}
+
+// Counts 笔记数、词数、倒排表条目数
+func (ix *Index) Counts() (docs, terms, postings int) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	for _, p := range ix.postings {
+		postings += len(p)
+	}
+	return len(ix.docs), len(ix.postings), postings
+}
+
+// has 笔记是否已索引
+func (ix *Index) has(folder, title string) bool {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	_, ok := ix.docs[docKey(folder, title)]
+	return ok
+}
+
+// keys 所有已索引笔记的 (文件夹, 标题)
+func (ix *Index) keys() [][2]string {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	keys := make([][2]string, 0, len(ix.docs))
+	for _, e := range ix.docs {
+		keys = append(keys, [2]string{e.Folder, e.Title})
+	}
+	return keys
+}
+
+// Add 添加或替换一篇笔记
+func (ix *Index) Add(doc Document) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	key := docKey(doc.Folder, doc.Title)
+	ix.remove(key)
+
+	title, content := Tokenize(doc.Title), Tokenize(doc.Content)
+	entry := &docEntry{Document: doc, TitleLen: len(title), ContentLen: len(content)}
+	ix.docs[key] = entry
+	ix.titleTotal += entry.TitleLen
+	ix.contentTotal += entry.ContentLen
+
+	get := func(term string) *posting {
+		docs := ix.postings[term]
+		if docs == nil {
+			docs = make(map[string]*posting)
+			ix.postings[term] = docs
+		}
+		p := docs[key]
+		if p == nil {
+			p = &posting{}
+			docs[key] = p
+		}
+		return p
+	}
+	for _, t := range title {
+		p := get(t.Term)
+		p.Title = append(p.Title, t.Pos)
+	}
+	for _, t := range content {
+		p := get(t.Term)
+		p.Content = append(p.Content, t.Pos)
+	}
+}
+
+// Remove 删除一篇笔记
+func (ix *Index) Remove(folder, title string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(docKey(folder, title))
+}
+
+// RemoveFolder 删除文件夹 (含子文件夹) 下的所有笔记
+func (ix *Index) RemoveFolder(folder string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	for key, e := range ix.docs {
+		if MatchFolder(e.Folder, folder) {
+			ix.remove(key)
+		}
+	}
+}
+
+func (ix *Index) remove(key string) {
+	entry, ok := ix.docs[key]
+	if !ok {
+		return
+	}
+	// 重新分词找出该笔记包含的词，避免为每篇笔记额外保存词表
+	seen := map[string]bool{}
+	for _, toks := range [][]Token{Tokenize(entry.Title), Tokenize(entry.Content)} {
+		for _, t := range toks {
+			if seen[t.Term] {
+				continue
+			}
+			seen[t.Term] = true
+			if docs := ix.postings[t.Term]; docs != nil {
+				delete(docs, key)
+				if len(docs) == 0 {
+					delete(ix.postings, t.Term)
+				}
+			}
+		}
+	}
+	ix.titleTotal -= entry.TitleLen
+	ix.contentTotal -= entry.ContentLen
+	delete(ix.docs, key)
+}
+
+// ==============================
+// 查询
+// ==============================
+
+// Options 过滤与分页
+type Options struct {
+	Folder string // 只搜该文件夹及其子文件夹，空表示全部
+	Tag    string // 只搜带该标签 (或其下级标签) 的笔记
+	Offset int
+	Limit  int
+}
+
+// Hit 一条搜索结果
+type Hit struct {
+	ID        uint      `json:"id,omitempty"`
+	Title     string    `json:"title"`
+	Folder    string    `json:"folder"`
+	Tags      []string  `json:"tags,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	Score     float64   `json:"score"`
+	// TitleHighlight / Snippet 为 HTML 转义后的文本，命中的词用 <mark> 包裹
+	TitleHighlight string `json:"title_highlight"`
+	Snippet        string `json:"snippet"`
+}
+
+// Result 搜索结果
+type Result struct {
+	Total int   `json:"total"` // 分页前的命中总数
+	Hits  []Hit `json:"hits"`
+}
+
+// BM25 参数；标题命中的权重更高
+const (
+	bm25K1      = 1.2
+	bm25B       = 0.75
+	titleWeight = 3.0
+)
+
+// fieldTF 一篇笔记中某个条件在标题 / 正文里的命中次数
+type fieldTF struct {
+	title, content int
+}
+
+// Search 按查询检索，所有条件都命中 (q.Any 时任一条件命中) 的笔记按 BM25 得分排序
+func (ix *Index) Search(q Query, opts Options) Result {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	res := Result{Hits: []Hit{}}
+	if q.Empty() || len(ix.docs) == 0 {
+		return res
+	}
+
+	n := float64(len(ix.docs))
+	avgTitle := math.Max(float64(ix.titleTotal)/n, 1)
+	avgContent := math.Max(float64(ix.contentTotal)/n, 1)
+	bm25 := func(tf, length int, avg float64) float64 {
+		if tf == 0 {
+			return 0
+		}
+		f := float64(tf)
+		return f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*float64(length)/avg))
+	}
+
+	var scores map[string]float64
+	for _, c := range q.Clauses {
+		matches := ix.evalClause(c)
+		df := float64(len(matches))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+
+		next := make(map[string]float64, len(matches))
+		if q.Any {
+			next = scores
+			if next == nil {
+				next = make(map[string]float64, len(matches))
+			}
+		}
+		for key, tf := range matches {
+			if scores != nil && !q.Any {
+				if _, ok := scores[key]; !ok {
+					continue // AND：前面的条件没有命中
+				}
+			}
+			entry := ix.docs[key]
+			s := titleWeight*bm25(tf.title, entry.TitleLen, avgTitle) + bm25(tf.content, entry.ContentLen, avgContent)
+			next[key] = scores[key] + idf*s
+		}
+		scores = next
+		if len(scores) == 0 && !q.Any {
+			return res
+		}
+	}
+
+	type scored struct {
+		entry *docEntry
+		score float64
+	}
+	var ranked []scored
+	for key, s := range scores {
+		entry := ix.docs[key]
+		if !MatchFolder(entry.Folder, opts.Folder) || !MatchTag(entry.Tags, opts.Tag) {
+			continue
+		}
+		ranked = append(ranked, scored{entry, s})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if !a.entry.UpdatedAt.Equal(b.entry.UpdatedAt) {
+			return a.entry.UpdatedAt.After(b.entry.UpdatedAt)
+		}
+		return a.entry.Title < b.entry.Title
+	})
+
+	res.Total = len(ranked)
+	if opts.Offset >= len(ranked) {
+		return res
+	}
+	ranked = ranked[opts.Offset:]
+	if opts.Limit > 0 && len(ranked) > opts.Limit {
+		ranked = ranked[:opts.Limit]
+	}
+	for _, r := range ranked {
+		d := r.entry.Document
+		res.Hits = append(res.Hits, Hit{
+			ID:             d.ID,
+			Title:          d.Title,
+			Folder:         d.Folder,
+			Tags:           d.Tags,
+			UpdatedAt:      d.UpdatedAt,
+			Score:          math.Round(r.score*1000) / 1000,
+			TitleHighlight: Highlight(d.Title, q, 0),
+			Snippet:        Highlight(d.Content, q, snippetRunes),
+		})
+	}
+	return res
+}
+
+// expand 查询词对应的索引词；前缀 / 单字查询需要扫描词典
+func (ix *Index) expand(m termMatcher) []string {
+	if m.exact() {
+		if _, ok := ix.postings[m.term]; ok {
+			return []string{m.term}
+		}
+		return nil
+	}
+	var terms []string
+	for t := range ix.postings {
+		if m.match(t) {
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+// evalClause 命中该条件的笔记及命中次数
+func (ix *Index) evalClause(c Clause) map[string]fieldTF {
+	ms := c.matchers()
+	expanded := make([][]string, len(ms))
+	for i, m := range ms {
+		expanded[i] = ix.expand(m)
+		if len(expanded[i]) == 0 {
+			return nil
+		}
+	}
+
+	matches := make(map[string]fieldTF)
+	if !c.Phrase {
+		for _, t := range expanded[0] {
+			for key, p := range ix.postings[t] {
+				tf := matches[key]
+				tf.title += len(p.Title)
+				tf.content += len(p.Content)
+				matches[key] = tf
+			}
+		}
+		return matches
+	}
+
+	// 短语：第 i 个词必须出现在 起始位置 + i
+	positions := func(i int, key string) (title, content map[int]bool) {
+		title, content = map[int]bool{}, map[int]bool{}
+		for _, t := range expanded[i] {
+			if p := ix.postings[t][key]; p != nil {
+				for _, pos := range p.Title {
+					title[pos] = true
+				}
+				for _, pos := range p.Content {
+					content[pos] = true
+				}
+			}
+		}
+		return
+	}
+	count := func(sets []map[int]bool) int {
+		n := 0
+		for start := range sets[0] {
+			ok := true
+			for i := 1; i < len(sets); i++ {
+				if !sets[i][start+i] {
+					ok = false
+					break
+				}
+			}
+			if ok {
+				n++
+			}
+		}
+		return n
+	}
+
+	candidates := map[string]bool{}
+	for _, t := range expanded[0] {
+		for key := range ix.postings[t] {
+			candidates[key] = true
+		}
+	}
+	for key := range candidates {
+		titleSets := make([]map[int]bool, len(ms))
+		contentSets := make([]map[int]bool, len(ms))
+		for i := range ms {
+			titleSets[i], contentSets[i] = positions(i, key)
+		}
+		tf := fieldTF{title: count(titleSets), content: count(contentSets)}
+		if tf.title > 0 || tf.content > 0 {
+			matches[key] = tf
+		}
+	}
+	return matches
+}
+
+// MatchFolder 笔记所在文件夹 folder 是否为 filter 或其子文件夹，filter 为空时总是匹配
+func MatchFolder(folder, filter string) bool {
+	filter = strings.Trim(filter, "/")
+	return filter == "" || folder == filter || strings.HasPrefix(folder, filter+"/")
+}
+
+// MatchTag 标签中是否有 filter 或其下级标签，filter 为空时总是匹配
+func MatchTag(tags []string, filter string) bool {
+	filter = strings.ToLower(strings.Trim(strings.TrimLeft(filter, "#"), "/"))
+	if filter == "" {
+		return true
+	}
+	for _, t := range tags {
+		if t == filter || strings.HasPrefix(t, filter+"/") {
+			return true
+		}
+	}
+	return false
+}
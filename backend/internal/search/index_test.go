@@ -0,0 +1,88 @@
+package search
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func newTestIndex() *Index {
+	ix := NewIndex()
+	now := time.Now()
+	docs := []Document{
+		{Title: "Docker 入门", Folder: "Work", Tags: []string{"ops"}, Content: "安装 docker 并运行第一个容器", UpdatedAt: now},
+		{Title: "部署", Folder: "Work/Sub", Tags: []string{"ops/k8s"}, Content: "用 kubernetes 部署 docker 镜像，docker compose 仅用于开发", UpdatedAt: now.Add(-time.Hour)},
+		{Title: "数据库索引", Folder: "Notes", Content: "MySQL 数据库的索引基于 B+ 树", UpdatedAt: now},
+		{Title: "菜谱", Folder: "Life", Content: "番茄炒蛋：先炒蛋再炒番茄", UpdatedAt: now},
+	}
+	for _, d := range docs {
+		ix.Add(d)
+	}
+	return ix
+}
+
+func TestIndexSearch(t *testing.T) {
+	ix := newTestIndex()
+	tests := []struct {
+		name  string
+		query string
+		opts  Options
+		want  string
+	}{
+		{"标题命中排在前面", "docker", Options{}, "[Docker 入门 部署]"},
+		{"所有词都要命中", "docker kubernetes", Options{}, "[部署]"},
+		{"没有命中", "redis", Options{}, "[]"},
+		{"中文短语", "数据库", Options{}, "[数据库索引]"},
+		{"中文短语须相邻", "数据索引", Options{}, "[]"},
+		{"单个汉字", "蛋", Options{}, "[菜谱]"},
+		{"前缀", "kube*", Options{}, "[部署]"},
+		{"引号短语", `"docker compose"`, Options{}, "[部署]"},
+		{"引号短语顺序不对", `"compose docker"`, Options{}, "[]"},
+		{"文件夹含子文件夹", "docker", Options{Folder: "Work/Sub"}, "[部署]"},
+		{"标签含下级标签", "docker", Options{Tag: "ops"}, "[Docker 入门 部署]"},
+		{"下级标签", "docker", Options{Tag: "ops/k8s"}, "[部署]"},
+		{"分页", "docker", Options{Offset: 1, Limit: 1}, "[部署]"},
+		{"超出范围", "docker", Options{Offset: 5}, "[]"},
+	}
+	for _, tt := range tests {
+		res := ix.Search(ParseQuery(tt.query), tt.opts)
+		var titles []string
+		for _, h := range res.Hits {
+			titles = append(titles, h.Title)
+		}
+		if got := fmt.Sprint(titles); got != tt.want {
+			t.Errorf("%s: %q 得到 %s，期望 %s", tt.name, tt.query, got, tt.want)
+		}
+	}
+
+	if res := ix.Search(ParseQuery("docker"), Options{Limit: 1}); res.Total != 2 || len(res.Hits) != 1 {
+		t.Errorf("Total 应为分页前的总数，得到 %d / %d", res.Total, len(res.Hits))
+	}
+	if res := ix.Search(ParseLooseQuery("怎么部署 redis"), Options{}); len(res.Hits) != 1 || res.Hits[0].Title != "部署" {
+		t.Errorf("宽松查询得到 %+v", res.Hits)
+	}
+}
+
+func TestIndexUpdateAndRemove(t *testing.T) {
+	ix := newTestIndex()
+	ix.Add(Document{Title: "菜谱", Folder: "Life", Content: "红烧肉"})
+	if res := ix.Search(ParseQuery("番茄"), Options{}); res.Total != 0 {
+		t.Errorf("重新索引后旧内容仍能搜到: %+v", res.Hits)
+	}
+	if res := ix.Search(ParseQuery("红烧肉"), Options{}); res.Total != 1 {
+		t.Errorf("新内容搜不到")
+	}
+
+	ix.RemoveFolder("Work")
+	if res := ix.Search(ParseQuery("docker"), Options{}); res.Total != 0 {
+		t.Errorf("删除文件夹后仍能搜到 %+v", res.Hits)
+	}
+	ix.Remove("Notes", "数据库索引")
+	if docs, terms, postings := ix.Counts(); docs != 1 || terms == 0 || postings == 0 {
+		t.Errorf("Counts() = %d, %d, %d", docs, terms, postings)
+	}
+	ix.Remove("Life", "菜谱")
+	if docs, terms, postings := ix.Counts(); docs != 0 || terms != 0 || postings != 0 {
+		t.Errorf("全部删除后 Counts() = %d, %d, %d，倒排表没有清理干净", docs, terms, postings)
+	}
+}
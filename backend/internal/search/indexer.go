@@ -0,0 +1,325 @@
+package search
+
+import (
+	"ai-notes/internal/dao"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// 索引有改动时定期写盘的间隔
+const saveInterval = 30 * time.Second
+
+// Indexer 维护常驻内存的搜索索引
+//   - 订阅存储后端的变更事件增量更新 (写入、移动、删除笔记 / 文件夹)，所有后端行为一致，不依赖数据库的 LIKE / FULLTEXT
+//   - 定期持久化到磁盘；启动时加载索引文件，只重新索引之后修改过的笔记，文件不存在或格式不符时全量重建
+type Indexer struct {
+	store dao.NoteStore
+	path  string // 为空时不持久化
+
+	mu        sync.RWMutex
+	index     *Index
+	dirty     bool
+	lastBuild time.Time
+	buildTook time.Duration
+	lastSaved time.Time
+
+	// 全量重建期间到达的事件，重建完成后在新索引上重放
+	rebuilding bool
+	replay     []dao.NoteEvent
+
+	// 事件队列：存储层的回调里只入队，由后台 goroutine 读取笔记内容并更新索引
+	qmu    sync.Mutex
+	queue  []dao.NoteEvent
+	busy   int // 已出队但还没应用完的事件数
+	signal chan struct{}
+}
+
+// Stats 索引统计信息
+type Stats struct {
+	Documents   int       `json:"documents"`
+	Terms       int       `json:"terms"`
+	Postings    int       `json:"postings"`
+	Path        string    `json:"path"`
+	FileSize    int64     `json:"file_size"`
+	LastBuild   time.Time `json:"last_build"`
+	BuildMillis int64     `json:"build_ms"`
+	LastSaved   time.Time `json:"last_saved"`
+	Dirty       bool      `json:"dirty"`   // 有尚未写盘的修改
+	Pending     int       `json:"pending"` // 等待处理的变更事件
+	Rebuilding  bool      `json:"rebuilding"`
+}
+
+// NewIndexer 加载或重建索引，并开始跟踪存储后端的变更
+func NewIndexer(store dao.NoteStore, path string) *Indexer {
+	x := &Indexer{store: store, path: path, signal: make(chan struct{}, 1)}
+
+	// 先订阅再加载，加载期间的修改会在之后的事件中补上
+	if obs, ok := store.(dao.Observable); ok {
+		obs.Subscribe(x.enqueue)
+	} else {
+		log.Println("当前存储后端不支持变更通知，搜索索引只能通过重建更新")
+	}
+
+	if err := x.load(); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Println("加载搜索索引失败，重新构建:", err)
+		}
+		if err := x.Reindex(); err != nil {
+			log.Println("构建搜索索引失败:", err)
+		}
+	}
+
+	go x.worker()
+	if path != "" {
+		go x.saver()
+	}
+	return x
+}
+
+// Search 在当前索引上检索
+func (x *Indexer) Search(q Query, opts Options) Result {
+	x.mu.RLock()
+	ix := x.index
+	x.mu.RUnlock()
+	return ix.Search(q, opts)
+}
+
+// Stats 索引统计信息
+func (x *Indexer) Stats() Stats {
+	x.mu.RLock()
+	st := Stats{
+		Path:        x.path,
+		LastBuild:   x.lastBuild,
+		BuildMillis: x.buildTook.Milliseconds(),
+		LastSaved:   x.lastSaved,
+		Dirty:       x.dirty,
+		Rebuilding:  x.rebuilding,
+	}
+	ix := x.index
+	x.mu.RUnlock()
+
+	st.Documents, st.Terms, st.Postings = ix.Counts()
+	x.qmu.Lock()
+	st.Pending = len(x.queue) + x.busy
+	x.qmu.Unlock()
+	if x.path != "" {
+		if info, err := os.Stat(x.path); err == nil {
+			st.FileSize = info.Size()
+		}
+	}
+	return st
+}
+
+// Reindex 读取所有笔记全量重建索引，完成后替换当前索引并写盘
+func (x *Indexer) Reindex() error {
+	x.mu.Lock()
+	if x.rebuilding {
+		x.mu.Unlock()
+		return errors.New("索引正在重建中")
+	}
+	x.rebuilding = true
+	x.replay = nil
+	x.mu.Unlock()
+
+	start := time.Now()
+	ix := NewIndex()
+	notes, err := x.store.ListNotes()
+	if err == nil {
+		for _, n := range notes {
+			x.indexNote(ix, n.ID, n.Title, n.Folder, n.UpdatedAt)
+		}
+	}
+
+	x.mu.Lock()
+	x.rebuilding = false
+	replay := x.replay
+	x.replay = nil
+	if err != nil {
+		if x.index == nil {
+			x.index = NewIndex()
+		}
+		x.mu.Unlock()
+		return err
+	}
+	x.index = ix
+	x.dirty = true
+	x.lastBuild = time.Now()
+	x.buildTook = time.Since(start)
+	x.mu.Unlock()
+
+	// 重建期间的修改可能没有反映在刚读到的内容里，重放一遍
+	for _, ev := range replay {
+		x.apply(ev)
+	}
+	log.Printf("搜索索引重建完成：%d 篇笔记，耗时 %v", ix.Len(), time.Since(start).Round(time.Millisecond))
+	x.save()
+	return nil
+}
+
+// load 加载索引文件，并补上保存之后的修改
+func (x *Indexer) load() error {
+	if x.path == "" {
+		return fs.ErrNotExist
+	}
+	start := time.Now()
+	ix, savedAt, err := LoadIndex(x.path)
+	if err != nil {
+		return err
+	}
+	notes, err := x.store.ListNotes()
+	if err != nil {
+		return err
+	}
+
+	// 以保存时刻为界：之后修改过的、索引里没有的笔记重新索引；已不存在的笔记移除
+	// (保存时事件队列总是空的，所以保存时刻之前的修改都已在索引中；留 1 秒余量应对数据库时间精度)
+	cutoff := savedAt.Add(-time.Second)
+	live := make(map[[2]string]bool, len(notes))
+	stale := 0
+	for _, n := range notes {
+		live[[2]string{n.Folder, n.Title}] = true
+		if ix.has(n.Folder, n.Title) && !n.UpdatedAt.After(cutoff) {
+			continue
+		}
+		x.indexNote(ix, n.ID, n.Title, n.Folder, n.UpdatedAt)
+		stale++
+	}
+	for _, k := range ix.keys() {
+		if !live[k] {
+			ix.Remove(k[0], k[1])
+			stale++
+		}
+	}
+
+	x.mu.Lock()
+	x.index = ix
+	x.lastSaved = savedAt
+	x.lastBuild = time.Now()
+	x.buildTook = time.Since(start)
+	x.dirty = stale > 0
+	x.mu.Unlock()
+	log.Printf("已加载搜索索引：%d 篇笔记，更新 %d 篇", ix.Len(), stale)
+	return nil
+}
+
+// indexNote 读取笔记内容加入索引；笔记已不存在时从索引中移除
+func (x *Indexer) indexNote(ix *Index, id uint, title, folder string, updatedAt time.Time) {
+	content, err := x.store.GetNote(title, folder)
+	if err != nil {
+		ix.Remove(folder, title)
+		return
+	}
+	ix.Add(Document{
+		ID:        id,
+		Title:     title,
+		Folder:    folder,
+		Tags:      dao.ExtractTags(content),
+		Content:   content,
+		UpdatedAt: updatedAt,
+	})
+}
+
+// enqueue 存储层的变更回调，只入队不阻塞写入请求
+func (x *Indexer) enqueue(ev dao.NoteEvent) {
+	x.qmu.Lock()
+	x.queue = append(x.queue, ev)
+	x.qmu.Unlock()
+	select {
+	case x.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (x *Indexer) worker() {
+	for range x.signal {
+		for {
+			x.qmu.Lock()
+			events := x.queue
+			x.queue = nil
+			x.busy = len(events)
+			x.qmu.Unlock()
+			if len(events) == 0 {
+				break
+			}
+			for _, ev := range events {
+				x.apply(ev)
+			}
+			x.qmu.Lock()
+			x.busy = 0
+			x.qmu.Unlock()
+		}
+	}
+}
+
+// apply 把一个变更事件应用到当前索引
+func (x *Indexer) apply(ev dao.NoteEvent) {
+	x.mu.Lock()
+	ix := x.index
+	if x.rebuilding {
+		x.replay = append(x.replay, ev)
+	}
+	x.dirty = true
+	x.mu.Unlock()
+	if ix == nil {
+		return
+	}
+
+	switch ev.Op {
+	case "remove":
+		ix.Remove(ev.Folder, ev.Title)
+		return
+	case "remove_folder":
+		if ev.Folder != "" {
+			ix.RemoveFolder(ev.Folder)
+		}
+		return
+	}
+	x.indexNote(ix, ev.ID, ev.Title, ev.Folder, ev.UpdatedAt)
+}
+
+// saver 定期把有改动的索引写盘
+func (x *Indexer) saver() {
+	ticker := time.NewTicker(saveInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		x.mu.RLock()
+		dirty := x.dirty && !x.rebuilding
+		x.mu.RUnlock()
+		if dirty {
+			x.save()
+		}
+	}
+}
+
+// save 写盘；还有未处理的事件时跳过 (保持 dirty，下一轮再保存)，保证文件里的索引不落后于保存时刻
+func (x *Indexer) save() {
+	if x.path == "" {
+		return
+	}
+	x.qmu.Lock()
+	pending := len(x.queue) + x.busy
+	x.qmu.Unlock()
+	if pending > 0 {
+		return
+	}
+	x.mu.Lock()
+	ix := x.index
+	savedAt := time.Now()
+	x.dirty = false
+	x.mu.Unlock()
+
+	if err := ix.Save(x.path, savedAt); err != nil {
+		log.Println("保存搜索索引失败:", err)
+		x.mu.Lock()
+		x.dirty = true
+		x.mu.Unlock()
+		return
+	}
+	x.mu.Lock()
+	x.lastSaved = savedAt
+	x.mu.Unlock()
+}
@@ -0,0 +1,79 @@
+package search
+
+import (
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// indexFormat 索引文件格式版本；分词规则或结构变化时加一，旧文件会被丢弃并重建
+const indexFormat = 1
+
+// snapshot 索引文件内容
+type snapshot struct {
+	Format       int
+	SavedAt      time.Time // 保存时刻，之后修改过的笔记在启动时重新索引
+	Docs         map[string]*docEntry
+	Postings     map[string]map[string]*posting
+	TitleTotal   int
+	ContentTotal int
+}
+
+// Save 把索引写入文件 (先写临时文件再 rename)
+func (ix *Index) Save(path string, savedAt time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-index-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // rename 成功后这里是 no-op
+
+	ix.mu.RLock()
+	err = gob.NewEncoder(tmp).Encode(&snapshot{
+		Format:       indexFormat,
+		SavedAt:      savedAt,
+		Docs:         ix.docs,
+		Postings:     ix.postings,
+		TitleTotal:   ix.titleTotal,
+		ContentTotal: ix.contentTotal,
+	})
+	ix.mu.RUnlock()
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadIndex 从文件读取索引，返回保存时刻
+func LoadIndex(path string) (*Index, time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer f.Close()
+
+	var snap snapshot
+	if err := gob.NewDecoder(f).Decode(&snap); err != nil {
+		return nil, time.Time{}, err
+	}
+	if snap.Format != indexFormat {
+		return nil, time.Time{}, fmt.Errorf("索引格式版本 %d 与当前版本 %d 不一致", snap.Format, indexFormat)
+	}
+	ix := NewIndex()
+	if snap.Docs != nil {
+		ix.docs = snap.Docs
+	}
+	if snap.Postings != nil {
+		ix.postings = snap.Postings
+	}
+	ix.titleTotal, ix.contentTotal = snap.TitleTotal, snap.ContentTotal
+	return ix, snap.SavedAt, nil
+}
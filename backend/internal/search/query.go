@@ -0,0 +1,115 @@
+package search
+
+import "strings"
+
+// Clause 查询中的一个条件，所有条件都要满足 (AND)
+//   - 单个词：foo
+//   - 前缀：foo* (匹配 foobar)
+//   - 短语："hello world"，词必须按顺序相邻出现；
+//     不带引号的连续中文 (例如 数据库) 分词后是多个 bigram，也按短语处理
+type Clause struct {
+	Terms  []string
+	Phrase bool
+	Prefix bool // 最后一个词按前缀匹配
+}
+
+// Query 解析后的查询
+type Query struct {
+	Clauses []Clause
+	// Any 为 true 时任意条件命中即可 (OR)，得分为各条件之和，命中越多排名越靠前
+	Any bool
+}
+
+// Empty 查询里没有任何可检索的词
+func (q Query) Empty() bool {
+	return len(q.Clauses) == 0
+}
+
+// ParseQuery 解析查询字符串
+func ParseQuery(s string) Query {
+	var q Query
+	// 空格分隔的词在这里已经拆开，一段文本分出多个词时 (引号短语、中文、"foo-bar") 都按短语处理
+	add := func(text string, prefix bool) {
+		tokens := Tokenize(text)
+		if len(tokens) == 0 {
+			return
+		}
+		c := Clause{Phrase: len(tokens) > 1, Prefix: prefix}
+		for _, t := range tokens {
+			c.Terms = append(c.Terms, t.Term)
+		}
+		q.Clauses = append(q.Clauses, c)
+	}
+
+	for i := 0; i < len(s); {
+		switch {
+		case s[i] == '"':
+			end := strings.IndexByte(s[i+1:], '"')
+			if end < 0 {
+				end = len(s) - i - 1 // 没有闭合的引号，到结尾为止
+			}
+			phrase := s[i+1 : i+1+end]
+			i += end + 2
+			prefix := i < len(s) && s[i] == '*'
+			add(phrase, prefix)
+		case s[i] == ' ' || s[i] == '\t' || s[i] == '\n':
+			i++
+		default:
+			end := strings.IndexAny(s[i:], " \t\n\"")
+			if end < 0 {
+				end = len(s) - i
+			}
+			word := s[i : i+end]
+			i += end
+			add(strings.TrimRight(word, "*"), strings.HasSuffix(word, "*"))
+		}
+	}
+	return q
+}
+
+// ParseLooseQuery 把自然语言文本 (如一个问题) 转成宽松查询：每个词 / bigram 单独作为条件，任意命中即可
+// 用于问答检索，问题里的词不会全部出现在笔记中
+func ParseLooseQuery(s string) Query {
+	q := Query{Any: true}
+	seen := map[string]bool{}
+	for _, t := range Tokenize(s) {
+		if seen[t.Term] {
+			continue
+		}
+		seen[t.Term] = true
+		q.Clauses = append(q.Clauses, Clause{Terms: []string{t.Term}})
+	}
+	return q
+}
+
+// termMatcher 查询词与索引词的匹配规则
+type termMatcher struct {
+	term   string
+	prefix bool
+}
+
+func (m termMatcher) match(t string) bool {
+	switch {
+	case m.prefix:
+		return strings.HasPrefix(t, m.term)
+	case singleCJK(m.term):
+		// 单个汉字：匹配所有包含它的 bigram (以及单字)
+		return strings.Contains(t, m.term)
+	default:
+		return t == m.term
+	}
+}
+
+// exact 不需要扫描词典，直接按词查倒排表即可
+func (m termMatcher) exact() bool {
+	return !m.prefix && !singleCJK(m.term)
+}
+
+// matchers 条件中每个位置的匹配规则
+func (c Clause) matchers() []termMatcher {
+	ms := make([]termMatcher, len(c.Terms))
+	for i, t := range c.Terms {
+		ms[i] = termMatcher{term: t, prefix: c.Prefix && i == len(c.Terms)-1}
+	}
+	return ms
+}
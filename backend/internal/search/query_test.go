@@ -0,0 +1,40 @@
+package search
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "[]"},
+		{"  ", "[]"},
+		{"foo", "[{[foo] false false}]"},
+		{"Foo bar", "[{[foo] false false} {[bar] false false}]"},
+		{"foo*", "[{[foo] false true}]"},
+		{`"hello world"`, "[{[hello world] true false}]"},
+		{`"hello wor"*`, "[{[hello wor] true true}]"},
+		{`"unclosed phrase`, "[{[unclosed phrase] true false}]"},
+		{"数据库", "[{[数据 据库] true false}]"},
+		{"foo-bar", "[{[foo bar] true false}]"},
+		{"*** ,,,", "[]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(ParseQuery(tt.query).Clauses); got != tt.want {
+			t.Errorf("ParseQuery(%q) = %s，期望 %s", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseLooseQuery(t *testing.T) {
+	q := ParseLooseQuery("如何部署 docker？docker 部署")
+	if !q.Any {
+		t.Error("宽松查询应为 OR")
+	}
+	if got := fmt.Sprint(q.Clauses); got != "[{[如何] false false} {[何部] false false} {[部署] false false} {[docker] false false}]" {
+		t.Errorf("得到 %s", got)
+	}
+}
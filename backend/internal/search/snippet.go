@@ -0,0 +1,119 @@
+package search
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// 摘要长度 (字符数)，以及第一个命中词之前保留的上下文长度
+const (
+	snippetRunes   = 160
+	snippetContext = 40
+)
+
+type span struct {
+	start, end int
+}
+
+// matchSpans 文本中与查询词匹配的位置，重叠的 (中文 bigram) 合并成一段
+func matchSpans(text string, q Query) []span {
+	var ms []termMatcher
+	for _, c := range q.Clauses {
+		ms = append(ms, c.matchers()...)
+	}
+
+	var spans []span
+	for _, t := range Tokenize(text) {
+		for _, m := range ms {
+			if !m.match(t.Term) {
+				continue
+			}
+			sp := span{t.Start, t.End}
+			if m.exact() || m.prefix {
+				spans = append(spans, sp)
+				break
+			}
+			// 单字查询只标出这个字，而不是整个 bigram
+			if i := strings.Index(text[t.Start:t.End], m.term); i >= 0 {
+				sp = span{t.Start + i, t.Start + i + len(m.term)}
+			}
+			spans = append(spans, sp)
+			break
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	var merged []span
+	for _, s := range spans {
+		if n := len(merged); n > 0 && s.start <= merged[n-1].end {
+			if s.end > merged[n-1].end {
+				merged[n-1].end = s.end
+			}
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+// Highlight 截取第一个命中词附近的文本 (maxRunes 为 0 时不截取)，HTML 转义后用 <mark> 标出命中的词
+// 换行压缩为空格，便于在列表中单行展示
+func Highlight(text string, q Query, maxRunes int) string {
+	spans := matchSpans(text, q)
+
+	from, to := 0, len(text)
+	if maxRunes > 0 && utf8.RuneCountInString(text) > maxRunes {
+		if len(spans) > 0 {
+			from = backRunes(text, spans[0].start, snippetContext)
+		}
+		to = forwardRunes(text, from, maxRunes)
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	pos := from
+	for _, s := range spans {
+		if s.end <= from || s.start >= to {
+			continue
+		}
+		start, end := max(s.start, from), min(s.end, to)
+		b.WriteString(escape(text[pos:start]))
+		b.WriteString("<mark>")
+		b.WriteString(escape(text[start:end]))
+		b.WriteString("</mark>")
+		pos = end
+	}
+	b.WriteString(escape(text[pos:to]))
+	if to < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+var flatten = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ", "\t", " ")
+
+func escape(s string) string {
+	return html.EscapeString(flatten.Replace(s))
+}
+
+// backRunes 从字节位置 pos 往前退 n 个字符
+func backRunes(s string, pos, n int) int {
+	for ; n > 0 && pos > 0; n-- {
+		_, size := utf8.DecodeLastRuneInString(s[:pos])
+		pos -= size
+	}
+	return pos
+}
+
+// forwardRunes 从字节位置 pos 往后走 n 个字符
+func forwardRunes(s string, pos, n int) int {
+	for ; n > 0 && pos < len(s); n-- {
+		_, size := utf8.DecodeRuneInString(s[pos:])
+		pos += size
+	}
+	return pos
+}
@@ -0,0 +1,84 @@
+// Package search 笔记全文检索：中英文混合分词、查询解析、BM25 排序与摘要高亮
+package search
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Token 分词结果
+// 英文 / 数字按连续字母数字切词并转小写；中日韩文字没有空格分隔，按相邻两字 (bigram) 切分，
+// 例如 "数据库" -> "数据" "据库"，这样任意长度的中文查询都能用相邻 bigram 的短语匹配找到
+type Token struct {
+	Term       string
+	Pos        int // 第几个词，短语匹配用
+	Start, End int // 在原文中的字节偏移，摘要高亮用
+}
+
+// isCJK 中日韩文字 (汉字、假名、谚文)
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// isWordRune 英文 / 数字等非 CJK 的词字符
+func isWordRune(r rune) bool {
+	return (unicode.IsLetter(r) || unicode.IsDigit(r)) && !isCJK(r)
+}
+
+// Tokenize 把文本切成检索词
+func Tokenize(text string) []Token {
+	var tokens []Token
+	emit := func(term string, start, end int) {
+		tokens = append(tokens, Token{Term: term, Pos: len(tokens), Start: start, End: end})
+	}
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case isWordRune(r):
+			start := i
+			buf := make([]rune, 0, 16)
+			for i < len(text) {
+				r, size := utf8.DecodeRuneInString(text[i:])
+				if !isWordRune(r) {
+					break
+				}
+				buf = append(buf, unicode.ToLower(r))
+				i += size
+			}
+			emit(string(buf), start, i)
+
+		case isCJK(r):
+			// 收集整段连续的 CJK 字符，再切 bigram
+			var offs []int
+			for i < len(text) {
+				r, size := utf8.DecodeRuneInString(text[i:])
+				if !isCJK(r) {
+					break
+				}
+				offs = append(offs, i)
+				i += size
+			}
+			offs = append(offs, i)
+			if len(offs) == 2 {
+				// 单个字
+				emit(text[offs[0]:offs[1]], offs[0], offs[1])
+				continue
+			}
+			for k := 0; k+2 < len(offs); k++ {
+				emit(text[offs[k]:offs[k+2]], offs[k], offs[k+2])
+			}
+
+		default:
+			i += size
+		}
+	}
+	return tokens
+}
+
+// singleCJK 单个 CJK 字符的查询词：索引里只有 bigram，需要匹配所有包含该字的词
+func singleCJK(term string) bool {
+	r, size := utf8.DecodeRuneInString(term)
+	return size == len(term) && isCJK(r)
+}
@@ -0,0 +1,48 @@
+package search
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"", "[]"},
+		{"Hello, World!", "[hello world]"},
+		{"foo-bar_baz 42", "[foo bar baz 42]"},
+		{"数据库", "[数据 据库]"},
+		{"库", "[库]"},
+		{"用Docker部署", "[用 docker 部署]"},
+		{"MySQL数据库索引", "[mysql 数据 据库 库索 索引]"},
+		{"ひらがなとカタカナ", "[ひら らが がな なと とカ カタ タカ カナ]"},
+	}
+	for _, tt := range tests {
+		var terms []string
+		for _, tok := range Tokenize(tt.text) {
+			terms = append(terms, tok.Term)
+		}
+		if got := fmt.Sprint(terms); got != tt.want {
+			t.Errorf("Tokenize(%q) = %s，期望 %s", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTokenizeOffsets(t *testing.T) {
+	text := "Go 语言"
+	want := []Token{
+		{Term: "go", Pos: 0, Start: 0, End: 2},
+		{Term: "语言", Pos: 1, Start: 3, End: 9},
+	}
+	got := Tokenize(text)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("得到 %+v，期望 %+v", got, want)
+	}
+	for _, tok := range got {
+		if tok.Term == "语言" && text[tok.Start:tok.End] != "语言" {
+			t.Errorf("偏移量不对: %q", text[tok.Start:tok.End])
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// Package semantic 基于向量的语义检索：按标题切分笔记、调用 embeddings 接口、暴力余弦相似度检索
+package semantic
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"unicode/utf8"
+)
+
+// 一段过长时按段落继续切分的上限 (字符数)，避免超出向量模型的输入长度
+const maxChunkRunes = 1500
+
+// Chunk 笔记的一段：一个标题及其下的正文
+type Chunk struct {
+	Heading string // 所在标题 (含上级标题，如 "安装 > Docker")，标题之前的内容为空
+	Text    string
+}
+
+// embedText 发送给向量模型的文本：带上笔记标题和章节标题，短段落也有足够的上下文
+func (c Chunk) embedText(title string) string {
+	head := title
+	if c.Heading != "" {
+		head += " > " + c.Heading
+	}
+	return head + "\n\n" + c.Text
+}
+
+// SplitChunks 按 Markdown 标题切分笔记；代码块中的 # 不算标题，过长的段再按空行切分
+func SplitChunks(content string) []Chunk {
+	var chunks []Chunk
+	var stack []string // 当前各级标题
+	var levels []int
+	var buf strings.Builder
+
+	flush := func() {
+		text := strings.TrimSpace(buf.String())
+		buf.Reset()
+		if text == "" {
+			return
+		}
+		heading := strings.Join(stack, " > ")
+		for _, part := range splitLong(text) {
+			chunks = append(chunks, Chunk{Heading: heading, Text: part})
+		}
+	}
+
+	fence := ""
+	for _, line := range strings.SplitAfter(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			buf.WriteString(line)
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			buf.WriteString(line)
+			continue
+		}
+		if level, text := ParseHeading(trimmed); level > 0 {
+			flush()
+			for len(levels) > 0 && levels[len(levels)-1] >= level {
+				levels, stack = levels[:len(levels)-1], stack[:len(stack)-1]
+			}
+			levels, stack = append(levels, level), append(stack, text)
+			continue
+		}
+		buf.WriteString(line)
+	}
+	flush()
+
+	// 只有标题没有正文的笔记，至少保留标题本身
+	if len(chunks) == 0 && len(stack) > 0 {
+		chunks = append(chunks, Chunk{Heading: strings.Join(stack, " > ")})
+	}
+	return chunks
+}
+
+// ParseHeading ATX 标题 "## 标题" 的级别和文字，不是标题时 level 为 0
+func ParseHeading(line string) (level int, text string) {
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || (level < len(line) && line[level] != ' ' && line[level] != '\t') {
+		return 0, ""
+	}
+	text = strings.TrimSpace(strings.TrimRight(strings.TrimSpace(line[level:]), "#"))
+	if text == "" {
+		return 0, ""
+	}
+	return level, text
+}
+
+// splitLong 按空行把过长的文本合并成不超过 maxChunkRunes 的若干段；单个段落过长时硬切
+func splitLong(text string) []string {
+	if utf8.RuneCountInString(text) <= maxChunkRunes {
+		return []string{text}
+	}
+	var parts []string
+	var cur strings.Builder
+	curRunes := 0
+	emit := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" {
+			parts = append(parts, s)
+		}
+		cur.Reset()
+		curRunes = 0
+	}
+	for _, para := range strings.Split(text, "\n\n") {
+		n := utf8.RuneCountInString(para)
+		if curRunes > 0 && curRunes+2+n > maxChunkRunes { // 2 为段落之间的空行
+			emit()
+		}
+		for n > maxChunkRunes {
+			cut := 0
+			for i := 0; i < maxChunkRunes; i++ {
+				_, size := utf8.DecodeRuneInString(para[cut:])
+				cut += size
+			}
+			cur.WriteString(para[:cut])
+			emit()
+			para = para[cut:]
+			n -= maxChunkRunes
+		}
+		if curRunes > 0 {
+			cur.WriteString("\n\n")
+			curRunes += 2
+		}
+		cur.WriteString(para)
+		curRunes += n
+	}
+	emit()
+	return parts
+}
+
+// hashText 内容哈希，内容不变时复用已有的向量
+func hashText(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:16])
+}
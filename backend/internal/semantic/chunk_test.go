@@ -0,0 +1,84 @@
+package semantic
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitChunks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []Chunk
+	}{
+		{"空笔记", "", nil},
+		{"没有标题", "hello\nworld\n", []Chunk{{Text: "hello\nworld"}}},
+		{"标题之前的内容", "intro\n# A\nbody\n", []Chunk{{Text: "intro"}, {Heading: "A", Text: "body"}}},
+		{"多级标题", "# A\na\n## B\nb\n### C\nc\n## D\nd\n# E\ne\n", []Chunk{
+			{Heading: "A", Text: "a"},
+			{Heading: "A > B", Text: "b"},
+			{Heading: "A > B > C", Text: "c"},
+			{Heading: "A > D", Text: "d"},
+			{Heading: "E", Text: "e"},
+		}},
+		{"代码块中的 # 不是标题", "# A\n```sh\n# comment\n```\n", []Chunk{{Heading: "A", Text: "```sh\n# comment\n```"}}},
+		{"没有正文的标题被跳过", "# A\n## B\nb\n", []Chunk{{Heading: "A > B", Text: "b"}}},
+		{"只有标题", "# A\n## B\n", []Chunk{{Heading: "A > B"}}},
+	}
+	for _, tt := range tests {
+		got := SplitChunks(tt.content)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: 得到 %q，期望 %q", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: 第 %d 段 %q，期望 %q", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestSplitChunksLong(t *testing.T) {
+	para := strings.Repeat("字", 700)
+	huge := strings.Repeat("x", maxChunkRunes*2+10)
+	chunks := SplitChunks("# A\n" + para + "\n\n" + para + "\n\n" + para + "\n\n" + huge)
+	var lens []int
+	for _, c := range chunks {
+		if c.Heading != "A" {
+			t.Errorf("切分后的段应保留标题，得到 %q", c.Heading)
+		}
+		lens = append(lens, utf8.RuneCountInString(c.Text))
+	}
+	// 前两个段落合并成一段，第三段放不下另起一段，超长段落硬切
+	want := []int{700 + 2 + 700, 700, maxChunkRunes, maxChunkRunes, 10}
+	if len(lens) != len(want) {
+		t.Fatalf("段长 %v，期望 %v", lens, want)
+	}
+	for i := range want {
+		if lens[i] != want[i] {
+			t.Fatalf("段长 %v，期望 %v", lens, want)
+		}
+	}
+}
+
+func TestParseHeading(t *testing.T) {
+	tests := []struct {
+		line  string
+		level int
+		text  string
+	}{
+		{"# A", 1, "A"},
+		{"### A ###", 3, "A"},
+		{"#A", 0, ""},
+		{"#", 0, ""},
+		{"####### A", 0, ""},
+		{"text", 0, ""},
+	}
+	for _, tt := range tests {
+		if level, text := ParseHeading(tt.line); level != tt.level || text != tt.text {
+			t.Errorf("ParseHeading(%q) = %d, %q，期望 %d, %q", tt.line, level, text, tt.level, tt.text)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+package semantic
+
+import "context"
+
+// Embedder 把一批文本转换为向量，返回的向量与输入一一对应 (ai.Embedder 实现了该接口)
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	// Model 模型名，更换模型后旧向量不再可比，需要重新生成
+	Model() string
+}
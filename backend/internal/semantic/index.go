@@ -0,0 +1,366 @@
+package semantic
+
+import (
+	"ai-notes/internal/search"
+	"math"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 结果摘要长度 (字符数)
+const snippetRunes = 160
+
+// 被删除笔记的向量暂存上限：移动 / 改文件夹时先删后写，内容没变的段落可以直接复用
+const maxRecycled = 10000
+
+// chunkVec 一段及其向量 (已归一化，余弦相似度即点积)
+type chunkVec struct {
+	Heading string
+	Text    string
+	Hash    string // 发送给模型的文本的哈希
+	Vec     []float32
+}
+
+// noteEntry 一篇笔记的所有段落
+type noteEntry struct {
+	ID        uint
+	Title     string
+	Folder    string
+	Tags      []string
+	UpdatedAt time.Time
+	Hash      string // 标题 + 内容的哈希，没变化时不重新生成向量
+	Chunks    []chunkVec
+}
+
+// chunkRef 扁平矩阵中的一行对应的段落
+type chunkRef struct {
+	note  *noteEntry
+	chunk int
+}
+
+// Index 内存向量索引，暴力计算余弦相似度
+// 所有向量拼成一个连续的 []float32 矩阵，检索时按 CPU 数分片并行扫描，几万段以内足够快
+type Index struct {
+	mu       sync.RWMutex
+	notes    map[string]*noteEntry // 键为 docKey(文件夹, 标题)
+	recycled map[string][]float32  // 最近删除的段落：哈希 -> 向量
+
+	matrix []float32
+	refs   []chunkRef
+	dims   int
+	stale  bool // notes 有变化，矩阵需要重建
+}
+
+// NewIndex 创建空索引
+func NewIndex() *Index {
+	return &Index{notes: make(map[string]*noteEntry), recycled: make(map[string][]float32)}
+}
+
+func docKey(folder, title string) string {
+	return folder + "\x00" + title
+}
+
+// put 添加或替换一篇笔记
+func (ix *Index) put(e *noteEntry) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.notes[docKey(e.Folder, e.Title)] = e
+	ix.stale = true
+}
+
+// get 已索引的笔记
+func (ix *Index) get(folder, title string) *noteEntry {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.notes[docKey(folder, title)]
+}
+
+// byID 按笔记 ID 查找 (只有支持 ID 的后端才有)
+func (ix *Index) byID(id uint) *noteEntry {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	for _, e := range ix.notes {
+		if e.ID == id {
+			return e
+		}
+	}
+	return nil
+}
+
+// Remove 删除一篇笔记，其向量暂存以便复用
+func (ix *Index) Remove(folder, title string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(docKey(folder, title))
+}
+
+// RemoveFolder 删除文件夹 (含子文件夹) 下的所有笔记
+func (ix *Index) RemoveFolder(folder string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	for key, e := range ix.notes {
+		if search.MatchFolder(e.Folder, folder) {
+			ix.remove(key)
+		}
+	}
+}
+
+func (ix *Index) remove(key string) {
+	e, ok := ix.notes[key]
+	if !ok {
+		return
+	}
+	if len(ix.recycled)+len(e.Chunks) > maxRecycled {
+		ix.recycled = make(map[string][]float32)
+	}
+	for _, c := range e.Chunks {
+		ix.recycled[c.Hash] = c.Vec
+	}
+	delete(ix.notes, key)
+	ix.stale = true
+}
+
+// reusable 按哈希查找可复用的向量：同一笔记的旧版本或最近删除的笔记
+func (ix *Index) reusable(old *noteEntry, hash string) []float32 {
+	if old != nil {
+		for _, c := range old.Chunks {
+			if c.Hash == hash {
+				return c.Vec
+			}
+		}
+	}
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.recycled[hash]
+}
+
+// keys 所有已索引笔记的 (文件夹, 标题)
+func (ix *Index) keys() [][2]string {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	keys := make([][2]string, 0, len(ix.notes))
+	for _, e := range ix.notes {
+		keys = append(keys, [2]string{e.Folder, e.Title})
+	}
+	return keys
+}
+
+// Counts 笔记数、段落数
+func (ix *Index) Counts() (notes, chunks int) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	for _, e := range ix.notes {
+		chunks += len(e.Chunks)
+	}
+	return len(ix.notes), chunks
+}
+
+// rebuild 重新拼接向量矩阵；只保留最常见维度的向量，其余 (如同名模型换了维度后的残留) 跳过
+// (换模型时整个向量文件在加载时就被丢弃，见 LoadIndex)
+func (ix *Index) rebuild() {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if !ix.stale {
+		return
+	}
+	counts := make(map[int]int)
+	for _, e := range ix.notes {
+		for _, c := range e.Chunks {
+			if len(c.Vec) > 0 {
+				counts[len(c.Vec)]++
+			}
+		}
+	}
+	dims := majorityDims(counts)
+	total := counts[dims]
+	ix.matrix = make([]float32, 0, total*dims)
+	ix.refs = make([]chunkRef, 0, total)
+	for _, e := range ix.notes {
+		for i, c := range e.Chunks {
+			if len(c.Vec) != dims {
+				continue
+			}
+			ix.matrix = append(ix.matrix, c.Vec...)
+			ix.refs = append(ix.refs, chunkRef{note: e, chunk: i})
+		}
+	}
+	ix.dims = dims
+	ix.stale = false
+}
+
+// majorityDims 出现次数最多的维度，次数相同时取较大的维度，保证结果与遍历顺序无关
+func majorityDims(counts map[int]int) int {
+	dims := 0
+	for d, n := range counts {
+		if n > counts[dims] || (n == counts[dims] && d > dims) {
+			dims = d
+		}
+	}
+	return dims
+}
+
+// Options 检索过滤
+type Options struct {
+	Folder string // 只搜该文件夹及其子文件夹
+	Tag    string // 只搜带该标签 (或其下级标签) 的笔记
+	Limit  int
+	// 排除的笔记 (相关笔记不返回自己)
+	ExcludeFolder, ExcludeTitle string
+}
+
+// Hit 一条语义检索结果：笔记及其中最相关的一段
+type Hit struct {
+	ID        uint      `json:"id,omitempty"`
+	Title     string    `json:"title"`
+	Folder    string    `json:"folder"`
+	Tags      []string  `json:"tags,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	Score     float64   `json:"score"`   // 余弦相似度
+	Heading   string    `json:"heading"` // 最相关段落所在的标题
+	Snippet   string    `json:"snippet"` // 最相关段落的开头 (纯文本)
+	// Text 最相关段落的全文，供问答等内部使用，不返回给前端
+	Text string `json:"-"`
+}
+
+// Search 与 query 向量最相似的笔记，每篇笔记取最相似的一段计分
+func (ix *Index) Search(query []float32, opts Options) []Hit {
+	ix.rebuild()
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	hits := []Hit{}
+	if ix.dims == 0 || len(query) != ix.dims {
+		return hits
+	}
+	q := normalize(query)
+	scores := ix.scan(q)
+
+	best := make(map[*noteEntry]int)
+	for i, ref := range ix.refs {
+		e := ref.note
+		if j, ok := best[e]; ok && scores[j] >= scores[i] {
+			continue
+		}
+		if e.Folder == opts.ExcludeFolder && e.Title == opts.ExcludeTitle {
+			continue
+		}
+		if !search.MatchFolder(e.Folder, opts.Folder) || !search.MatchTag(e.Tags, opts.Tag) {
+			continue
+		}
+		best[e] = i
+	}
+
+	rows := make([]int, 0, len(best))
+	for _, i := range best {
+		rows = append(rows, i)
+	}
+	sort.Slice(rows, func(a, b int) bool {
+		if scores[rows[a]] != scores[rows[b]] {
+			return scores[rows[a]] > scores[rows[b]]
+		}
+		return ix.refs[rows[a]].note.Title < ix.refs[rows[b]].note.Title
+	})
+	if opts.Limit > 0 && len(rows) > opts.Limit {
+		rows = rows[:opts.Limit]
+	}
+	for _, i := range rows {
+		e, c := ix.refs[i].note, ix.refs[i].note.Chunks[ix.refs[i].chunk]
+		hits = append(hits, Hit{
+			ID:        e.ID,
+			Title:     e.Title,
+			Folder:    e.Folder,
+			Tags:      e.Tags,
+			UpdatedAt: e.UpdatedAt,
+			Score:     math.Round(float64(scores[i])*10000) / 10000,
+			Heading:   c.Heading,
+			Snippet:   snippet(c.Text),
+			Text:      c.Text,
+		})
+	}
+	return hits
+}
+
+// scan 计算 q 与矩阵每一行的点积，分片并行
+func (ix *Index) scan(q []float32) []float32 {
+	rows := len(ix.refs)
+	scores := make([]float32, rows)
+	workers := runtime.GOMAXPROCS(0)
+	if rows < 4096 {
+		workers = 1
+	}
+	per := (rows + workers - 1) / workers
+	var wg sync.WaitGroup
+	for from := 0; from < rows; from += per {
+		to := min(from+per, rows)
+		wg.Add(1)
+		go func(from, to int) {
+			defer wg.Done()
+			dims := ix.dims
+			for i := from; i < to; i++ {
+				row := ix.matrix[i*dims : (i+1)*dims]
+				var dot float32
+				for k, v := range row {
+					dot += v * q[k]
+				}
+				scores[i] = dot
+			}
+		}(from, to)
+	}
+	wg.Wait()
+	return scores
+}
+
+// centroid 笔记所有段落向量的平均，作为整篇笔记的向量
+func (e *noteEntry) centroid() []float32 {
+	if len(e.Chunks) == 0 {
+		return nil
+	}
+	sum := make([]float32, len(e.Chunks[0].Vec))
+	for _, c := range e.Chunks {
+		if len(c.Vec) != len(sum) {
+			continue
+		}
+		for k, v := range c.Vec {
+			sum[k] += v
+		}
+	}
+	return normalize(sum)
+}
+
+// normalize 归一化为单位向量，零向量原样返回
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	out := make([]float32, len(v))
+	if sum == 0 {
+		copy(out, v)
+		return out
+	}
+	inv := float32(1 / math.Sqrt(sum))
+	for i, x := range v {
+		out[i] = x * inv
+	}
+	return out
+}
+
+var flatten = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ", "\t", " ")
+
+// snippet 段落开头，换行压缩为空格
+func snippet(text string) string {
+	text = flatten.Replace(text)
+	if utf8.RuneCountInString(text) <= snippetRunes {
+		return text
+	}
+	i := 0
+	for n := 0; n < snippetRunes; n++ {
+		_, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+	}
+	return text[:i] + "…"
+}
@@ -0,0 +1,86 @@
+package semantic
+
+import (
+	"fmt"
+	"testing"
+)
+
+// entry 只有一段的笔记
+func entry(folder, title string, tags []string, vec ...float32) *noteEntry {
+	return &noteEntry{Title: title, Folder: folder, Tags: tags, Chunks: []chunkVec{{Text: title, Vec: normalize(vec)}}}
+}
+
+func TestRebuildMajorityDims(t *testing.T) {
+	// 维度不同的残留向量不论出现在遍历顺序的哪个位置，都不能决定矩阵的维度
+	for round := 0; round < 20; round++ {
+		ix := NewIndex()
+		for i := 0; i < 5; i++ {
+			ix.put(entry("", fmt.Sprintf("new%d", i), nil, 1, float32(i), 0))
+		}
+		ix.put(entry("", "stale", nil, 1, 0))
+		hits := ix.Search([]float32{1, 0, 0}, Options{})
+		if len(hits) != 5 {
+			t.Fatalf("第 %d 轮: 得到 %d 条结果，期望 5 条 (维度 %d)", round, len(hits), ix.dims)
+		}
+		if ix.dims != 3 {
+			t.Fatalf("第 %d 轮: 维度 %d，期望 3", round, ix.dims)
+		}
+	}
+}
+
+func TestMajorityDims(t *testing.T) {
+	tests := []struct {
+		counts map[int]int
+		want   int
+	}{
+		{map[int]int{}, 0},
+		{map[int]int{768: 3}, 768},
+		{map[int]int{768: 1, 1536: 5}, 1536},
+		{map[int]int{768: 5, 1536: 1}, 768},
+		{map[int]int{768: 2, 1536: 2}, 1536},
+	}
+	for _, tt := range tests {
+		if got := majorityDims(tt.counts); got != tt.want {
+			t.Errorf("majorityDims(%v) = %d，期望 %d", tt.counts, got, tt.want)
+		}
+	}
+}
+
+func TestIndexSearch(t *testing.T) {
+	ix := NewIndex()
+	ix.put(entry("Work", "docker", []string{"ops"}, 1, 0, 0))
+	ix.put(entry("Work/Sub", "k8s", []string{"ops/k8s"}, 0.9, 0.1, 0))
+	ix.put(entry("Life", "cooking", nil, 0, 0, 1))
+
+	titles := func(hits []Hit) string {
+		var out []string
+		for _, h := range hits {
+			out = append(out, h.Title)
+		}
+		return fmt.Sprint(out)
+	}
+	tests := []struct {
+		name  string
+		query []float32
+		opts  Options
+		want  string
+	}{
+		{"按相似度排序", []float32{1, 0, 0}, Options{}, "[docker k8s cooking]"},
+		{"数量限制", []float32{1, 0, 0}, Options{Limit: 1}, "[docker]"},
+		{"文件夹含子文件夹", []float32{0, 1, 0}, Options{Folder: "Work"}, "[k8s docker]"},
+		{"下级标签", []float32{1, 0, 0}, Options{Tag: "ops"}, "[docker k8s]"},
+		{"排除自己", []float32{1, 0, 0}, Options{ExcludeFolder: "Work", ExcludeTitle: "docker"}, "[k8s cooking]"},
+		{"维度不符", []float32{1, 0}, Options{}, "[]"},
+	}
+	for _, tt := range tests {
+		if got := titles(ix.Search(tt.query, tt.opts)); got != tt.want {
+			t.Errorf("%s: 得到 %s，期望 %s", tt.name, got, tt.want)
+		}
+	}
+
+	// 删除后矩阵重建
+	ix.RemoveFolder("Work")
+	if got := titles(ix.Search([]float32{1, 0, 0}, Options{})); got != "[cooking]" {
+		t.Errorf("删除文件夹后得到 %s", got)
+	}
+}
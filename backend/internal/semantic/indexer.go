@@ -0,0 +1,398 @@
+package semantic
+
+import (
+	"ai-notes/internal/dao"
+	"context"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	saveInterval = 30 * time.Second // 有改动时定期写盘的间隔
+	embedBatch   = 64               // 每次请求 embeddings 接口的最大段数
+	noteBatch    = 16               // worker 每轮处理的笔记数
+	maxBackoff   = 5 * time.Minute  // 接口连续失败时的最长重试间隔
+)
+
+// ErrNotIndexed 笔记还没有生成向量 (刚创建或正在排队)
+var ErrNotIndexed = errors.New("笔记的向量尚未生成，请稍后再试")
+
+// Indexer 维护笔记的向量索引
+//   - 订阅存储后端的变更事件，在后台按标题切分笔记并调用 embeddings 接口
+//   - 笔记内容的哈希没变时不重新生成；内容变了只为变化的段落生成，其余段落复用已有向量
+//   - 向量持久化到磁盘，重启后只需比对哈希，不会重复请求接口
+type Indexer struct {
+	store    dao.NoteStore
+	embedder Embedder
+	path     string // 为空时不持久化
+	index    *Index
+
+	mu          sync.Mutex
+	dirty       bool
+	lastSaved   time.Time
+	lastError   string
+	lastErrorAt *time.Time
+	embedded    int // 启动以来通过接口生成的段数
+
+	// 待处理的笔记，同一篇笔记只保留最新的事件
+	qmu     sync.Mutex
+	pending map[[2]string]dao.NoteEvent
+	order   [][2]string
+	busy    int
+	signal  chan struct{}
+	// removals 删除事件的计数，process 据此发现生成向量期间被删除的笔记，避免把它们放回索引
+	removals uint64
+}
+
+// Stats 向量索引统计信息
+type Stats struct {
+	Model       string     `json:"model"`
+	Notes       int        `json:"notes"`
+	Chunks      int        `json:"chunks"`
+	Pending     int        `json:"pending"` // 等待生成向量的笔记
+	Embedded    int        `json:"embedded"`
+	Path        string     `json:"path"`
+	FileSize    int64      `json:"file_size"`
+	LastSaved   time.Time  `json:"last_saved"`
+	Dirty       bool       `json:"dirty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// NewIndexer 加载向量文件，并在后台补齐新增 / 修改过的笔记
+func NewIndexer(store dao.NoteStore, embedder Embedder, path string) *Indexer {
+	x := &Indexer{
+		store:    store,
+		embedder: embedder,
+		path:     path,
+		pending:  make(map[[2]string]dao.NoteEvent),
+		signal:   make(chan struct{}, 1),
+	}
+	if path != "" {
+		ix, err := LoadIndex(path, embedder.Model())
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Println("加载向量文件失败，重新生成:", err)
+		}
+		x.index = ix
+	}
+	if x.index == nil {
+		x.index = NewIndex()
+	}
+
+	if obs, ok := store.(dao.Observable); ok {
+		obs.Subscribe(x.enqueue)
+	} else {
+		log.Println("当前存储后端不支持变更通知，向量索引只在启动时更新")
+	}
+
+	go x.worker()
+	go x.sync()
+	if path != "" {
+		go x.saver()
+	}
+	return x
+}
+
+// Model 生成向量使用的模型
+func (x *Indexer) Model() string {
+	return x.embedder.Model()
+}
+
+// Search 按语义检索：为查询文本生成向量，返回最相似的笔记
+func (x *Indexer) Search(ctx context.Context, text string, opts Options) ([]Hit, error) {
+	vecs, err := x.embedder.Embed(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return x.index.Search(vecs[0], opts), nil
+}
+
+// Related 与指定笔记内容相近的笔记；id 不为 0 时按 ID 查找，否则按 (文件夹, 标题)
+func (x *Indexer) Related(id uint, folder, title string, opts Options) ([]Hit, error) {
+	var e *noteEntry
+	if id != 0 {
+		e = x.index.byID(id)
+	} else {
+		e = x.index.get(folder, title)
+	}
+	if e == nil || len(e.Chunks) == 0 {
+		return nil, ErrNotIndexed
+	}
+	opts.ExcludeFolder, opts.ExcludeTitle = e.Folder, e.Title
+	return x.index.Search(e.centroid(), opts), nil
+}
+
+// Stats 向量索引统计信息
+func (x *Indexer) Stats() Stats {
+	x.mu.Lock()
+	st := Stats{
+		Model:       x.embedder.Model(),
+		Path:        x.path,
+		Embedded:    x.embedded,
+		LastSaved:   x.lastSaved,
+		Dirty:       x.dirty,
+		LastError:   x.lastError,
+		LastErrorAt: x.lastErrorAt,
+	}
+	x.mu.Unlock()
+
+	st.Notes, st.Chunks = x.index.Counts()
+	x.qmu.Lock()
+	st.Pending = len(x.order) + x.busy
+	x.qmu.Unlock()
+	if x.path != "" {
+		if info, err := os.Stat(x.path); err == nil {
+			st.FileSize = info.Size()
+		}
+	}
+	return st
+}
+
+// sync 启动时与存储对齐：移除已不存在的笔记，其余全部入队 (内容没变的只比对哈希)
+func (x *Indexer) sync() {
+	notes, err := x.store.ListNotes()
+	if err != nil {
+		log.Println("读取笔记列表失败，向量索引暂不更新:", err)
+		return
+	}
+	live := make(map[[2]string]bool, len(notes))
+	for _, n := range notes {
+		live[[2]string{n.Folder, n.Title}] = true
+	}
+	for _, k := range x.index.keys() {
+		if !live[k] {
+			x.index.Remove(k[0], k[1])
+			x.markDirty()
+		}
+	}
+	for _, n := range notes {
+		x.enqueue(dao.NoteEvent{Title: n.Title, Folder: n.Folder, Op: "write", ID: n.ID, UpdatedAt: n.UpdatedAt})
+	}
+}
+
+// enqueue 存储层的变更回调：删除直接生效，写入排队等待生成向量
+func (x *Indexer) enqueue(ev dao.NoteEvent) {
+	switch ev.Op {
+	case "remove":
+		x.qmu.Lock()
+		x.removals++
+		x.index.Remove(ev.Folder, ev.Title)
+		x.qmu.Unlock()
+		x.markDirty()
+		return
+	case "remove_folder":
+		if ev.Folder != "" {
+			x.qmu.Lock()
+			x.removals++
+			x.index.RemoveFolder(ev.Folder)
+			x.qmu.Unlock()
+			x.markDirty()
+		}
+		return
+	}
+
+	key := [2]string{ev.Folder, ev.Title}
+	x.qmu.Lock()
+	if _, ok := x.pending[key]; !ok {
+		x.order = append(x.order, key)
+	}
+	x.pending[key] = ev
+	x.qmu.Unlock()
+	select {
+	case x.signal <- struct{}{}:
+	default:
+	}
+}
+
+// requeue 处理失败的笔记放回队列；期间又有新事件时以新事件为准
+func (x *Indexer) requeue(events []dao.NoteEvent) {
+	x.qmu.Lock()
+	defer x.qmu.Unlock()
+	for _, ev := range events {
+		key := [2]string{ev.Folder, ev.Title}
+		if _, ok := x.pending[key]; ok {
+			continue
+		}
+		x.pending[key] = ev
+		x.order = append(x.order, key)
+	}
+}
+
+// take 取出最多 n 个待处理的事件
+func (x *Indexer) take(n int) []dao.NoteEvent {
+	x.qmu.Lock()
+	defer x.qmu.Unlock()
+	n = min(n, len(x.order))
+	events := make([]dao.NoteEvent, 0, n)
+	for _, key := range x.order[:n] {
+		events = append(events, x.pending[key])
+		delete(x.pending, key)
+	}
+	x.order = x.order[n:]
+	x.busy = len(events)
+	return events
+}
+
+func (x *Indexer) worker() {
+	var backoff time.Duration
+	for range x.signal {
+		for {
+			events := x.take(noteBatch)
+			if len(events) == 0 {
+				break
+			}
+			err := x.process(events)
+			x.qmu.Lock()
+			x.busy = 0
+			x.qmu.Unlock()
+			if err == nil {
+				backoff = 0
+				continue
+			}
+
+			x.requeue(events)
+			backoff = min(max(2*backoff, 5*time.Second), maxBackoff)
+			log.Printf("生成向量失败，%v 后重试: %v", backoff, err)
+			x.mu.Lock()
+			now := time.Now()
+			x.lastError, x.lastErrorAt = err.Error(), &now
+			x.mu.Unlock()
+			time.Sleep(backoff)
+		}
+	}
+}
+
+// pendingChunk 需要请求接口生成向量的段落
+type pendingChunk struct {
+	entry *noteEntry
+	chunk int
+	text  string
+}
+
+// process 为一批笔记生成向量；任何一次接口调用失败时整批都不写入索引
+func (x *Indexer) process(events []dao.NoteEvent) error {
+	x.qmu.Lock()
+	seen := x.removals
+	x.qmu.Unlock()
+
+	var entries []*noteEntry
+	var todo []pendingChunk
+	for _, ev := range events {
+		content, err := x.store.GetNote(ev.Title, ev.Folder)
+		if err != nil {
+			x.index.Remove(ev.Folder, ev.Title)
+			x.markDirty()
+			continue
+		}
+		old := x.index.get(ev.Folder, ev.Title)
+		hash := hashText(ev.Title + "\x00" + content)
+		if old != nil && old.Hash == hash {
+			if old.ID != ev.ID || !old.UpdatedAt.Equal(ev.UpdatedAt) {
+				e := *old
+				e.ID, e.UpdatedAt = ev.ID, ev.UpdatedAt
+				entries = append(entries, &e)
+			}
+			continue
+		}
+
+		e := &noteEntry{
+			ID:        ev.ID,
+			Title:     ev.Title,
+			Folder:    ev.Folder,
+			Tags:      dao.ExtractTags(content),
+			UpdatedAt: ev.UpdatedAt,
+			Hash:      hash,
+		}
+		for i, c := range SplitChunks(content) {
+			text := c.embedText(ev.Title)
+			cv := chunkVec{Heading: c.Heading, Text: c.Text, Hash: hashText(text)}
+			cv.Vec = x.index.reusable(old, cv.Hash)
+			e.Chunks = append(e.Chunks, cv)
+			if cv.Vec == nil {
+				todo = append(todo, pendingChunk{entry: e, chunk: i, text: text})
+			}
+		}
+		entries = append(entries, e)
+	}
+
+	for from := 0; from < len(todo); from += embedBatch {
+		batch := todo[from:min(from+embedBatch, len(todo))]
+		texts := make([]string, len(batch))
+		for i, p := range batch {
+			texts[i] = p.text
+		}
+		vecs, err := x.embedder.Embed(context.Background(), texts)
+		if err != nil {
+			return err
+		}
+		for i, p := range batch {
+			p.entry.Chunks[p.chunk].Vec = normalize(vecs[i])
+		}
+	}
+
+	// 期间有笔记被删除时，去掉已不存在的笔记再写入；检查与写入之间又有删除时重新检查
+	for {
+		x.qmu.Lock()
+		if x.removals == seen {
+			for _, e := range entries {
+				x.index.put(e)
+			}
+			x.qmu.Unlock()
+			break
+		}
+		seen = x.removals
+		x.qmu.Unlock()
+		entries = x.existing(entries)
+	}
+	if len(entries) > 0 {
+		x.mu.Lock()
+		x.dirty = true
+		x.embedded += len(todo)
+		x.mu.Unlock()
+	}
+	return nil
+}
+
+// existing 过滤掉存储中已不存在的笔记
+func (x *Indexer) existing(entries []*noteEntry) []*noteEntry {
+	kept := entries[:0]
+	for _, e := range entries {
+		if _, err := x.store.GetNote(e.Title, e.Folder); err == nil {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+func (x *Indexer) markDirty() {
+	x.mu.Lock()
+	x.dirty = true
+	x.mu.Unlock()
+}
+
+// saver 定期把有改动的索引写盘
+func (x *Indexer) saver() {
+	ticker := time.NewTicker(saveInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		x.mu.Lock()
+		dirty := x.dirty
+		x.dirty = false
+		x.mu.Unlock()
+		if !dirty {
+			continue
+		}
+		if err := x.index.Save(x.path, x.embedder.Model()); err != nil {
+			log.Println("保存向量文件失败:", err)
+			x.markDirty()
+			continue
+		}
+		x.mu.Lock()
+		x.lastSaved = time.Now()
+		x.mu.Unlock()
+	}
+}
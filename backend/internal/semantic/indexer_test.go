@@ -0,0 +1,211 @@
+package semantic
+
+import (
+	"ai-notes/internal/ai"
+	"ai-notes/internal/dao/daotest"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 假 embeddings 服务的词表：向量的每一维是对应词出现的次数，最后一维恒为 1 (避免零向量)
+var fakeVocabulary = []string{"docker", "kubernetes", "cooking", "recipe"}
+
+// newFakeEmbedder 启动兼容 OpenAI /embeddings 的假服务，结果只取决于输入文本；inputs 统计请求过的文本数
+func newFakeEmbedder(t *testing.T) (ai.Embedder, *atomic.Int64) {
+	t.Helper()
+	var inputs atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/embeddings") {
+			http.NotFound(w, r)
+			return
+		}
+		var req struct {
+			Input []string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		inputs.Add(int64(len(req.Input)))
+		type item struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		}
+		data := make([]item, len(req.Input))
+		for i, text := range req.Input {
+			text = strings.ToLower(text)
+			vec := make([]float32, len(fakeVocabulary)+1)
+			for d, word := range fakeVocabulary {
+				vec[d] = float32(strings.Count(text, word))
+			}
+			vec[len(fakeVocabulary)] = 1
+			data[i] = item{Index: i, Embedding: vec}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	t.Cleanup(srv.Close)
+
+	p, err := ai.New(ai.Config{Provider: "openai", BaseURL: srv.URL, APIKey: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ai.Embedder{Provider: p, Name: "fake-embedding"}, &inputs
+}
+
+// waitIndexed 等待后台 worker 处理完队列，并且索引中恰好有 notes 篇笔记
+func waitIndexed(t *testing.T, x *Indexer, notes int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if st := x.Stats(); st.Pending == 0 && st.Notes == notes {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("等待索引超时: %+v", x.Stats())
+}
+
+func hitTitles(hits []Hit) []string {
+	titles := make([]string, len(hits))
+	for i, h := range hits {
+		titles[i] = h.Title
+	}
+	return titles
+}
+
+func TestIndexer(t *testing.T) {
+	store := daotest.New(t)
+	notes := []struct{ folder, title, content string }{
+		{"Work", "docker", "# Docker\ndocker docker compose\n\n# Deploy\nkubernetes\n"},
+		{"Work", "k8s", "kubernetes kubernetes docker\n"},
+		{"Life", "cooking", "cooking recipe #food\n"},
+	}
+	for _, n := range notes {
+		if err := store.SaveNote(n.title, n.folder, n.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	embedder, inputs := newFakeEmbedder(t)
+	x := NewIndexer(store, embedder, "")
+	waitIndexed(t, x, 3)
+	if got := inputs.Load(); got != 4 {
+		t.Fatalf("首次生成请求了 %d 段，期望 4 段", got)
+	}
+
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		text string
+		opts Options
+		want string
+	}{
+		{"最相似的在前", "kubernetes", Options{}, "[k8s docker cooking]"},
+		{"数量限制", "recipe", Options{Limit: 1}, "[cooking]"},
+		{"文件夹", "recipe", Options{Folder: "Work"}, "[docker k8s]"},
+		{"标签", "docker", Options{Tag: "food"}, "[cooking]"},
+	}
+	for _, tt := range tests {
+		hits, err := x.Search(ctx, tt.text, tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprint(hitTitles(hits)); got != tt.want {
+			t.Errorf("%s: 得到 %s，期望 %s", tt.name, got, tt.want)
+		}
+	}
+
+	// 相关笔记不包含自己，按 ID 和按标题查找结果相同
+	byTitle, err := x.Related(0, "Work", "docker", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hitTitles(byTitle); len(got) != 2 || got[0] != "k8s" {
+		t.Errorf("相关笔记 %v，期望 [k8s cooking]", got)
+	}
+	note, err := store.GetNoteByID(byTitle[0].ID)
+	if err != nil || note.Title != "k8s" {
+		t.Fatalf("结果应带上笔记 ID，得到 %v %v", note, err)
+	}
+	byID, err := x.Related(note.ID, "", "", Options{})
+	if err != nil || len(byID) != 2 || byID[0].Title != "docker" {
+		t.Errorf("按 ID 查找相关笔记 %v %v", hitTitles(byID), err)
+	}
+	if _, err := x.Related(0, "Work", "missing", Options{}); err != ErrNotIndexed {
+		t.Errorf("未索引的笔记应返回 ErrNotIndexed，得到 %v", err)
+	}
+
+	// 只改了一段时，其余段落复用已有向量
+	before := inputs.Load()
+	if err := store.SaveNote("docker", "Work", "# Docker\ndocker docker compose\n\n# Deploy\nkubernetes helm\n"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for inputs.Load() == before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	waitIndexed(t, x, 3)
+	if got := inputs.Load() - before; got != 1 {
+		t.Errorf("修改一段后请求了 %d 段，期望 1 段", got)
+	}
+
+	// 删除立即生效
+	if err := store.DeleteNote("k8s", "Work"); err != nil {
+		t.Fatal(err)
+	}
+	waitIndexed(t, x, 2)
+	hits, err := x.Search(ctx, "kubernetes", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hitTitles(hits); len(got) != 2 || got[0] != "docker" {
+		t.Errorf("删除后得到 %v", got)
+	}
+}
+
+// blockingEmbedder 每次请求先通知 started，等 release 关闭后才返回
+type blockingEmbedder struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (e *blockingEmbedder) Model() string { return "blocking" }
+
+func (e *blockingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.started <- struct{}{}
+	<-e.release
+	vecs := make([][]float32, len(texts))
+	for i := range vecs {
+		vecs[i] = []float32{1, 0}
+	}
+	return vecs, nil
+}
+
+func TestIndexerRemoveDuringEmbed(t *testing.T) {
+	store := daotest.New(t)
+	for _, title := range []string{"a", "b"} {
+		if err := store.SaveNote(title, "", title); err != nil {
+			t.Fatal(err)
+		}
+	}
+	embedder := &blockingEmbedder{started: make(chan struct{}, 1), release: make(chan struct{})}
+	x := NewIndexer(store, embedder, "")
+
+	// 生成向量期间删除笔记，结果写入索引时不能把它放回来
+	<-embedder.started
+	if err := store.DeleteNote("a", ""); err != nil {
+		t.Fatal(err)
+	}
+	close(embedder.release)
+	waitIndexed(t, x, 1)
+	if x.index.get("", "a") != nil {
+		t.Error("已删除的笔记回到了索引中")
+	}
+}
@@ -0,0 +1,68 @@
+package semantic
+
+import (
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// indexFormat 向量文件格式版本；切分规则或结构变化时加一，旧文件会被丢弃
+const indexFormat = 1
+
+// snapshot 向量文件内容
+type snapshot struct {
+	Format int
+	Model  string // 生成向量的模型，与当前配置不同时丢弃
+	Notes  map[string]*noteEntry
+}
+
+// Save 把索引写入文件 (先写临时文件再 rename)
+func (ix *Index) Save(path, model string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-embeddings-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // rename 成功后这里是 no-op
+
+	ix.mu.RLock()
+	err = gob.NewEncoder(tmp).Encode(&snapshot{Format: indexFormat, Model: model, Notes: ix.notes})
+	ix.mu.RUnlock()
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadIndex 从文件读取索引；格式或模型不一致时返回错误
+func LoadIndex(path, model string) (*Index, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var snap snapshot
+	if err := gob.NewDecoder(f).Decode(&snap); err != nil {
+		return nil, err
+	}
+	if snap.Format != indexFormat {
+		return nil, fmt.Errorf("向量文件格式版本 %d 与当前版本 %d 不一致", snap.Format, indexFormat)
+	}
+	if snap.Model != model {
+		return nil, fmt.Errorf("向量文件由模型 %s 生成，当前模型为 %s", snap.Model, model)
+	}
+	ix := NewIndex()
+	if snap.Notes != nil {
+		ix.notes = snap.Notes
+	}
+	ix.stale = true
+	return ix, nil
+}
@@ -0,0 +1,227 @@
+// Package usage AI 用量：记录每次调用，按天 / 按月汇总，以及每日 token 预算和按客户端的请求频率限制
+package usage
+
+import (
+	"ai-notes/internal/dao"
+	"ai-notes/internal/model"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrNoStore 存储后端不支持用量记录，无法汇总历史用量
+var ErrNoStore = errors.New("当前存储后端不记录 AI 用量")
+
+// embedAction 生成向量的记录：计入统计，但不占用每日预算 (后台建索引不应让对话功能不可用)
+const embedAction = "embed"
+
+// 客户端数超过该值时清理已经回满的限流记录
+const maxClients = 10000
+
+// LimitError 超出每日预算或请求频率限制
+type LimitError struct {
+	Message    string
+	RetryAfter time.Duration // 建议的重试间隔，0 表示今天内不必重试
+}
+
+func (e *LimitError) Error() string { return e.Message }
+
+// Meter 用量计量器，并发安全
+type Meter struct {
+	store  dao.UsageStore // 为 nil 时不持久化，只在内存中统计当天用量
+	budget int64          // 每日 token 预算 (输入 + 输出)，0 表示不限
+	rate   int            // 每个客户端每分钟最多请求数，0 表示不限
+
+	mu        sync.Mutex
+	day       string // 当天日期，跨天时清零 dayTokens
+	dayTokens int64
+	clients   map[string]*bucket
+}
+
+// bucket 令牌桶：容量 rate，每分钟回满
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewMeter 创建计量器；存储后端支持 UsageStore 时持久化记录，并从中恢复当天已用的 token
+func NewMeter(s dao.NoteStore, budget int64, perMinute int) *Meter {
+	m := &Meter{budget: budget, rate: perMinute, clients: map[string]*bucket{}}
+	us, ok := s.(dao.UsageStore)
+	if !ok {
+		return m
+	}
+	m.store = us
+	now := time.Now()
+	m.day = now.Format("2006-01-02")
+	totals, err := us.SumUsage([]time.Time{startOfDay(now)})
+	if err != nil {
+		log.Println("读取今日 AI 用量失败:", err)
+		return m
+	}
+	for _, t := range totals {
+		if t.Action != embedAction {
+			m.dayTokens += t.InputTokens + t.OutputTokens
+		}
+	}
+	return m
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, mo, d := t.Date()
+	return time.Date(y, mo, d, 0, 0, 0, 0, t.Location())
+}
+
+// rollover 跨天时清零当天用量，调用时需持有 mu
+func (m *Meter) rollover(now time.Time) {
+	if day := now.Format("2006-01-02"); day != m.day {
+		m.day, m.dayTokens = day, 0
+	}
+}
+
+// Record 记录一次调用 (作为 ai.Metered 的回调)
+func (m *Meter) Record(rec model.AIUsage) {
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = time.Now()
+	}
+	if rec.Action != embedAction {
+		m.mu.Lock()
+		m.rollover(rec.CreatedAt)
+		m.dayTokens += int64(rec.InputTokens + rec.OutputTokens)
+		m.mu.Unlock()
+	}
+	if m.store != nil {
+		if err := m.store.RecordUsage(&rec); err != nil {
+			log.Println("记录 AI 用量失败:", err)
+		}
+	}
+}
+
+// Allow 检查客户端现在能否发起一次调用，不能时返回原因
+func (m *Meter) Allow(client string) *LimitError {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.overBudget(now); err != nil {
+		return err
+	}
+	if m.rate <= 0 {
+		return nil
+	}
+
+	perSecond := float64(m.rate) / 60
+	b, ok := m.clients[client]
+	if !ok {
+		if len(m.clients) >= maxClients {
+			m.prune(now, perSecond)
+		}
+		b = &bucket{tokens: float64(m.rate), last: now}
+		m.clients[client] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * perSecond
+	if b.tokens > float64(m.rate) {
+		b.tokens = float64(m.rate)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+		return &LimitError{
+			Message:    fmt.Sprintf("AI 请求过于频繁 (每分钟最多 %d 次)，请 %d 秒后再试", m.rate, int(wait.Seconds())+1),
+			RetryAfter: wait,
+		}
+	}
+	b.tokens--
+	return nil
+}
+
+// OverBudget 今日预算已用完时返回原因 (后台任务只检查预算，不受请求频率限制)
+func (m *Meter) OverBudget() *LimitError {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.overBudget(time.Now())
+}
+
+// overBudget 调用时需持有 mu
+func (m *Meter) overBudget(now time.Time) *LimitError {
+	m.rollover(now)
+	if m.budget > 0 && m.dayTokens >= m.budget {
+		return &LimitError{Message: fmt.Sprintf("今日 AI 用量已达上限 (%d / %d tokens)，请明天再试或调高 AI_DAILY_TOKEN_BUDGET", m.dayTokens, m.budget)}
+	}
+	return nil
+}
+
+// prune 删除已经回满的令牌桶 (这些客户端重新出现时等价于新客户端)，调用时需持有 mu
+func (m *Meter) prune(now time.Time, perSecond float64) {
+	for client, b := range m.clients {
+		if b.tokens+now.Sub(b.last).Seconds()*perSecond >= float64(m.rate) {
+			delete(m.clients, client)
+		}
+	}
+}
+
+// Today 当天已用的 token 数和每日预算 (0 表示不限)
+func (m *Meter) Today() (used, budget int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rollover(time.Now())
+	return m.dayTokens, m.budget
+}
+
+// Totals 一组调用的合计
+type Totals struct {
+	Requests     int   `json:"requests"`
+	Errors       int   `json:"errors"` // 失败 (不含取消) 的次数
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+}
+
+func (t *Totals) add(u model.UsageTotal) {
+	t.Requests += u.Requests
+	t.Errors += u.Errors
+	t.InputTokens += u.InputTokens
+	t.OutputTokens += u.OutputTokens
+}
+
+// Bucket 一天或一个月的用量
+type Bucket struct {
+	Period string `json:"period"` // "2026-01-02" 或 "2026-01"
+	Totals
+	Actions map[string]*Totals `json:"actions"` // 按动作 (polish / ask / embed ...) 细分
+}
+
+// Summary 按天 ("day") 或按月 ("month") 汇总 from 之后的用量，按时间先后排序，没有调用的时间段不返回
+// from 须为某天 / 某月的开始，汇总在数据库中完成
+func (m *Meter) Summary(period string, from time.Time) ([]Bucket, error) {
+	if m.store == nil {
+		return nil, ErrNoStore
+	}
+	layout, step := "2006-01-02", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+	if period == "month" {
+		layout, step = "2006-01", func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }
+	}
+	var bounds []time.Time
+	for t, now := from, time.Now(); !t.After(now); t = step(t) {
+		bounds = append(bounds, t)
+	}
+	totals, err := m.store.SumUsage(bounds)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := []Bucket{}
+	for _, t := range totals { // 已按时间段排序
+		if n := len(buckets); n == 0 || buckets[n-1].Period != bounds[t.Bucket].Format(layout) {
+			buckets = append(buckets, Bucket{Period: bounds[t.Bucket].Format(layout), Actions: map[string]*Totals{}})
+		}
+		b := &buckets[len(buckets)-1]
+		b.add(t)
+		if b.Actions[t.Action] == nil {
+			b.Actions[t.Action] = &Totals{}
+		}
+		b.Actions[t.Action].add(t)
+	}
+	return buckets, nil
+}
@@ -0,0 +1,139 @@
+package usage
+
+import (
+	"ai-notes/internal/dao/daotest"
+	"ai-notes/internal/model"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMeterBudget(t *testing.T) {
+	s := daotest.New(t)
+	now := time.Now()
+	for _, u := range []model.AIUsage{
+		{CreatedAt: now, Action: "polish", InputTokens: 30, OutputTokens: 20},
+		{CreatedAt: now, Action: embedAction, InputTokens: 1000},             // 生成向量不占预算
+		{CreatedAt: now.AddDate(0, 0, -1), Action: "ask", InputTokens: 1000}, // 昨天的不算
+	} {
+		if err := s.RecordUsage(&u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 重启后从数据库恢复当天用量
+	m := NewMeter(s, 100, 0)
+	if used, budget := m.Today(); used != 50 || budget != 100 {
+		t.Fatalf("Today() = %d, %d，期望 50, 100", used, budget)
+	}
+	if err := m.Allow("c"); err != nil {
+		t.Fatalf("预算未用完时不应限制: %v", err)
+	}
+	m.Record(model.AIUsage{Action: "ask", InputTokens: 40, OutputTokens: 10})
+	if err := m.Allow("c"); err == nil || err.RetryAfter != 0 {
+		t.Fatalf("预算用完后应拒绝且不建议重试，得到 %v", err)
+	}
+	if m.OverBudget() == nil {
+		t.Error("OverBudget 应返回预算错误")
+	}
+	m.Record(model.AIUsage{Action: embedAction, InputTokens: 10})
+	if used, _ := m.Today(); used != 100 {
+		t.Errorf("生成向量不应计入预算，已用 %d", used)
+	}
+
+	// 跨天后清零
+	m.mu.Lock()
+	m.day = "2000-01-01"
+	m.mu.Unlock()
+	if err := m.OverBudget(); err != nil {
+		t.Errorf("跨天后应清零，得到 %v", err)
+	}
+
+	if unlimited := NewMeter(nil, 0, 0); unlimited.OverBudget() != nil || unlimited.Allow("c") != nil {
+		t.Error("预算为 0 时不限")
+	}
+}
+
+func TestMeterRateLimit(t *testing.T) {
+	m := NewMeter(nil, 0, 2)
+	for i := 0; i < 2; i++ {
+		if err := m.Allow("a"); err != nil {
+			t.Fatalf("第 %d 次请求不应限流: %v", i+1, err)
+		}
+	}
+	err := m.Allow("a")
+	if err == nil || err.RetryAfter <= 0 || err.RetryAfter > 30*time.Second {
+		t.Fatalf("超出频率应限流并给出重试间隔，得到 %+v", err)
+	}
+	if err := m.Allow("b"); err != nil {
+		t.Errorf("其他客户端不受影响: %v", err)
+	}
+
+	// 令牌按时间回补：每分钟 2 个，30 秒回补 1 个
+	m.mu.Lock()
+	m.clients["a"].last = m.clients["a"].last.Add(-30 * time.Second)
+	m.mu.Unlock()
+	if err := m.Allow("a"); err != nil {
+		t.Errorf("30 秒后应回补一次，得到 %v", err)
+	}
+	if err := m.Allow("a"); err == nil {
+		t.Error("回补的令牌不应超过经过的时间")
+	}
+}
+
+func TestMeterSummary(t *testing.T) {
+	if _, err := NewMeter(nil, 0, 0).Summary("day", time.Now()); !errors.Is(err, ErrNoStore) {
+		t.Fatalf("没有存储时应返回 ErrNoStore，得到 %v", err)
+	}
+
+	s := daotest.New(t)
+	today := startOfDay(time.Now())
+	for _, u := range []model.AIUsage{
+		{CreatedAt: today.Add(time.Minute), Action: "polish", InputTokens: 10, OutputTokens: 5, Status: "ok"},
+		{CreatedAt: today.Add(time.Hour), Action: "polish", InputTokens: 20, OutputTokens: 5, Status: "error"},
+		{CreatedAt: today.Add(2 * time.Hour), Action: "ask", InputTokens: 7, OutputTokens: 3, Status: "cancelled"},
+		{CreatedAt: today.Add(-time.Minute), Action: "ask", InputTokens: 1, OutputTokens: 1, Status: "ok"}, // 昨天
+		{CreatedAt: today.AddDate(0, 0, -10), Action: "ask", InputTokens: 100, Status: "ok"},               // 范围外
+	} {
+		if err := s.RecordUsage(&u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := NewMeter(s, 0, 0)
+
+	buckets, err := m.Summary("day", today.AddDate(0, 0, -2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != 2 {
+		t.Fatalf("得到 %d 个时间段 %+v，期望 2 个", len(buckets), buckets)
+	}
+	yesterday, last := buckets[0], buckets[1]
+	if yesterday.Period != today.AddDate(0, 0, -1).Format("2006-01-02") || yesterday.Requests != 1 {
+		t.Errorf("昨天: %+v", yesterday)
+	}
+	want := Totals{Requests: 3, Errors: 1, InputTokens: 37, OutputTokens: 13}
+	if last.Period != today.Format("2006-01-02") || last.Totals != want {
+		t.Errorf("今天: %s %+v，期望 %+v", last.Period, last.Totals, want)
+	}
+	if p := last.Actions["polish"]; p == nil || *p != (Totals{Requests: 2, Errors: 1, InputTokens: 30, OutputTokens: 10}) {
+		t.Errorf("polish: %+v", p)
+	}
+
+	// 按月：当月之前的记录都不在范围内
+	thisMonth := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())
+	buckets, err = m.Summary("month", thisMonth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total int
+	for _, b := range buckets {
+		total += b.Requests
+	}
+	if len(buckets) != 1 || buckets[0].Period != thisMonth.Format("2006-01") {
+		t.Errorf("按月: %+v", buckets)
+	}
+	if total < 3 {
+		t.Errorf("当月至少有今天的 3 次调用，得到 %d", total)
+	}
+}
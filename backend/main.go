@@ -1,29 +1,79 @@
 package main
 
 import (
+	"ai-notes/internal/ai"
 	"ai-notes/internal/dao"
+	"ai-notes/internal/jobs"
 	"ai-notes/internal/router"
+	"ai-notes/internal/search"
+	"ai-notes/internal/semantic"
+	"ai-notes/internal/usage"
 	"embed"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 //go:embed static/*
 var staticFiles embed.FS
 
 func main() {
-	// 1. 初始化存储层 (MySQL DAO)
-	dbUser := getEnv("DB_USER", "root")
-	dbPwd := getEnv("DB_PASSWORD", "rootpassword")
-	dbHost := getEnv("DB_HOST", "mysql")
-	dbPort := getEnv("DB_PORT", "3306")
-	dbName := getEnv("DB_NAME", "notes_db")
+	// 1. 初始化存储层 (根据 DB_DRIVER 选择后端)
+	s := newStore()
+	if rs, ok := s.(dao.RevisionStore); ok {
+		rs.SetRevisionPolicy(dao.RevisionPolicy{
+			CoalesceWindow: time.Duration(getEnvInt("REVISION_COALESCE_SECONDS", 300)) * time.Second,
+			KeepLast:       getEnvInt("REVISION_KEEP_LAST", 50),
+			KeepDays:       getEnvInt("REVISION_KEEP_DAYS", 30),
+		})
+	}
+
+	if ts, ok := s.(dao.TrashStore); ok {
+		// 回收站保留天数，0 表示不自动清理
+		dao.StartTrashPurger(ts, time.Duration(getEnvInt("TRASH_RETENTION_DAYS", 30))*24*time.Hour)
+	}
+
+	// 搜索索引：常驻内存，随笔记变更增量更新，定期保存到 SEARCH_INDEX_PATH (设为空则不持久化)
+	ix := search.NewIndexer(s, getEnv("SEARCH_INDEX_PATH", "data/search.idx"))
+
+	// AI 服务商：AI_PROVIDER 选择 openai (默认，OpenAI 兼容接口) / anthropic / ollama
+	provider, err := ai.New(ai.Config{
+		Provider: os.Getenv("AI_PROVIDER"),
+		BaseURL:  os.Getenv("AI_BASE_URL"),
+		APIKey:   os.Getenv("AI_API_KEY"),
+		Model:    os.Getenv("AI_MODEL_NAME"),
+		// 超时 (秒)：连接超时，以及等待上游数据的空闲超时
+		ConnectTimeout: time.Duration(getEnvInt("AI_CONNECT_TIMEOUT", 10)) * time.Second,
+		IdleTimeout:    time.Duration(getEnvInt("AI_IDLE_TIMEOUT", 120)) * time.Second,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	// 用量记录：每次调用的 token / 耗时写入数据库；AI_DAILY_TOKEN_BUDGET 每日 token 预算，AI_RATE_LIMIT 每个客户端每分钟请求数 (0 表示不限)
+	meter := usage.NewMeter(s, int64(getEnvInt("AI_DAILY_TOKEN_BUDGET", 0)), getEnvInt("AI_RATE_LIMIT", 0))
+	provider = ai.Metered(provider, meter.Record)
+	// 结果缓存：只对标记为可缓存的模板生效，AI_CACHE_SIZE_MB 为 0 时不缓存
+	cache := ai.NewCache(time.Duration(getEnvInt("AI_CACHE_TTL", 86400))*time.Second, getEnvInt("AI_CACHE_SIZE_MB", 32)<<20)
+
+	// 后台批量任务：AI_JOB_WORKERS 个 worker 并发调用服务商 (数据库后端支持)
+	jr := jobs.New(s, provider, meter, getEnvInt("AI_JOB_WORKERS", 2))
 
-	// 初始化 MySQL DAO
-	s := dao.NewNoteDAO(dbUser, dbPwd, dbHost, dbPort, dbName)
+	// 向量索引：配置了 AI_EMBEDDING_MODEL 才启用，通过服务商的 embeddings 接口生成向量
+	var sem *semantic.Indexer
+	if embedModel := os.Getenv("AI_EMBEDDING_MODEL"); embedModel != "" {
+		embedder := ai.Embedder{Provider: provider, Name: embedModel}
+		sem = semantic.NewIndexer(s, embedder, getEnv("EMBEDDING_INDEX_PATH", "data/embeddings.idx"))
+	}
 
 	// 2. 初始化路由并启动服务
-	r := router.SetupRouter(s, staticFiles)
+	r := router.SetupRouter(provider, s, ix, sem, meter, cache, jr, staticFiles)
+	// 反向代理：只采信 TRUSTED_PROXIES (逗号分隔的 IP / CIDR) 转发来的 X-Forwarded-For，
+	// 默认不信任任何代理，按连接的对端地址识别客户端 (限流和用量记录依赖它，不能由请求头伪造)
+	if err := r.SetTrustedProxies(getEnvList("TRUSTED_PROXIES")); err != nil {
+		log.Fatalf("TRUSTED_PROXIES 配置错误: %v", err)
+	}
 
 	if port := os.Getenv("PORT"); port == "" {
 		log.Println("服务启动在 :8080")
@@ -34,6 +84,34 @@ func main() {
 	}
 }
 
+// newStore 根据 DB_DRIVER 选择存储后端，默认 MySQL
+func newStore() dao.NoteStore {
+	switch driver := getEnv("DB_DRIVER", "mysql"); driver {
+	case "mysql":
+		dbUser := getEnv("DB_USER", "root")
+		dbPwd := getEnv("DB_PASSWORD", "rootpassword")
+		dbHost := getEnv("DB_HOST", "mysql")
+		dbPort := getEnv("DB_PORT", "3306")
+		dbName := getEnv("DB_NAME", "notes_db")
+		return dao.NewNoteDAO(dbUser, dbPwd, dbHost, dbPort, dbName)
+	case "postgres":
+		dbUser := getEnv("DB_USER", "postgres")
+		dbPwd := getEnv("DB_PASSWORD", "rootpassword")
+		dbHost := getEnv("DB_HOST", "postgres")
+		dbPort := getEnv("DB_PORT", "5432")
+		dbName := getEnv("DB_NAME", "notes_db")
+		sslMode := getEnv("DB_SSLMODE", "disable")
+		return dao.NewPostgresNoteDAO(dbUser, dbPwd, dbHost, dbPort, dbName, sslMode)
+	case "sqlite":
+		return dao.NewSQLiteNoteDAO(getEnv("DB_PATH", "data/inkflow.db"))
+	case "fs":
+		return dao.NewFileNoteStore(getEnv("NOTES_DIR", "data/notes"))
+	default:
+		log.Fatalf("不支持的存储后端 DB_DRIVER=%s", driver)
+		return nil
+	}
+}
+
 // 辅助函数：读取环境变量
 func getEnv(key, fallback string) string {
 	if value, exists := os.LookupEnv(key); exists {
@@ -41,3 +119,25 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+// 辅助函数：读取整数环境变量，解析失败时使用默认值
+func getEnvInt(key string, fallback int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+		log.Printf("环境变量 %s=%q 不是整数，使用默认值 %d", key, value, fallback)
+	}
+	return fallback
+}
+
+// 辅助函数：读取逗号分隔的列表环境变量，未设置时为 nil
+func getEnvList(key string) []string {
+	var list []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
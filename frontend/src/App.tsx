@@ -14,6 +14,21 @@ interface NoteItem {
   folder: string;
 }
 
+// 🔥 AI 修改中的一处改动 (diff 事件中的 changes)
+interface AIChange {
+  index: number;
+  old: string[] | null; // 原文中被替换的行，纯插入时为空
+  new: string[] | null; // 替换后的行，纯删除时为空
+}
+
+// 🔥 待审阅的 AI 修改：original 为修改前的整篇笔记，output 为应用全部修改后的整篇笔记
+interface AIReview {
+  original: string;
+  output: string;
+  changes: AIChange[];
+  accepted: Set<number>;
+}
+
 function App() {
   const [content, setContent] = useState("");
   const [title, setTitle] = useState("");
@@ -41,11 +56,16 @@ function App() {
   // === 撤回状态 ===
   const [historyContent, setHistoryContent] = useState<string | null>(null);
 
+  // === AI 修改审阅状态 ===
+  const [aiOutput, setAIOutput] = useState(""); // 流式输出中的 AI 结果，只用于预览
+  const [aiReview, setAIReview] = useState<AIReview | null>(null);
+
   // === 文件夹内联重命名状态 ===
   const [editingFolder, setEditingFolder] = useState<string | null>(null); // 当前正在编辑的文件夹名
   const [tempFolderName, setTempFolderName] = useState(""); // 编辑框中的临时值
 
   const fileInputRef = useRef<HTMLInputElement>(null);
+  const editorRef = useRef<HTMLTextAreaElement>(null);
 
   useEffect(() => {
     fetchNotesList();
@@ -149,6 +169,7 @@ function App() {
       setOriginalLocation({ title: data.title, folder: data.folder || "" }); // 记录原始位置
       setContent(data.content);
       setHistoryContent(null);
+      setAIReview(null);
     } catch (e) {
       alert("加载笔记失败");
     }
@@ -232,7 +253,7 @@ function App() {
     });
   };
 
-  // API: AI 润色 (保持不变)
+  // API: AI 润色 / 格式化：输出完毕后逐处审阅修改，确认后由后端写回
   const handlePolish = async () => {
     await callAIStreaming('/api/ai/polish');
   };
@@ -243,18 +264,31 @@ function App() {
 
   const callAIStreaming = async (endpoint: string) => {
     if (!content.trim()) { alert("请先输入一些内容"); return; }
-    setHistoryContent(content);
+    if (!title.trim()) { alert("请输入标题"); return; }
+
+    // 有选中文字时只修改选区；selection_start 按字符计，与后端一致
+    const editor = editorRef.current;
+    const body: Record<string, unknown> = { title, content };
+    if (editor && editor.selectionStart !== editor.selectionEnd) {
+      body.selection = content.slice(editor.selectionStart, editor.selectionEnd);
+      body.selection_start = Array.from(content.slice(0, editor.selectionStart)).length;
+    }
+
     setLoading(true);
+    setAIOutput("");
+    setAIReview(null);
     try {
       const response = await fetch(endpoint, {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
-        body: JSON.stringify({ content }),
+        body: JSON.stringify(body),
       });
 
-      if (!response.ok) throw new Error(`HTTP ${response.status}`);
+      if (!response.ok) {
+        const err = await response.json().catch(() => ({}));
+        throw new Error(err.error || `HTTP ${response.status}`);
+      }
 
-      setContent("");
       if (!response.body) return;
       const reader = response.body.getReader();
       const decoder = new TextDecoder();
@@ -270,20 +304,70 @@ function App() {
 
         for (const line of lines) {
           const trimmed = line.trim();
-          if (trimmed.startsWith('data: ') && trimmed !== 'data: [DONE]') {
+          if (trimmed.startsWith('data: ')) {
+            // 统一事件流：delta / usage / diff / done / error，与后端使用的 AI 服务商无关
+            let json;
             try {
-              const json = JSON.parse(trimmed.replace('data: ', ''));
-              const token = json.choices?.[0]?.delta?.content || "";
-              if (token) setContent(prev => prev + token);
-            } catch (e) { console.error(e); }
+              json = JSON.parse(trimmed.replace('data: ', ''));
+            } catch (e) { console.error(e); continue; }
+            if (json.type === 'delta' && json.text) setAIOutput(prev => prev + json.text);
+            if (json.type === 'diff') {
+              const changes: AIChange[] = json.changes || [];
+              if (changes.length === 0) {
+                alert("AI 没有做任何修改");
+              } else {
+                // 默认全部接受，由用户逐处取消
+                setAIReview({ original: content, output: json.output, changes, accepted: new Set(changes.map(c => c.index)) });
+              }
+            }
+            if (json.type === 'error') throw new Error(json.error);
           }
         }
       }
     } catch (err) {
       console.error(err);
-      alert("AI 服务连接失败");
+      alert("AI 服务出错: " + (err instanceof Error ? err.message : err));
     } finally {
       setLoading(false);
+      setAIOutput("");
+    }
+  };
+
+  const toggleAIChange = (index: number) => {
+    if (!aiReview) return;
+    const accepted = new Set(aiReview.accepted);
+    if (accepted.has(index)) accepted.delete(index);
+    else accepted.add(index);
+    setAIReview({ ...aiReview, accepted });
+  };
+
+  // 把接受的修改写回笔记；笔记在审阅期间被修改过时后端会三方合并，有冲突时返回 409
+  const handleApplyAI = async () => {
+    if (!aiReview) return;
+    if (aiReview.accepted.size === 0) { setAIReview(null); return; }
+    try {
+      const res = await fetch('/api/ai/apply', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({
+          title,
+          folder,
+          original: aiReview.original,
+          output: aiReview.output,
+          accept: Array.from(aiReview.accepted)
+        })
+      });
+      const data = await res.json();
+      if (!res.ok) {
+        alert("❌ 应用 AI 修改失败: " + (data.error || "未知错误"));
+        return;
+      }
+      setHistoryContent(content);
+      setContent(data.content);
+      setAIReview(null);
+      fetchNotesList();
+    } catch (e) {
+      alert("❌ 请求出错");
     }
   };
 
@@ -507,6 +591,7 @@ function App() {
     setOriginalLocation(null); // 新建笔记没有原始位置
     setContent("");
     setHistoryContent(null);
+    setAIReview(null);
     setIsBatchMode(false);
   };
 
@@ -722,8 +807,29 @@ function App() {
               <button onClick={toggleBatchMode} style={{ padding: '10px 20px', background: '#2563eb', color: 'white', border: 'none', borderRadius: '6px', cursor: 'pointer' }}>退出批量模式</button>
             </div>
           )}
-          <textarea value={content} onChange={(e) => setContent(e.target.value)} placeholder="在此输入 Markdown 内容..." style={{ flex: 1, padding: '20px', border: 'none', borderRight: '1px solid #e5e7eb', fontSize: '16px', outline: 'none', resize: 'none', fontFamily: 'monospace', lineHeight: '1.6', background: '#f9fafb' }} />
-          <div style={{ flex: 1, padding: '20px', overflowY: 'auto', background: '#fff', lineHeight: '1.6' }}><ReactMarkdown>{content}</ReactMarkdown></div>
+          <textarea ref={editorRef} readOnly={loading || aiReview !== null} value={content} onChange={(e) => setContent(e.target.value)} placeholder="在此输入 Markdown 内容..." style={{ flex: 1, padding: '20px', border: 'none', borderRight: '1px solid #e5e7eb', fontSize: '16px', outline: 'none', resize: 'none', fontFamily: 'monospace', lineHeight: '1.6', background: '#f9fafb' }} />
+          {aiReview ? (
+            // 🔥 逐处审阅 AI 修改
+            <div style={{ flex: 1, padding: '20px', overflowY: 'auto', background: '#fff', display: 'flex', flexDirection: 'column', gap: '10px' }}>
+              <div style={{ display: 'flex', alignItems: 'center', gap: '10px' }}>
+                <span style={{ fontWeight: 'bold', flex: 1 }}>AI 修改 ({aiReview.accepted.size}/{aiReview.changes.length} 处已接受)</span>
+                <button onClick={handleApplyAI} style={{ padding: '6px 12px', background: '#10b981', color: 'white', border: 'none', borderRadius: '6px', cursor: 'pointer' }}>应用</button>
+                <button onClick={() => setAIReview(null)} style={{ padding: '6px 12px', background: '#f3f4f6', color: '#374151', border: 'none', borderRadius: '6px', cursor: 'pointer' }}>放弃</button>
+              </div>
+              {aiReview.changes.map(change => (
+                <div key={change.index} onClick={() => toggleAIChange(change.index)} style={{ border: '1px solid #e5e7eb', borderRadius: '6px', cursor: 'pointer', opacity: aiReview.accepted.has(change.index) ? 1 : 0.5 }}>
+                  <div style={{ display: 'flex', alignItems: 'center', gap: '6px', padding: '6px 10px', borderBottom: '1px solid #e5e7eb', fontSize: '12px', color: '#6b7280' }}>
+                    {aiReview.accepted.has(change.index) ? <CheckSquare size={14} color="#10b981" /> : <Square size={14} color="#9ca3af" />}
+                    第 {change.index + 1} 处
+                  </div>
+                  {change.old?.length ? <pre style={{ margin: 0, padding: '6px 10px', background: '#fef2f2', color: '#b91c1c', whiteSpace: 'pre-wrap', textDecoration: 'line-through' }}>{change.old.join('\n')}</pre> : null}
+                  {change.new?.length ? <pre style={{ margin: 0, padding: '6px 10px', background: '#f0fdf4', color: '#15803d', whiteSpace: 'pre-wrap' }}>{change.new.join('\n')}</pre> : null}
+                </div>
+              ))}
+            </div>
+          ) : (
+            <div style={{ flex: 1, padding: '20px', overflowY: 'auto', background: '#fff', lineHeight: '1.6' }}><ReactMarkdown>{loading && aiOutput ? aiOutput : content}</ReactMarkdown></div>
+          )}
         </div>
       </div>
     </div>